@@ -0,0 +1,185 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"time"
+)
+
+var _ AtomicCache = (*MemCache)(nil)
+
+// SetIfAbsent stores key/value with the provided TTL only if key is missing
+// or expired, and reports whether it stored the value.
+//
+// Stores follow the rules of Set: values exceeding the size limits are
+// rejected with a *SizeError, and an admission policy may reject the new key,
+// in which case SetIfAbsent returns false as well.
+func (mc *MemCache) SetIfAbsent(_ context.Context, key string, value any, ttl time.Duration) (bool, error) {
+	return mc.setIf(key, newEntry(value, ttl, mc.now()), func(_ entry, ok bool) (bool, error) {
+		return !ok, nil
+	})
+}
+
+// CompareAndSwap stores newValue under key with the provided TTL only if the
+// current value of key has the digest expected, as returned by Digest, and
+// reports whether it stored the value. Tags of the entry are dropped as by
+// Set.
+//
+// If key is missing or expired, CompareAndSwap returns ErrNotFound. If the
+// current value has no stable digest (Digest returns 0 for it), it returns
+// ErrType since the value can never be matched.
+func (mc *MemCache) CompareAndSwap(
+	_ context.Context,
+	key string,
+	expected Digest,
+	newValue any,
+	ttl time.Duration,
+) (bool, error) {
+	return mc.setIf(key, newEntry(newValue, ttl, mc.now()), func(current entry, ok bool) (bool, error) {
+		if !ok {
+			return false, ErrNotFound
+		}
+
+		digest := DigestWith(current.value, mc.newHash)
+		if digest == 0 {
+			return false, ErrType
+		}
+
+		return digest == expected, nil
+	})
+}
+
+// Incr atomically adds delta to the integer value of key and returns the new
+// value.
+//
+// The value may be of any signed or unsigned integer type and keeps its type;
+// its expiry and tags are kept as well. If key is missing or expired, Incr
+// stores delta as an int64 that does not expire; to count within a window,
+// create the counter first with SetIfAbsent and a TTL.
+//
+// Incr returns ErrType if the value of key is not an integer, and ErrOverflow
+// if the result does not fit the integer type of the value or an int64.
+func (mc *MemCache) Incr(_ context.Context, key string, delta int64) (int64, error) {
+	return mc.incr(key, delta)
+}
+
+// Decr atomically subtracts delta from the integer value of key and returns
+// the new value. See Incr.
+func (mc *MemCache) Decr(_ context.Context, key string, delta int64) (int64, error) {
+	if delta == math.MinInt64 {
+		return 0, ErrOverflow
+	}
+
+	return mc.incr(key, -delta)
+}
+
+func (mc *MemCache) incr(key string, delta int64) (int64, error) {
+	defer mc.metrics.SetLatency.ObserveSince(time.Now())
+
+	mc.mx.Lock()
+
+	result, admission, err := mc.incrLocked(key, delta)
+
+	mc.mx.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+
+	mc.stored(admission)
+
+	if mc.wlog != nil {
+		return result, mc.wlog.commit()
+	}
+
+	return result, nil
+}
+
+// incrLocked adds delta to the value of key and stores the sum. The caller
+// must hold the write lock.
+func (mc *MemCache) incrLocked(key string, delta int64) (int64, Admission, error) {
+	val, ok := mc.liveLocked(key)
+	if !ok {
+		val = newEntry(int64(0), 0, mc.now())
+	}
+
+	value, result, err := addInt(val.value, delta)
+	if err != nil {
+		return 0, Admission{Evicted: nil, Decision: DecisionNone}, err
+	}
+
+	val.value = value
+
+	if err := mc.sizeEntry(key, &val); err != nil {
+		return 0, Admission{Evicted: nil, Decision: DecisionNone}, err
+	}
+
+	if mc.wlog != nil {
+		record, err := encodeLogSet(key, val, mc.codec)
+		if err != nil {
+			return 0, Admission{Evicted: nil, Decision: DecisionNone}, err
+		}
+
+		mc.wlog.append(record)
+	}
+
+	admission := mc.storeLocked(key, val)
+	mc.logEvicted(admission.Evicted)
+
+	return result, admission, nil
+}
+
+// addInt adds delta to the integer value and returns the sum both with the
+// type of value and as an int64.
+func addInt(value any, delta int64) (any, int64, error) {
+	current := reflect.ValueOf(value)
+	if !current.IsValid() {
+		return nil, 0, ErrType
+	}
+
+	sum := reflect.New(current.Type()).Elem()
+
+	switch current.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := current.Int()
+		if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) ||
+			sum.OverflowInt(n+delta) {
+			return nil, 0, ErrOverflow
+		}
+
+		sum.SetInt(n + delta)
+
+		return sum.Interface(), n + delta, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := current.Uint()
+		if n > math.MaxInt64 || (delta < 0 && n < uint64(-delta)) {
+			return nil, 0, ErrOverflow
+		}
+
+		result := int64(n) + delta //nolint:gosec // n <= math.MaxInt64
+		if (delta > 0 && result < 0) || sum.OverflowUint(uint64(result)) {
+			return nil, 0, ErrOverflow
+		}
+
+		sum.SetUint(uint64(result))
+
+		return sum.Interface(), result, nil
+	default:
+		return nil, 0, ErrType
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"math"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/cachetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemCacheSetIfAbsent(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	mcache := newMemCache(t, cache.WithClock(clock))
+
+	stored, err := mcache.SetIfAbsent(ctx, "key", "first", 0)
+	require.NoError(t, err)
+	assert.True(t, stored)
+
+	stored, err = mcache.SetIfAbsent(ctx, "key", "second", 0)
+	require.NoError(t, err)
+	assert.False(t, stored)
+
+	value, err := mcache.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "first", value)
+
+	require.NoError(t, mcache.Set(ctx, "expired", "old", time.Nanosecond))
+	clock.Advance(time.Millisecond)
+
+	stored, err = mcache.SetIfAbsent(ctx, "expired", "new", 0)
+	require.NoError(t, err)
+	assert.True(t, stored, "expired entries count as missing")
+	assert.Equal(t, uint64(1), mcache.Metrics().LazyEvictions)
+
+	_, err = newMemCache(t, cache.WithMaxValueBytes(1)).SetIfAbsent(ctx, "key", "too large", 0)
+	require.ErrorIs(t, err, cache.ErrTooLarge)
+}
+
+func TestMemCacheSetIfAbsentConcurrent(t *testing.T) {
+	t.Parallel()
+
+	scache := cache.NewSharded(4, time.Hour, Logger(t))
+
+	t.Cleanup(func() {
+		require.NoError(t, scache.Close(t.Context()))
+	})
+
+	const workers = 64
+
+	var (
+		wg      sync.WaitGroup
+		winners atomic.Int64
+	)
+
+	wg.Add(workers)
+
+	for worker := range workers {
+		go func() {
+			defer wg.Done()
+
+			stored, err := scache.SetIfAbsent(t.Context(), "request-id", worker, time.Minute)
+			if assert.NoError(t, err) && stored {
+				winners.Add(1)
+			}
+		}()
+	}
+
+	wg.Wait()
+	assert.Equal(t, int64(1), winners.Load())
+}
+
+func TestMemCacheCompareAndSwap(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := newMemCache(t)
+
+	_, err := mcache.CompareAndSwap(ctx, "missing", 1, "value", 0)
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	require.NoError(t, mcache.SetWithTags(ctx, "point", snapshotPoint{X: 1, Y: 2}, 0, "tag"))
+	digest := mcache.Digest(ctx, "point")
+
+	swapped, err := mcache.CompareAndSwap(ctx, "point", digest+1, snapshotPoint{X: 0, Y: 0}, 0)
+	require.NoError(t, err)
+	assert.False(t, swapped)
+
+	swapped, err = mcache.CompareAndSwap(ctx, "point", digest, snapshotPoint{X: 3, Y: 4}, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, swapped)
+
+	swapped, err = mcache.CompareAndSwap(ctx, "point", digest, snapshotPoint{X: 5, Y: 6}, 0)
+	require.NoError(t, err)
+	assert.False(t, swapped, "the digest is stale after a swap")
+
+	value, err := mcache.Get(ctx, "point")
+	require.NoError(t, err)
+	assert.Equal(t, snapshotPoint{X: 3, Y: 4}, value)
+
+	require.NoError(t, mcache.InvalidateTags(ctx, "tag"))
+	assert.Equal(t, 1, mcache.Size(), "swapped entries drop their tags")
+
+	require.NoError(t, mcache.Set(ctx, "func", func() {}, 0))
+
+	_, err = mcache.CompareAndSwap(ctx, "func", 0, "value", 0)
+	require.ErrorIs(t, err, cache.ErrType)
+}
+
+func TestMemCacheCompareAndSwapConcurrent(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := newMemCache(t)
+
+	require.NoError(t, mcache.Set(ctx, "counter", 0, 0))
+
+	const (
+		workers    = 8
+		increments = 100
+	)
+
+	var wg sync.WaitGroup
+
+	wg.Add(workers)
+
+	for range workers {
+		go func() {
+			defer wg.Done()
+
+			for range increments {
+				for {
+					value, err := mcache.Get(ctx, "counter")
+					if !assert.NoError(t, err) {
+						return
+					}
+
+					current, ok := value.(int)
+					if !assert.True(t, ok) {
+						return
+					}
+
+					swapped, err := mcache.CompareAndSwap(ctx, "counter", cache.DigestOf(current), current+1, 0)
+					if !assert.NoError(t, err) || swapped {
+						break
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	value, err := mcache.Get(ctx, "counter")
+	require.NoError(t, err)
+	assert.Equal(t, workers*increments, value)
+}
+
+func TestMemCacheIncr(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := newMemCache(t)
+
+	n, err := mcache.Incr(ctx, "hits", 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+
+	n, err = mcache.Decr(ctx, "hits", 7)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-2), n)
+
+	value, err := mcache.Get(ctx, "hits")
+	require.NoError(t, err)
+	assert.Equal(t, int64(-2), value, "missing counters are created as int64")
+
+	require.NoError(t, mcache.Set(ctx, "small", uint8(250), time.Hour))
+
+	n, err = mcache.Incr(ctx, "small", 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(255), n)
+
+	value, err = mcache.Get(ctx, "small")
+	require.NoError(t, err)
+	assert.Equal(t, uint8(255), value, "counters keep their type")
+
+	overflows := map[string]struct {
+		value any
+		delta int64
+	}{
+		"uint8 above max":  {value: uint8(255), delta: 1},
+		"uint below zero":  {value: uint(0), delta: -1},
+		"int8 below min":   {value: int8(-128), delta: -1},
+		"int64 above max":  {value: int64(math.MaxInt64), delta: 1},
+		"uint64 above max": {value: uint64(math.MaxUint64), delta: 0},
+	}
+
+	for name, overflow := range overflows {
+		require.NoError(t, mcache.Set(ctx, name, overflow.value, 0))
+
+		_, err = mcache.Incr(ctx, name, overflow.delta)
+		require.ErrorIs(t, err, cache.ErrOverflow, name)
+
+		value, err = mcache.Get(ctx, name)
+		require.NoError(t, err)
+		assert.Equal(t, overflow.value, value, "%s: failed updates leave the value alone", name)
+	}
+
+	_, err = mcache.Decr(ctx, "hits", math.MinInt64)
+	require.ErrorIs(t, err, cache.ErrOverflow)
+
+	require.NoError(t, mcache.Set(ctx, "str", "1", 0))
+
+	_, err = mcache.Incr(ctx, "str", 1)
+	require.ErrorIs(t, err, cache.ErrType)
+}
+
+func TestMemCacheIncrKeepsExpiry(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	mcache := newMemCache(t, cache.WithClock(clock))
+
+	stored, err := mcache.SetIfAbsent(ctx, "window", 0, 50*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, stored)
+
+	for range 3 {
+		_, err = mcache.Incr(ctx, "window", 1)
+		require.NoError(t, err)
+	}
+
+	value, err := mcache.Get(ctx, "window")
+	require.NoError(t, err)
+	assert.Equal(t, 3, value)
+
+	clock.Advance(60 * time.Millisecond)
+
+	n, err := mcache.Incr(ctx, "window", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n, "an expired counter starts over")
+}
+
+func TestMemCacheIncrConcurrent(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := newMemCache(t)
+
+	const (
+		workers    = 16
+		increments = 1000
+	)
+
+	var wg sync.WaitGroup
+
+	wg.Add(workers)
+
+	for range workers {
+		go func() {
+			defer wg.Done()
+
+			for range increments {
+				_, err := mcache.Incr(ctx, "counter", 1)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	value, err := mcache.Get(ctx, "counter")
+	require.NoError(t, err)
+	assert.Equal(t, int64(workers*increments), value)
+	assert.Equal(t, uint64(workers*increments), mcache.Metrics().Sets)
+}
+
+func TestMemCacheAtomicWriteLog(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	path := filepath.Join(t.TempDir(), "cache.log")
+	first := openLogged(t, path)
+
+	_, err := first.SetIfAbsent(ctx, "once", "v1", 0)
+	require.NoError(t, err)
+	_, err = first.SetIfAbsent(ctx, "once", "v2", 0)
+	require.NoError(t, err)
+	_, err = first.CompareAndSwap(ctx, "once", first.Digest(ctx, "once"), "v3", 0)
+	require.NoError(t, err)
+	_, err = first.Incr(ctx, "counter", 2)
+	require.NoError(t, err)
+	_, err = first.Decr(ctx, "counter", 1)
+	require.NoError(t, err)
+	require.NoError(t, first.Close(ctx))
+
+	second := openLogged(t, path)
+
+	value, err := second.Get(ctx, "once")
+	require.NoError(t, err)
+	assert.Equal(t, "v3", value)
+
+	value, err = second.Get(ctx, "counter")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), value)
+}
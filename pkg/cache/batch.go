@@ -0,0 +1,221 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// GetMany returns the values of the keys that were found and, in the order
+// of keys, the keys that are missing or expired.
+//
+// All keys are read under a single read lock. A second, write lock is taken
+// only if some entries need it: expired entries are then removed as by Get,
+// and entries written with SetSliding have their expiry extended. Stale
+// entries written with SetWithRefresh start a background refresh. Every key
+// counts as one hit or miss, as if read by Get; the batch is observed once in
+// GetLatency.
+//
+// The operation can be cancelled via the context. If cancelled, GetMany
+// returns ErrAborted; keys read before the cancellation are counted in
+// Metrics.
+func (mc *MemCache) GetMany(ctx context.Context, keys ...string) (map[string]any, []string, error) {
+	defer mc.metrics.GetLatency.ObserveSince(time.Now())
+
+	var (
+		found     = make(map[string]any, len(keys))
+		missing   []string
+		expired   []string
+		sliding   []string
+		refreshes []refreshJob
+	)
+
+	now := mc.now()
+
+	mc.mx.RLock()
+
+	for _, key := range keys {
+		select {
+		case <-ctx.Done():
+			mc.mx.RUnlock()
+			mc.log.Error().
+				Err(ctx.Err()).
+				Str("key", key).
+				Msg("get many aborted")
+
+			return nil, nil, ErrAborted
+		default:
+		}
+
+		if mc.policy != nil {
+			mc.policy.Access(key)
+		}
+
+		val, ok := mc.items[key]
+
+		switch {
+		case !ok:
+			missing = append(missing, key)
+			mc.metrics.addMiss(now)
+		case val.IsExpired(now):
+			missing = append(missing, key)
+			expired = append(expired, key)
+			mc.metrics.addMiss(now)
+		default:
+			found[key] = val.value
+			mc.metrics.addHit(now)
+
+			if val.sliding {
+				sliding = append(sliding, key)
+			}
+
+			if mc.refreshFn != nil && val.NeedsRefresh(now) {
+				refreshes = append(refreshes, refreshJob{key: key, entry: val})
+			}
+		}
+	}
+
+	mc.mx.RUnlock()
+
+	if len(expired) > 0 || len(sliding) > 0 {
+		mc.settle(expired, sliding)
+	}
+
+	for _, job := range refreshes {
+		mc.startRefresh(job.key, job.entry)
+	}
+
+	return found, missing, nil
+}
+
+// settle removes the entries of expired that are still expired and extends
+// the expiry of the sliding entries of sliding, under one write lock.
+func (mc *MemCache) settle(expired, sliding []string) {
+	mc.mx.Lock()
+	defer mc.mx.Unlock()
+
+	now := mc.now()
+
+	for _, key := range expired {
+		if val, ok := mc.items[key]; ok && val.IsExpired(now) {
+			mc.removeLocked(key, ReasonExpired)
+			mc.metrics.AddLazyEviction()
+		}
+	}
+
+	for _, key := range sliding {
+		mc.slideLocked(key)
+	}
+}
+
+// DeleteMany removes keys as Delete does and returns the number of keys that
+// held a live entry. Expired entries are removed as well but not counted.
+//
+// All keys are removed under a single write lock; the batch is observed once
+// in DeleteLatency. If cancelled, DeleteMany returns ErrAborted along with the
+// number of keys removed so far.
+func (mc *MemCache) DeleteMany(ctx context.Context, keys ...string) (int, error) {
+	defer mc.metrics.DeleteLatency.ObserveSince(time.Now())
+
+	deleted, err := mc.delete(ctx, keys)
+	if err != nil {
+		return deleted, err
+	}
+
+	if mc.wlog != nil {
+		return deleted, mc.wlog.commit()
+	}
+
+	return deleted, nil
+}
+
+// SetMany stores items, each with its own TTL, as Set would.
+//
+// Every item is sized and, for a cache opened with Open, encoded before the
+// cache is modified: if one of them is rejected with a *SizeError or
+// ErrType, nothing is stored. The items are then stored in order under a
+// single write lock. Later items win over earlier ones with the same key.
+// Every item counts as one set; the batch is observed once in SetLatency.
+//
+// The operation can be cancelled via the context. If cancelled, SetMany
+// returns ErrAborted; items stored before the cancellation stay stored.
+func (mc *MemCache) SetMany(ctx context.Context, items ...Item) error {
+	defer mc.metrics.SetLatency.ObserveSince(time.Now())
+
+	entries := make([]entry, len(items))
+
+	var records [][]byte
+	if mc.wlog != nil {
+		records = make([][]byte, len(items))
+	}
+
+	now := mc.now()
+
+	for i, item := range items {
+		entries[i] = newEntry(item.Value, item.TTL, now)
+
+		if err := mc.sizeEntry(item.Key, &entries[i]); err != nil {
+			return err
+		}
+
+		if records != nil {
+			record, err := encodeLogSet(item.Key, entries[i], mc.codec)
+			if err != nil {
+				return err
+			}
+
+			records[i] = record
+		}
+	}
+
+	if err := mc.setMany(ctx, items, entries, records); err != nil {
+		return err
+	}
+
+	if mc.wlog != nil {
+		return mc.wlog.commit()
+	}
+
+	return nil
+}
+
+func (mc *MemCache) setMany(ctx context.Context, items []Item, entries []entry, records [][]byte) error {
+	mc.mx.Lock()
+	defer mc.mx.Unlock()
+
+	for i, item := range items {
+		select {
+		case <-ctx.Done():
+			mc.log.Error().
+				Err(ctx.Err()).
+				Str("key", item.Key).
+				Msg("set many aborted")
+
+			return ErrAborted
+		default:
+			admission := mc.storeLocked(item.Key, entries[i])
+
+			if records != nil {
+				mc.wlog.append(records[i])
+				mc.logEvicted(admission.Evicted)
+			}
+
+			mc.stored(admission)
+		}
+	}
+
+	return nil
+}
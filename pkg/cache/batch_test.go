@@ -0,0 +1,280 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/cachetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemCacheGetMany(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	mcache := newMemCache(t, cache.WithClock(clock))
+
+	require.NoError(t, mcache.Set(ctx, "a", 1, 0))
+	require.NoError(t, mcache.Set(ctx, "b", "two", 0))
+	require.NoError(t, mcache.Set(ctx, "expired", 3, time.Nanosecond))
+	clock.Advance(time.Millisecond)
+
+	found, missing, err := mcache.GetMany(ctx, "a", "missing", "b", "expired")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": 1, "b": "two"}, found)
+	assert.Equal(t, []string{"missing", "expired"}, missing)
+
+	snps := mcache.Metrics()
+	assert.Equal(t, uint64(2), snps.Hits)
+	assert.Equal(t, uint64(2), snps.Misses)
+	assert.Equal(t, uint64(1), snps.LazyEvictions)
+	assert.Equal(t, uint64(1), snps.GetLatency.Count)
+	assert.Equal(t, 2, mcache.Size(), "expired entries are removed")
+
+	found, missing, err = mcache.GetMany(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+	assert.Empty(t, missing)
+}
+
+func TestMemCacheGetManySliding(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	mcache := newMemCache(t, cache.WithClock(clock))
+
+	require.NoError(t, mcache.SetSliding(ctx, "session", "user", 100*time.Millisecond))
+	require.NoError(t, mcache.Set(ctx, "fixed", "user", 100*time.Millisecond))
+	clock.Advance(50 * time.Millisecond)
+
+	_, _, err := mcache.GetMany(ctx, "session", "fixed")
+	require.NoError(t, err)
+
+	sliding, err := mcache.TTL(ctx, "session")
+	require.NoError(t, err)
+
+	fixed, err := mcache.TTL(ctx, "fixed")
+	require.NoError(t, err)
+
+	assert.Equal(t, 100*time.Millisecond, sliding, "GetMany extends sliding entries")
+	assert.Equal(t, 50*time.Millisecond, fixed)
+}
+
+func TestMemCacheSetMany(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	mcache := newMemCache(t, cache.WithClock(clock))
+
+	require.NoError(t, mcache.SetMany(ctx,
+		cache.Item{Key: "a", Value: 1, TTL: 0},
+		cache.Item{Key: "b", Value: "two", TTL: time.Hour},
+		cache.Item{Key: "short", Value: 3, TTL: time.Nanosecond},
+		cache.Item{Key: "a", Value: 4, TTL: 0},
+	))
+	clock.Advance(time.Millisecond)
+
+	found, missing, err := mcache.GetMany(ctx, "a", "b", "short")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": 4, "b": "two"}, found, "later items win")
+	assert.Equal(t, []string{"short"}, missing, "items keep their own TTL")
+
+	ttl, err := mcache.TTL(ctx, "b")
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour-time.Millisecond, ttl)
+
+	snps := mcache.Metrics()
+	assert.Equal(t, uint64(4), snps.Sets)
+	assert.Equal(t, uint64(1), snps.SetLatency.Count)
+
+	limited := newMemCache(t, cache.WithMaxValueBytes(4))
+
+	err = limited.SetMany(ctx,
+		cache.Item{Key: "ok", Value: "v", TTL: 0},
+		cache.Item{Key: "large", Value: "too large", TTL: 0},
+	)
+	require.ErrorIs(t, err, cache.ErrTooLarge)
+	assert.Zero(t, limited.Size(), "rejected batches store nothing")
+}
+
+func TestMemCacheSetManyCapacity(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := newMemCache(t, cache.WithMaxEntries(2))
+
+	require.NoError(t, mcache.SetMany(ctx,
+		cache.Item{Key: "a", Value: 1, TTL: 0},
+		cache.Item{Key: "b", Value: 2, TTL: 0},
+		cache.Item{Key: "c", Value: 3, TTL: 0},
+	))
+
+	assert.Equal(t, 2, mcache.Size())
+	assert.Equal(t, uint64(1), mcache.Metrics().CapacityEvictions)
+
+	_, missing, err := mcache.GetMany(ctx, "a", "b", "c")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, missing)
+}
+
+func TestMemCacheDeleteMany(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	mcache := newMemCache(t, cache.WithClock(clock))
+
+	require.NoError(t, mcache.SetMany(ctx,
+		cache.Item{Key: "a", Value: 1, TTL: 0},
+		cache.Item{Key: "b", Value: 2, TTL: 0},
+		cache.Item{Key: "expired", Value: 3, TTL: time.Nanosecond},
+	))
+	clock.Advance(time.Millisecond)
+
+	deleted, err := mcache.DeleteMany(ctx, "a", "b", "expired", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted, "expired and missing keys are not counted")
+	assert.Zero(t, mcache.Size())
+	assert.Equal(t, uint64(1), mcache.Metrics().DeleteLatency.Count, "the batch is observed once")
+
+	deleted, err = mcache.DeleteMany(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
+
+func TestMemCacheBatchCancelled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	mcache := newMemCache(t)
+
+	_, _, err := mcache.GetMany(ctx, "a")
+	require.ErrorIs(t, err, cache.ErrAborted)
+
+	err = mcache.SetMany(ctx, cache.Item{Key: "a", Value: 1, TTL: 0})
+	require.ErrorIs(t, err, cache.ErrAborted)
+	assert.Zero(t, mcache.Size())
+	assert.Zero(t, mcache.Metrics().Sets)
+
+	deleted, err := mcache.DeleteMany(ctx, "a")
+	require.ErrorIs(t, err, cache.ErrAborted)
+	assert.Zero(t, deleted)
+}
+
+func TestMemCacheSetManyWriteLog(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	path := filepath.Join(t.TempDir(), "cache.log")
+	first := openLogged(t, path)
+
+	require.NoError(t, first.SetMany(ctx,
+		cache.Item{Key: "a", Value: 1, TTL: 0},
+		cache.Item{Key: "point", Value: snapshotPoint{X: 1, Y: 2}, TTL: time.Hour},
+	))
+	require.NoError(t, first.Close(ctx))
+
+	second := openLogged(t, path)
+
+	found, missing, err := second.GetMany(ctx, "a", "point")
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, map[string]any{"a": 1, "point": snapshotPoint{X: 1, Y: 2}}, found)
+}
+
+func TestShardedBatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	scache := cache.NewSharded(8, time.Hour, Logger(t))
+
+	t.Cleanup(func() {
+		require.NoError(t, scache.Close(ctx))
+	})
+
+	items := make([]cache.Item, 0, 100)
+	keys := make([]string, 0, 101)
+
+	for i := range 100 {
+		key := fmt.Sprintf("k-%d", i)
+		items = append(items, cache.Item{Key: key, Value: i, TTL: 0})
+		keys = append(keys, key)
+	}
+
+	keys = append(keys, "missing")
+
+	require.NoError(t, scache.SetMany(ctx, items...))
+	assert.Equal(t, 100, scache.Size())
+
+	found, missing, err := scache.GetMany(ctx, keys...)
+	require.NoError(t, err)
+	assert.Len(t, found, 100)
+	assert.Equal(t, 42, found["k-42"])
+	assert.Equal(t, []string{"missing"}, missing)
+
+	snps := scache.Metrics()
+	assert.Equal(t, uint64(100), snps.Hits)
+	assert.Equal(t, uint64(1), snps.Misses)
+
+	deleted, err := scache.DeleteMany(ctx, keys...)
+	require.NoError(t, err)
+	assert.Equal(t, 100, deleted)
+	assert.Zero(t, scache.Size())
+}
+
+func TestEncodedBatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	encoded := cache.NewEncoded(newMemCache(t), cache.GobCodec{})
+
+	require.NoError(t, encoded.SetMany(ctx,
+		cache.Item{Key: "point", Value: snapshotPoint{X: 1, Y: 2}, TTL: 0},
+		cache.Item{Key: "str", Value: "value", TTL: 0},
+	))
+
+	raw, err := encoded.Cache().Get(ctx, "point")
+	require.NoError(t, err)
+	assert.IsType(t, []byte(nil), raw)
+
+	found, missing, err := encoded.GetMany(ctx, "point", "str", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"point": snapshotPoint{X: 1, Y: 2}, "str": "value"}, found)
+	assert.Equal(t, []string{"missing"}, missing)
+
+	err = encoded.SetMany(ctx, cache.Item{Key: "func", Value: func() {}, TTL: 0})
+	require.ErrorIs(t, err, cache.ErrType)
+
+	require.NoError(t, encoded.Cache().Set(ctx, "garbage", 42, 0))
+
+	_, _, err = encoded.GetMany(ctx, "point", "garbage")
+	require.ErrorIs(t, err, cache.ErrType)
+
+	deleted, err := encoded.DeleteMany(ctx, "point", "garbage", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+}
@@ -22,8 +22,9 @@ import (
 // Digest is a stable, cheap fingerprint of a cached value.
 //
 // For this package's MemCache implementation, Digest is defined and stable for
-// primitive types (string, []byte, bool, ints, uints, floats). For other
-// types, or when a key is missing or expired, implementations may return 0.
+// primitive types (string, []byte, bool, ints, uints, floats) and for
+// composite values built from them (see DigestWith). For other types, or when
+// a key is missing or expired, implementations may return 0.
 type Digest uint64
 
 // Cache is a minimal cache interface with TTL support.
@@ -34,10 +35,54 @@ type Digest uint64
 //
 // Implementations may evict expired items lazily (on reads) and/or via a background
 // cleanup process.
+//
+// Batch operations:
+//   - GetMany returns the values of the keys that were found and, in order,
+//     the keys that are missing or expired
+//   - SetMany stores several items, each with its own TTL
+//   - DeleteMany removes several keys and reports how many of them were
+//     present; Delete does the same without the count
+//
+// Implementations serve batches in as few round trips or lock acquisitions as
+// they can. A batch may be applied partially if it fails or its context is
+// cancelled.
 type Cache interface {
 	Set(ctx context.Context, key string, value any, ttl time.Duration) error
 	Get(ctx context.Context, key string) (any, error)
+	GetMany(ctx context.Context, keys ...string) (map[string]any, []string, error)
+	SetMany(ctx context.Context, items ...Item) error
 	Delete(ctx context.Context, keys ...string) error
+	DeleteMany(ctx context.Context, keys ...string) (int, error)
 	Digest(ctx context.Context, key string) Digest
 	Close(ctx context.Context) error
 }
+
+// Item is a key/value pair stored by SetMany with its own TTL.
+type Item struct {
+	Key   string
+	Value any
+	TTL   time.Duration
+}
+
+// AtomicCache is a Cache that also supports conditional writes and atomic
+// counters, for example for idempotency keys and rate limits.
+//
+// Every method is atomic with respect to all other operations on the same key.
+// Expired entries count as missing. TTL semantics are those of Cache.Set.
+type AtomicCache interface {
+	Cache
+	// SetIfAbsent stores key/value only if key is missing and reports whether
+	// it stored the value.
+	SetIfAbsent(ctx context.Context, key string, value any, ttl time.Duration) (bool, error)
+	// CompareAndSwap stores newValue only if the current value of key has the
+	// digest expected, and reports whether it stored the value. It returns
+	// ErrNotFound if key is missing and ErrType if the current value has no
+	// digest.
+	CompareAndSwap(ctx context.Context, key string, expected Digest, newValue any, ttl time.Duration) (bool, error)
+	// Incr adds delta to the integer value of key and returns the result. A
+	// missing key is created with the value delta and no expiry. It returns
+	// ErrType if the value of key is not an integer.
+	Incr(ctx context.Context, key string, delta int64) (int64, error)
+	// Decr subtracts delta from the integer value of key like Incr.
+	Decr(ctx context.Context, key string, delta int64) (int64, error)
+}
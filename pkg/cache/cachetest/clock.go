@@ -0,0 +1,133 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cachetest provides test helpers for the cache package, such as a
+// manual Clock that makes expiry and background cleanup deterministic.
+package cachetest
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+)
+
+// Clock is a cache.Clock whose time only moves when Advance or Set is
+// called. Moving it fires the tickers that came due, as a real clock would
+// have by then.
+//
+// A ticker that came due several times fires once and then resumes on its
+// period, and a tick is dropped while the previous one is still unread, the
+// way a time.Ticker treats slow receivers. Ticks are delivered to goroutines
+// such as the background cleaner of a MemCache asynchronously, so tests
+// should wait for the effect of a tick, for example with require.Eventually
+// on the cleanup metrics, before advancing again.
+//
+// Clock is safe for concurrent use.
+type Clock struct {
+	now     time.Time
+	tickers []*ticker
+	mx      sync.Mutex
+}
+
+var _ cache.Clock = (*Clock)(nil)
+
+// NewClock returns a Clock set to start.
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start, tickers: nil, mx: sync.Mutex{}}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	return c.now
+}
+
+// NewTicker returns a ticker firing every d of clock time. It panics if d
+// is not positive, like time.NewTicker.
+func (c *Clock) NewTicker(d time.Duration) cache.Ticker {
+	if d <= 0 {
+		panic("cachetest: non-positive interval for NewTicker")
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	t := &ticker{
+		clock:  c,
+		ch:     make(chan time.Time, 1),
+		period: d,
+		next:   c.now.Add(d),
+	}
+	c.tickers = append(c.tickers, t)
+
+	return t
+}
+
+// Advance moves the clock forward by d and fires the tickers that came due.
+func (c *Clock) Advance(d time.Duration) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.setLocked(c.now.Add(d))
+}
+
+// Set moves the clock to now and fires the tickers that came due. Moving
+// the clock backwards fires no ticker.
+func (c *Clock) Set(now time.Time) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.setLocked(now)
+}
+
+func (c *Clock) setLocked(now time.Time) {
+	c.now = now
+
+	for _, t := range c.tickers {
+		if now.Before(t.next) {
+			continue
+		}
+
+		select {
+		case t.ch <- now:
+		default:
+		}
+
+		t.next = t.next.Add((now.Sub(t.next)/t.period + 1) * t.period)
+	}
+}
+
+type ticker struct {
+	clock  *Clock
+	ch     chan time.Time
+	next   time.Time
+	period time.Duration
+}
+
+func (t *ticker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *ticker) Stop() {
+	t.clock.mx.Lock()
+	defer t.clock.mx.Unlock()
+
+	t.clock.tickers = slices.DeleteFunc(t.clock.tickers, func(other *ticker) bool {
+		return other == t
+	})
+}
@@ -0,0 +1,64 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import "time"
+
+// Clock tells a MemCache the time. Expiry, refresh deadlines, TTL, the
+// background cleaner, write log syncs and compactions, and the recent hit
+// and miss windows of Metrics all follow it, so a manual Clock, such as
+// cachetest.Clock, makes them deterministic in tests. The default Clock is
+// the system clock; set another with WithClock.
+//
+// Latency histograms measure real time and always use the system clock.
+type Clock interface {
+	Now() time.Time
+	// NewTicker returns a Ticker delivering the time on its channel every d,
+	// dropping ticks for slow receivers like time.NewTicker. d must be > 0.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers the ticks of a Clock.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{ticker: time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t systemTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// now returns the current time of the cache Clock in UTC.
+func (mc *MemCache) now() time.Time {
+	return mc.clock.Now().UTC()
+}
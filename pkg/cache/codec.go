@@ -0,0 +1,122 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec converts cache values to bytes and back.
+//
+// Codecs let typed and persistent layers store arbitrary values in backends
+// that only hold bytes. Unmarshal receives a pointer to the destination value.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec is a Codec backed by encoding/json.
+type JSONCodec struct{}
+
+// Marshal returns the JSON encoding of v.
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal parses JSON data into the value pointed to by v.
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// MsgpackCodec is a Codec backed by MessagePack.
+//
+// It produces more compact encodings than JSONCodec and, like it, decodes
+// into a *any as generic maps and slices. Struct fields are named by their
+// msgpack tags, falling back to json tags and then to the field name.
+type MsgpackCodec struct{}
+
+// Marshal returns the MessagePack encoding of v.
+func (MsgpackCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal parses MessagePack data into the value pointed to by v.
+func (MsgpackCodec) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+
+	return dec.Decode(v)
+}
+
+// GobCodec is a Codec backed by encoding/gob.
+//
+// Values are encoded as interface values, so decoding into a *any restores
+// their concrete type. This makes GobCodec suitable for persisting values of
+// mixed types, such as MemCache snapshots, but every concrete type that is not
+// a gob built-in must be registered with gob.Register on both ends.
+type GobCodec struct{}
+
+// Marshal returns the gob encoding of v as an interface value.
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes data into the value pointed to by v. If v is not a *any,
+// the decoded value must be assignable to *v.
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	var decoded any
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&decoded); err != nil {
+		return err
+	}
+
+	if ptr, ok := v.(*any); ok {
+		*ptr = decoded
+		return nil
+	}
+
+	dst := reflect.ValueOf(v)
+	if dst.Kind() != reflect.Pointer || dst.IsNil() {
+		return fmt.Errorf("%w: gob: unmarshal into non-pointer %T", ErrType, v)
+	}
+
+	src := reflect.ValueOf(decoded)
+	if !src.IsValid() || !src.Type().AssignableTo(dst.Elem().Type()) {
+		return fmt.Errorf("%w: gob: cannot assign %T to %s", ErrType, decoded, dst.Elem().Type())
+	}
+
+	dst.Elem().Set(src)
+
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGobCodec(t *testing.T) {
+	t.Parallel()
+
+	codec := cache.GobCodec{}
+
+	data, err := codec.Marshal(snapshotPoint{X: 1, Y: 2})
+	require.NoError(t, err)
+
+	var decoded any
+	require.NoError(t, codec.Unmarshal(data, &decoded))
+	assert.Equal(t, snapshotPoint{X: 1, Y: 2}, decoded, "decoding into *any keeps the concrete type")
+
+	var point snapshotPoint
+	require.NoError(t, codec.Unmarshal(data, &point))
+	assert.Equal(t, snapshotPoint{X: 1, Y: 2}, point)
+
+	var wrong string
+	require.ErrorIs(t, codec.Unmarshal(data, &wrong), cache.ErrType)
+	require.ErrorIs(t, codec.Unmarshal(data, point), cache.ErrType)
+
+	_, err = codec.Marshal(struct{ Unregistered int }{})
+	require.Error(t, err)
+}
+
+type codecUser struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+func TestMsgpackCodec(t *testing.T) {
+	t.Parallel()
+
+	codec := cache.MsgpackCodec{}
+	user := codecUser{Name: "alice", Roles: []string{"admin"}}
+
+	data, err := codec.Marshal(user)
+	require.NoError(t, err)
+
+	jsonData, err := cache.JSONCodec{}.Marshal(user)
+	require.NoError(t, err)
+	assert.Less(t, len(data), len(jsonData))
+
+	var decoded codecUser
+	require.NoError(t, codec.Unmarshal(data, &decoded))
+	assert.Equal(t, user, decoded)
+
+	var generic any
+	require.NoError(t, codec.Unmarshal(data, &generic))
+	assert.Equal(t, map[string]any{"name": "alice", "roles": []any{"admin"}}, generic, "json tags name the fields")
+
+	require.Error(t, codec.Unmarshal([]byte{0xc1}, &generic))
+}
+
+func TestCodecs(t *testing.T) {
+	t.Parallel()
+
+	codecs := cache.NewCodecs(nil)
+	cache.RegisterCodec[codecUser](codecs, "user", cache.MsgpackCodec{})
+	cache.RegisterCodec[*snapshotPoint](codecs, "point", cache.JSONCodec{})
+
+	assert.Panics(t, func() { cache.RegisterCodec[codecUser](codecs, "other", cache.JSONCodec{}) })
+	assert.Panics(t, func() { cache.RegisterCodec[string](codecs, "user", cache.JSONCodec{}) })
+	assert.Panics(t, func() { cache.RegisterCodec[string](codecs, "", cache.JSONCodec{}) })
+
+	user := codecUser{Name: "bob", Roles: nil}
+
+	data, err := codecs.Marshal(user)
+	require.NoError(t, err)
+
+	var decoded any
+	require.NoError(t, codecs.Unmarshal(data, &decoded))
+	assert.Equal(t, user, decoded, "registered types keep their type through *any")
+
+	var typed codecUser
+	require.NoError(t, codecs.Unmarshal(data, &typed))
+	assert.Equal(t, user, typed)
+
+	var wrong snapshotPoint
+	require.ErrorIs(t, codecs.Unmarshal(data, &wrong), cache.ErrType)
+
+	data, err = codecs.Marshal(&snapshotPoint{X: 1, Y: 2})
+	require.NoError(t, err)
+	require.NoError(t, codecs.Unmarshal(data, &decoded))
+	assert.Equal(t, &snapshotPoint{X: 1, Y: 2}, decoded)
+
+	data, err = codecs.Marshal(snapshotPoint{X: 3, Y: 4})
+	require.NoError(t, err)
+	require.NoError(t, codecs.Unmarshal(data, &decoded))
+	assert.Equal(t, snapshotPoint{X: 3, Y: 4}, decoded, "unregistered types use the gob fallback")
+
+	other := cache.NewCodecs(cache.JSONCodec{})
+	data, err = codecs.Marshal(user)
+	require.NoError(t, err)
+	require.ErrorIs(t, other.Unmarshal(data, &decoded), cache.ErrType, "unknown names are type errors")
+	require.ErrorIs(t, other.Unmarshal([]byte{0x80}, &decoded), cache.ErrType)
+	require.ErrorIs(t, other.Unmarshal([]byte{0x05, 'a'}, &decoded), cache.ErrType)
+}
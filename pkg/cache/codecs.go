@@ -0,0 +1,158 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Codecs is a Codec that picks a Codec per value type.
+//
+// Types are registered under a name with RegisterCodec. Marshal prefixes the
+// encoding of a value of a registered type with the name of the type, so
+// Unmarshal can decode it with the same codec into the same type, also when
+// decoding into a *any. Values of other types are encoded with the fallback
+// codec.
+//
+// Both ends must register the same types under the same names. Codecs is safe
+// for concurrent use.
+type Codecs struct {
+	fallback Codec
+	byType   map[reflect.Type]*typeCodec
+	byName   map[string]*typeCodec
+	mx       sync.RWMutex
+}
+
+type typeCodec struct {
+	name  string
+	typ   reflect.Type
+	codec Codec
+}
+
+// NewCodecs returns an empty registry encoding values of unregistered types
+// with fallback. If fallback is nil, GobCodec is used.
+func NewCodecs(fallback Codec) *Codecs {
+	if fallback == nil {
+		fallback = GobCodec{}
+	}
+
+	return &Codecs{
+		fallback: fallback,
+		byType:   make(map[reflect.Type]*typeCodec),
+		byName:   make(map[string]*typeCodec),
+		mx:       sync.RWMutex{},
+	}
+}
+
+// RegisterCodec registers codec for values of type V under name. Like
+// gob.Register, it panics if name is empty or if V or name are already
+// registered.
+func RegisterCodec[V any](codecs *Codecs, name string, codec Codec) {
+	typ := reflect.TypeFor[V]()
+
+	codecs.mx.Lock()
+	defer codecs.mx.Unlock()
+
+	if name == "" {
+		panic("cache: RegisterCodec with empty name")
+	}
+
+	if _, ok := codecs.byType[typ]; ok {
+		panic(fmt.Sprintf("cache: RegisterCodec called twice for type %s", typ))
+	}
+
+	if _, ok := codecs.byName[name]; ok {
+		panic(fmt.Sprintf("cache: RegisterCodec called twice for name %q", name))
+	}
+
+	tc := &typeCodec{name: name, typ: typ, codec: codec}
+	codecs.byType[typ] = tc
+	codecs.byName[name] = tc
+}
+
+// Marshal encodes v with the codec registered for its type, or with the
+// fallback codec.
+func (c *Codecs) Marshal(v any) ([]byte, error) {
+	c.mx.RLock()
+	tc := c.byType[reflect.TypeOf(v)]
+	c.mx.RUnlock()
+
+	var (
+		name  string
+		codec = c.fallback
+	)
+
+	if tc != nil {
+		name, codec = tc.name, tc.codec
+	}
+
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 0, binary.MaxVarintLen64+len(name)+len(data))
+	buf = binary.AppendUvarint(buf, uint64(len(name)))
+	buf = append(buf, name...)
+
+	return append(buf, data...), nil
+}
+
+// Unmarshal decodes data produced by Marshal into the value pointed to by v,
+// which must be a *any or a pointer to the type data was encoded from. It
+// returns an error wrapping ErrType if data is malformed, was encoded from a
+// type registered under an unknown name, or does not decode into v.
+func (c *Codecs) Unmarshal(data []byte, v any) error {
+	size, n := binary.Uvarint(data)
+	if n <= 0 || size > uint64(len(data)-n) {
+		return fmt.Errorf("%w: codecs: malformed header", ErrType)
+	}
+
+	name, payload := string(data[n:n+int(size)]), data[n+int(size):] //nolint:gosec // bounded by len(data)
+
+	if name == "" {
+		return c.fallback.Unmarshal(payload, v)
+	}
+
+	c.mx.RLock()
+	tc := c.byName[name]
+	c.mx.RUnlock()
+
+	if tc == nil {
+		return fmt.Errorf("%w: codecs: unknown type name %q", ErrType, name)
+	}
+
+	ptr, ok := v.(*any)
+	if !ok {
+		dst := reflect.ValueOf(v)
+		if dst.Kind() != reflect.Pointer || dst.IsNil() || dst.Elem().Type() != tc.typ {
+			return fmt.Errorf("%w: codecs: cannot decode %s into %T", ErrType, tc.typ, v)
+		}
+
+		return tc.codec.Unmarshal(payload, v)
+	}
+
+	decoded := reflect.New(tc.typ)
+	if err := tc.codec.Unmarshal(payload, decoded.Interface()); err != nil {
+		return err
+	}
+
+	*ptr = decoded.Elem().Interface()
+
+	return nil
+}
@@ -0,0 +1,338 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// HashFunc returns a new 64-bit hash used to compute digests.
+type HashFunc func() hash.Hash64
+
+// NewFNV64a returns a 64-bit FNV-1a hash. It is the default HashFunc, and the
+// one byte-oriented backends use to compute digests of stored bytes.
+func NewFNV64a() hash.Hash64 {
+	return fnv.New64a()
+}
+
+// NewXXHash returns a 64-bit xxHash hash, which is faster than FNV-1a on
+// large values.
+func NewXXHash() hash.Hash64 {
+	return xxhash.New()
+}
+
+// Digester is implemented by types that compute their own Digest. Values
+// implementing Digester are fingerprinted by calling Digest, at the top level
+// as well as nested in composite values.
+type Digester interface {
+	Digest() Digest
+}
+
+// maxDigestDepth bounds the nesting of composite values, so that cyclic
+// values yield 0 instead of recursing forever.
+const maxDigestDepth = 64
+
+// DigestOf returns the Digest of value as computed by MemCache with the
+// default FNV-1a hash. See DigestWith.
+func DigestOf(value any) Digest {
+	return DigestWith(value, NewFNV64a)
+}
+
+// DigestWith returns the Digest of value computed with the hash returned by
+// newHash.
+//
+// Digests are deterministic across processes and releases for:
+//   - primitives (string, []byte, bool, ints, uints, floats): the hash of
+//     their bytes. The digest equals the digest of the bytes returned by
+//     EncodeValue, so byte-oriented backends can compute matching digests
+//     from stored bytes.
+//   - values implementing Digester: their own Digest.
+//   - composite values built from the above and from named types with a
+//     primitive kind: slices and arrays in order, maps with their entries
+//     sorted by key, structs with their fields sorted by name, so reordering
+//     fields keeps digests stable. Pointers and interfaces are followed; nil
+//     and empty slices and maps digest the same. time.Time values digest
+//     their instant, not their location or monotonic reading.
+//
+// Values holding functions, channels or unsafe pointers, structs with
+// unexported fields that do not implement Digester (such as big.Int or
+// netip.Addr, whose state is not visible), values nested deeper than 64
+// levels (such as cyclic values) and nil, including nil pointers to
+// Digesters, yield 0.
+func DigestWith(value any, newHash HashFunc) Digest {
+	if value == nil {
+		return 0
+	}
+
+	if digester, ok := value.(Digester); ok {
+		if isNilPointer(reflect.ValueOf(value)) {
+			return 0
+		}
+
+		return digester.Digest()
+	}
+
+	hash := newHash()
+
+	switch val := value.(type) {
+	case []byte:
+		_, _ = hash.Write(val)
+	case string:
+		_, _ = hash.Write([]byte(val))
+	case int, int8, int16, int32, int64:
+		fmt.Fprintf(hash, "%d", val)
+	case uint, uint8, uint16, uint32, uint64, uintptr:
+		fmt.Fprintf(hash, "%d", val)
+	case float32, float64:
+		fmt.Fprintf(hash, "%g", val)
+	case bool:
+		_, _ = hash.Write([]byte{boolToUint8(val)})
+	default:
+		buf, ok := appendCanonical(nil, reflect.ValueOf(value), 0)
+		if !ok {
+			return 0
+		}
+
+		_, _ = hash.Write(buf)
+	}
+
+	return Digest(hash.Sum64())
+}
+
+// isPrimitive reports whether value is of one of the primitive types whose
+// digests byte-oriented backends reproduce.
+func isPrimitive(value any) bool {
+	switch value.(type) {
+	case []byte, string, int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, uintptr, float32, float64, bool:
+		return true
+	default:
+		return false
+	}
+}
+
+// Tags of the canonical encoding of composite values.
+const (
+	tagNil byte = iota + 1
+	tagBool
+	tagInt
+	tagUint
+	tagFloat
+	tagComplex
+	tagString
+	tagBytes
+	tagList
+	tagMap
+	tagStruct
+	tagTime
+	tagDigest
+)
+
+var (
+	digesterType = reflect.TypeFor[Digester]()
+	timeType     = reflect.TypeFor[time.Time]()
+	// structFields caches the structLayout of struct types.
+	structFields sync.Map
+)
+
+// appendCanonical appends a canonical, unambiguous encoding of v to buf. It
+// returns false if v cannot be encoded deterministically.
+func appendCanonical(buf []byte, v reflect.Value, depth int) ([]byte, bool) {
+	if depth > maxDigestDepth {
+		return buf, false
+	}
+
+	if !v.IsValid() {
+		return append(buf, tagNil), true
+	}
+
+	if v.Type().Implements(digesterType) && !isNilPointer(v) {
+		digester, _ := v.Interface().(Digester)
+		buf = append(buf, tagDigest)
+
+		return binary.BigEndian.AppendUint64(buf, uint64(digester.Digest())), true
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return append(buf, tagBool, boolToUint8(v.Bool())), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(append(buf, tagInt), v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return binary.AppendUvarint(append(buf, tagUint), v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return binary.BigEndian.AppendUint64(append(buf, tagFloat), math.Float64bits(v.Float())), true
+	case reflect.Complex64, reflect.Complex128:
+		buf = binary.BigEndian.AppendUint64(append(buf, tagComplex), math.Float64bits(real(v.Complex())))
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(imag(v.Complex()))), true
+	case reflect.String:
+		return appendString(append(buf, tagString), v.String()), true
+	case reflect.Slice, reflect.Array:
+		return appendList(buf, v, depth)
+	case reflect.Map:
+		return appendMap(buf, v, depth)
+	case reflect.Struct:
+		return appendStruct(buf, v, depth)
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return append(buf, tagNil), true
+		}
+
+		return appendCanonical(buf, v.Elem(), depth+1)
+	case reflect.Invalid, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return buf, false
+	default:
+		return buf, false
+	}
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendList(buf []byte, v reflect.Value, depth int) ([]byte, bool) {
+	if v.Type().Elem().Kind() == reflect.Uint8 && v.Kind() == reflect.Slice {
+		buf = binary.AppendUvarint(append(buf, tagBytes), uint64(v.Len()))
+		return append(buf, v.Bytes()...), true
+	}
+
+	buf = binary.AppendUvarint(append(buf, tagList), uint64(v.Len()))
+
+	ok := true
+	for i := 0; i < v.Len() && ok; i++ {
+		buf, ok = appendCanonical(buf, v.Index(i), depth+1)
+	}
+
+	return buf, ok
+}
+
+func appendMap(buf []byte, v reflect.Value, depth int) ([]byte, bool) {
+	type pair struct {
+		key, value []byte
+	}
+
+	pairs := make([]pair, 0, v.Len())
+
+	for iter := v.MapRange(); iter.Next(); {
+		key, ok := appendCanonical(nil, iter.Key(), depth+1)
+		if !ok {
+			return buf, false
+		}
+
+		value, ok := appendCanonical(nil, iter.Value(), depth+1)
+		if !ok {
+			return buf, false
+		}
+
+		pairs = append(pairs, pair{key: key, value: value})
+	}
+
+	slices.SortFunc(pairs, func(a, b pair) int {
+		return bytes.Compare(a.key, b.key)
+	})
+
+	buf = binary.AppendUvarint(append(buf, tagMap), uint64(len(pairs)))
+	for _, p := range pairs {
+		buf = append(append(buf, p.key...), p.value...)
+	}
+
+	return buf, true
+}
+
+func appendStruct(buf []byte, v reflect.Value, depth int) ([]byte, bool) {
+	typ := v.Type()
+
+	if typ == timeType {
+		t, _ := v.Interface().(time.Time)
+		buf = binary.AppendVarint(append(buf, tagTime), t.Unix())
+
+		return binary.AppendVarint(buf, int64(t.Nanosecond())), true
+	}
+
+	fields, ok := sortedFields(typ)
+	if !ok {
+		return buf, false
+	}
+
+	buf = binary.AppendUvarint(append(buf, tagStruct), uint64(len(fields)))
+
+	for i := 0; i < len(fields) && ok; i++ {
+		buf = appendString(buf, typ.Field(fields[i]).Name)
+		buf, ok = appendCanonical(buf, v.Field(fields[i]), depth+1)
+	}
+
+	return buf, ok
+}
+
+// structLayout is the cached digest layout of a struct type.
+type structLayout struct {
+	fields []int // field indexes sorted by field name
+	opaque bool  // has unexported fields, which cannot be digested
+}
+
+// sortedFields returns the indexes of the fields of the struct type typ
+// sorted by field name, skipping blank fields. It returns false if typ has
+// unexported fields: their state would not be part of the digest, so
+// different values would digest the same.
+func sortedFields(typ reflect.Type) ([]int, bool) {
+	if cached, ok := structFields.Load(typ); ok {
+		layout, _ := cached.(structLayout)
+		return layout.fields, !layout.opaque
+	}
+
+	layout := structLayout{fields: make([]int, 0, typ.NumField()), opaque: false}
+
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+
+		switch {
+		case field.Name == "_":
+		case field.IsExported():
+			layout.fields = append(layout.fields, i)
+		default:
+			layout.opaque = true
+		}
+	}
+
+	slices.SortFunc(layout.fields, func(a, b int) int {
+		return strings.Compare(typ.Field(a).Name, typ.Field(b).Name)
+	})
+
+	structFields.Store(typ, layout)
+
+	return layout.fields, !layout.opaque
+}
+
+func isNilPointer(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"bytes"
+	"hash/fnv"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type digestAddress struct {
+	City string
+	Zip  int
+}
+
+type digestUser struct {
+	Name  string
+	Tags  []string
+	Attrs map[string]any
+	Home  *digestAddress
+	Seen  time.Time
+}
+
+// digestUserReordered has the fields of digestUser in another order.
+type digestUserReordered struct {
+	Seen  time.Time
+	Home  *digestAddress
+	Attrs map[string]any
+	Tags  []string
+	Name  string
+}
+
+type versioned struct {
+	Version int
+	Payload []byte
+}
+
+func (v versioned) Digest() cache.Digest {
+	return cache.Digest(v.Version)
+}
+
+func newDigestUser() digestUser {
+	return digestUser{
+		Name:  "alice",
+		Tags:  []string{"a", "b"},
+		Attrs: map[string]any{"x": 1, "y": []int{2, 3}, "z": map[int]bool{1: true, 2: false}},
+		Home:  &digestAddress{City: "Oslo", Zip: 150},
+		Seen:  time.Date(2025, 1, 2, 3, 4, 5, 6, time.UTC),
+	}
+}
+
+func TestDigestComposite(t *testing.T) {
+	t.Parallel()
+
+	user := newDigestUser()
+	digest := cache.DigestOf(user)
+	require.NotZero(t, digest)
+
+	// Maps iterate in random order; digests must not depend on it.
+	for range 20 {
+		assert.Equal(t, digest, cache.DigestOf(newDigestUser()))
+	}
+
+	reordered := digestUserReordered{
+		Seen:  user.Seen.In(time.FixedZone("CET", 3600)),
+		Home:  &digestAddress{City: "Oslo", Zip: 150},
+		Attrs: user.Attrs,
+		Tags:  user.Tags,
+		Name:  user.Name,
+	}
+	assert.Equal(t, digest, cache.DigestOf(reordered), "field order and locations do not matter")
+	assert.Equal(t, digest, cache.DigestOf(&user), "pointers are followed")
+
+	changes := map[string]func(u *digestUser){
+		"name":        func(u *digestUser) { u.Name = "bob" },
+		"tag order":   func(u *digestUser) { u.Tags = []string{"b", "a"} },
+		"nested map":  func(u *digestUser) { u.Attrs["z"] = map[int]bool{1: true, 2: true} },
+		"map key":     func(u *digestUser) { u.Attrs["w"] = u.Attrs["x"]; delete(u.Attrs, "x") },
+		"pointee":     func(u *digestUser) { u.Home.Zip = 151 },
+		"nil pointer": func(u *digestUser) { u.Home = nil },
+		"instant":     func(u *digestUser) { u.Seen = u.Seen.Add(time.Nanosecond) },
+		"value type":  func(u *digestUser) { u.Attrs["x"] = "1" },
+	}
+
+	for name, change := range changes {
+		changed := newDigestUser()
+		change(&changed)
+		assert.NotEqual(t, digest, cache.DigestOf(changed), name)
+	}
+
+	assert.NotEqual(t, cache.DigestOf([]string{"ab", "c"}), cache.DigestOf([]string{"a", "bc"}))
+	assert.NotEqual(t, cache.DigestOf([]any{1}), cache.DigestOf([]any{uint(1)}))
+	assert.Equal(t, cache.DigestOf([]int(nil)), cache.DigestOf([]int{}))
+	assert.Equal(t, cache.DigestOf([2]int{1, 2}), cache.DigestOf([]int{1, 2}))
+	assert.NotEqual(t, cache.DigestOf(struct{ B []byte }{[]byte("x")}), cache.DigestOf(struct{ B string }{"x"}))
+}
+
+func TestDigestPrimitivesUnchanged(t *testing.T) {
+	t.Parallel()
+
+	for _, value := range []any{"value", []byte{0, 1}, 42, int8(-3), uint64(7), 1.5, float32(0.25), true, false} {
+		encoded, err := cache.EncodeValue(value)
+		require.NoError(t, err)
+
+		hash := fnv.New64a()
+		_, _ = hash.Write(encoded)
+
+		assert.Equal(t, cache.Digest(hash.Sum64()), cache.DigestOf(value), "%T", value)
+		assert.Equal(t, cache.Digest(xxhash.Sum64(encoded)), cache.DigestWith(value, cache.NewXXHash), "%T", value)
+	}
+}
+
+func TestDigester(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, cache.Digest(3), cache.DigestOf(versioned{Version: 3, Payload: []byte("a")}))
+
+	a := cache.DigestOf([]versioned{{Version: 3, Payload: []byte("a")}})
+	b := cache.DigestOf([]versioned{{Version: 3, Payload: []byte("b")}})
+	assert.Equal(t, a, b, "nested Digesters are used as well")
+
+	assert.Zero(t, cache.DigestOf((*versioned)(nil)), "nil Digesters are not called")
+	assert.Equal(t, cache.DigestOf([]*digestAddress{nil}), cache.DigestOf([]*versioned{nil}),
+		"nested nil Digesters digest as nil")
+}
+
+func TestDigestUnsupported(t *testing.T) {
+	t.Parallel()
+
+	type node struct {
+		Next *node
+	}
+
+	cyclic := &node{Next: nil}
+	cyclic.Next = cyclic
+
+	assert.Zero(t, cache.DigestOf(cyclic))
+	assert.Zero(t, cache.DigestOf(map[string]any{"f": func() {}}))
+	assert.Zero(t, cache.DigestOf(struct{ C chan int }{C: make(chan int)}))
+	assert.Zero(t, cache.DigestOf(struct{ c int }{c: 1}), "unexported fields cannot be digested")
+	assert.NotZero(t, cache.DigestOf(struct {
+		_ [0]func()
+		A int
+	}{A: 1}), "blank fields are skipped")
+}
+
+type opaqueVersion struct {
+	version int
+}
+
+func (v opaqueVersion) Digest() cache.Digest {
+	return cache.Digest(v.version)
+}
+
+func TestDigestUnexportedState(t *testing.T) {
+	t.Parallel()
+
+	first := netip.MustParseAddr("10.0.0.1")
+	second := netip.MustParseAddr("10.0.0.2")
+
+	assert.Zero(t, cache.DigestOf(first))
+	assert.Zero(t, cache.DigestOf(second))
+	assert.Zero(t, cache.DigestOf([]netip.Addr{first}), "nested opaque structs yield 0 as well")
+	assert.Equal(t, cache.Digest(7), cache.DigestOf(opaqueVersion{version: 7}),
+		"Digesters may keep their state unexported")
+
+	ctx := t.Context()
+	mcache := newMemCache(t)
+
+	require.NoError(t, mcache.Set(ctx, "addr", second, 0))
+
+	swapped, err := mcache.CompareAndSwap(ctx, "addr", cache.DigestOf(first), first, 0)
+	require.ErrorIs(t, err, cache.ErrType)
+	assert.False(t, swapped)
+
+	value, err := mcache.Get(ctx, "addr")
+	require.NoError(t, err)
+	assert.Equal(t, second, value)
+}
+
+func TestMemCacheDigestHash(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	fnvCache := newMemCache(t)
+	xxCache := newMemCache(t, cache.WithDigestHash(cache.NewXXHash))
+	user := newDigestUser()
+
+	for _, mcache := range []*cache.MemCache{fnvCache, xxCache} {
+		require.NoError(t, mcache.Set(ctx, "user", user, 0))
+		require.NoError(t, mcache.Set(ctx, "str", "value", 0))
+	}
+
+	assert.Equal(t, cache.DigestOf(user), fnvCache.Digest(ctx, "user"))
+	assert.Equal(t, cache.DigestWith(user, cache.NewXXHash), xxCache.Digest(ctx, "user"))
+	assert.Equal(t, cache.Digest(xxhash.Sum64String("value")), xxCache.Digest(ctx, "str"))
+	assert.NotEqual(t, fnvCache.Digest(ctx, "user"), xxCache.Digest(ctx, "user"))
+}
+
+func BenchmarkDigest(b *testing.B) {
+	user := newDigestUser()
+	blob := bytes.Repeat([]byte{'x'}, 1024)
+
+	for name, newHash := range map[string]cache.HashFunc{"fnv64a": cache.NewFNV64a, "xxhash": cache.NewXXHash} {
+		b.Run(name+"/struct", func(b *testing.B) {
+			for b.Loop() {
+				cache.DigestWith(user, newHash)
+			}
+		})
+
+		b.Run(name+"/bytes", func(b *testing.B) {
+			for b.Loop() {
+				cache.DigestWith(blob, newHash)
+			}
+		})
+	}
+}
@@ -17,9 +17,43 @@
 //
 // The in-memory implementation (MemCache) is thread-safe and supports:
 //   - Lazy eviction: expired entries are removed on Get access
-//   - Periodic background cleaner: removes expired items in bounded batches
-//   - Optional TTL expiration per entry
-//   - Metrics tracking for cache performance
+//   - Periodic background cleaner: removes expired items in bounded batches,
+//     found through a min-heap of expiry times rather than a full scan
+//   - Optional TTL expiration per entry, sliding on reads with SetSliding;
+//     TTL, Expire, Persist and Touch inspect and change it in place
+//   - Optional capacity bound with pluggable eviction policies (LRU, W-TinyLFU)
+//   - Optional byte budget: entries are sized and evicted to fit WithMaxBytes
+//   - GetOrLoad: read-through loading with one loader call per key
+//   - Batches: GetMany and SetMany under a single lock acquisition
+//   - Refresh-ahead: stale-while-revalidate entries refreshed in the background
+//   - Iteration: All, Keys and ScanPrefix; DeletePrefix and DeleteMatch
+//   - Tags: SetWithTags and InvalidateTags drop groups of related entries
+//   - Subscribe: asynchronous events for deleted, replaced and evicted entries
+//   - Conditional writes: SetIfAbsent, CompareAndSwap and Incr/Decr counters
+//   - Digest: stable fingerprints of values, including structs, maps and
+//     slices, with FNV-1a or xxHash
+//   - Snapshot/Restore: versioned, checksummed dumps of live entries
+//   - Open: crash durability through an append-only, compacted write log
+//   - Metrics tracking for cache performance, with latency histograms and
+//     hit ratios over the last minute and five minutes
+//   - Injectable Clock (WithClock) driving expiry and the cleaner
+//
+// Sharded spreads keys over several independently locked MemCache shards to
+// reduce lock contention on many-core machines.
+//
+// Typed wraps any Cache with a type-safe API, optionally encoding values with
+// a Codec for byte-oriented backends.
+// Encoded wraps any Cache to store every value as bytes. Codecs are
+// available for JSON, gob and MessagePack, and Codecs picks one per type.
+//
+// Tiered composes a local MemCache with a remote Cache, reading through and
+// writing through both tiers.
+//
+// Subpackage redis implements Cache on top of a Redis server.
+// Subpackage memcached implements Cache on top of memcached servers.
+// Subpackage prom exports Metrics to Prometheus.
+// Subpackage otelcache traces and measures any Cache with OpenTelemetry.
+// Subpackage cachetest provides a manual Clock for deterministic tests.
 //
 // Example usage:
 //
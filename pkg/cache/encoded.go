@@ -0,0 +1,170 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Encoded is a Cache that stores values in another Cache as bytes.
+//
+// Set encodes every value with a Codec and stores the resulting []byte; Get
+// decodes it back into a *any. This lets byte-oriented backends such as the
+// redis and memcached clients hold structs. What Get returns depends on the
+// codec: GobCodec and a Codecs registry restore concrete types, JSONCodec and
+// MsgpackCodec return generic maps and slices. Use GetInto to decode into a
+// value of a known type, or Typed for a type-safe façade.
+type Encoded struct {
+	cache Cache
+	codec Codec
+}
+
+var _ Cache = (*Encoded)(nil)
+
+// NewEncoded returns a Cache storing values in c encoded with codec. If codec
+// is nil, GobCodec is used.
+func NewEncoded(c Cache, codec Codec) *Encoded {
+	if codec == nil {
+		codec = GobCodec{}
+	}
+
+	return &Encoded{cache: c, codec: codec}
+}
+
+// Set encodes value and stores it under key with the provided TTL. See Cache
+// for TTL semantics. If the value cannot be encoded, Set returns an error
+// wrapping ErrType.
+func (ec *Encoded) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	data, err := ec.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("%w: encode %q: %w", ErrType, key, err)
+	}
+
+	return ec.cache.Set(ctx, key, data, ttl)
+}
+
+// Get returns the decoded value stored under key.
+//
+// Get returns the underlying cache error (such as ErrNotFound) as-is, and an
+// error wrapping ErrType if the stored value cannot be decoded.
+func (ec *Encoded) Get(ctx context.Context, key string) (any, error) {
+	var value any
+	if err := ec.GetInto(ctx, key, &value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// GetInto decodes the value stored under key into the value pointed to by
+// dst. Errors are reported as by Get.
+func (ec *Encoded) GetInto(ctx context.Context, key string, dst any) error {
+	raw, err := ec.cache.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	return decode(ec.codec, key, raw, dst)
+}
+
+// GetMany returns the decoded values of the keys that were found and the keys
+// that are missing, as reported by the underlying cache. If a value cannot be
+// decoded, GetMany returns an error wrapping ErrType.
+func (ec *Encoded) GetMany(ctx context.Context, keys ...string) (map[string]any, []string, error) {
+	raws, missing, err := ec.cache.GetMany(ctx, keys...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	found := make(map[string]any, len(raws))
+
+	for key, raw := range raws {
+		var value any
+		if err := decode(ec.codec, key, raw, &value); err != nil {
+			return nil, nil, err
+		}
+
+		found[key] = value
+	}
+
+	return found, missing, nil
+}
+
+// SetMany encodes the values of items and stores them in the underlying
+// cache. If a value cannot be encoded, SetMany returns an error wrapping
+// ErrType and stores nothing.
+func (ec *Encoded) SetMany(ctx context.Context, items ...Item) error {
+	encoded := make([]Item, len(items))
+
+	for i, item := range items {
+		data, err := ec.codec.Marshal(item.Value)
+		if err != nil {
+			return fmt.Errorf("%w: encode %q: %w", ErrType, item.Key, err)
+		}
+
+		encoded[i] = Item{Key: item.Key, Value: data, TTL: item.TTL}
+	}
+
+	return ec.cache.SetMany(ctx, encoded...)
+}
+
+// decode decodes raw, the encoded value stored under key, into the value
+// pointed to by dst. Failures are reported as ErrType.
+func decode(codec Codec, key string, raw, dst any) error {
+	var data []byte
+
+	switch val := raw.(type) {
+	case []byte:
+		data = val
+	case string:
+		data = []byte(val)
+	default:
+		return fmt.Errorf("%w: %q holds %T, want encoded bytes", ErrType, key, raw)
+	}
+
+	if err := codec.Unmarshal(data, dst); err != nil {
+		return fmt.Errorf("%w: decode %q: %w", ErrType, key, err)
+	}
+
+	return nil
+}
+
+// Delete removes keys from the underlying cache.
+func (ec *Encoded) Delete(ctx context.Context, keys ...string) error {
+	return ec.cache.Delete(ctx, keys...)
+}
+
+// DeleteMany removes keys from the underlying cache and returns the number of
+// keys that were present.
+func (ec *Encoded) DeleteMany(ctx context.Context, keys ...string) (int, error) {
+	return ec.cache.DeleteMany(ctx, keys...)
+}
+
+// Digest returns the digest of the encoded value stored under key.
+func (ec *Encoded) Digest(ctx context.Context, key string) Digest {
+	return ec.cache.Digest(ctx, key)
+}
+
+// Close closes the underlying cache.
+func (ec *Encoded) Close(ctx context.Context) error {
+	return ec.cache.Close(ctx)
+}
+
+// Cache returns the underlying cache.
+func (ec *Encoded) Cache() Cache {
+	return ec.cache
+}
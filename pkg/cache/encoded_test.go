@@ -0,0 +1,92 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ cache.Cache = (*cache.Encoded)(nil)
+
+func TestEncoded(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := newMemCache(t)
+
+	codecs := cache.NewCodecs(cache.JSONCodec{})
+	cache.RegisterCodec[codecUser](codecs, "user", cache.MsgpackCodec{})
+
+	encoded := cache.NewEncoded(mcache, codecs)
+	user := codecUser{Name: "carol", Roles: []string{"ops"}}
+
+	require.NoError(t, encoded.Set(ctx, "user", user, 0))
+	require.NoError(t, encoded.Set(ctx, "count", 3, 0))
+
+	raw, err := mcache.Get(ctx, "user")
+	require.NoError(t, err)
+	assert.IsType(t, []byte{}, raw, "values are stored as bytes")
+
+	value, err := encoded.Get(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, user, value)
+
+	value, err = encoded.Get(ctx, "count")
+	require.NoError(t, err)
+	assert.InDelta(t, 3.0, value, 0, "unregistered types decode as the fallback codec does")
+
+	var count int
+	require.NoError(t, encoded.GetInto(ctx, "count", &count))
+	assert.Equal(t, 3, count)
+
+	assert.Equal(t, mcache.Digest(ctx, "user"), encoded.Digest(ctx, "user"))
+
+	_, err = encoded.Get(ctx, "missing")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	require.NoError(t, mcache.Set(ctx, "plain", 42, 0))
+	_, err = encoded.Get(ctx, "plain")
+	require.ErrorIs(t, err, cache.ErrType, "values must be bytes")
+
+	require.NoError(t, mcache.Set(ctx, "garbage", "\x00{", 0))
+	_, err = encoded.Get(ctx, "garbage")
+	require.ErrorIs(t, err, cache.ErrType, "decode failures are type errors")
+
+	err = encoded.Set(ctx, "func", func() {}, 0)
+	require.ErrorIs(t, err, cache.ErrType)
+
+	require.NoError(t, encoded.Delete(ctx, "user"))
+	_, err = encoded.Get(ctx, "user")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	assert.Same(t, mcache, encoded.Cache())
+}
+
+func TestEncodedDefaultCodec(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	encoded := cache.NewEncoded(newMemCache(t), nil)
+
+	require.NoError(t, encoded.Set(ctx, "point", snapshotPoint{X: 1, Y: 2}, 0))
+
+	value, err := encoded.Get(ctx, "point")
+	require.NoError(t, err)
+	assert.Equal(t, snapshotPoint{X: 1, Y: 2}, value)
+}
@@ -14,78 +14,91 @@
 
 package cache
 
-import (
-	"fmt"
-	"hash/fnv"
-	"time"
-)
+import "time"
 
 type entry struct {
-	value     any
-	expiresAt time.Time
+	value        any
+	expiresAt    time.Time
+	refreshAt    time.Time
+	ttl          time.Duration
+	refreshAfter time.Duration
+	tags         []string
+	size         int64 // estimated size of key and value in bytes
+	sliding      bool  // reads extend expiresAt by ttl
 }
 
-func newEntry(value any, ttl time.Duration) entry {
-	e := entry{value: value}
+func newEntry(value any, ttl time.Duration, now time.Time) entry {
+	return newRefreshingEntry(value, 0, ttl, now)
+}
+
+// newRefreshingEntry returns an entry with a soft deadline refreshAfter from
+// now and a hard expiry ttl from now. Non-positive durations disable the
+// deadline.
+func newRefreshingEntry(value any, refreshAfter, ttl time.Duration, now time.Time) entry {
+	e := entry{value: value, ttl: ttl, refreshAfter: refreshAfter}
+
 	if ttl > 0 {
-		e.expiresAt = time.Now().UTC().Add(ttl)
+		e.expiresAt = now.Add(ttl)
+	}
+
+	if refreshAfter > 0 {
+		e.refreshAt = now.Add(refreshAfter)
 	}
 
 	return e
 }
 
-func (e entry) IsExpired() bool {
+func (e entry) IsExpired(now time.Time) bool {
 	// Zero expiration time means "never expires".
 	if e.expiresAt.IsZero() {
 		return false
 	}
 
-	return time.Now().UTC().After(e.expiresAt)
+	return now.After(e.expiresAt)
 }
 
-func (e *entry) AsBytes() ([]byte, error) {
-	b, ok := e.value.([]byte)
-	if !ok {
-		return nil, ErrType
+// NeedsRefresh reports whether the soft deadline of the entry has passed at
+// now.
+func (e entry) NeedsRefresh(now time.Time) bool {
+	if e.refreshAt.IsZero() {
+		return false
 	}
 
-	return b, nil
+	return now.After(e.refreshAt)
 }
 
-func (e entry) Digest() Digest {
-	if e.IsExpired() {
-		return 0
-	}
+// sameStore reports whether e and other, both with a soft deadline, were
+// written by the same store. Their expiry is not compared, since Expire,
+// Touch and sliding reads change it in place.
+func (e entry) sameStore(other entry) bool {
+	return e.refreshAt.Equal(other.refreshAt)
+}
 
-	hash := fnv.New64a()
+// expireIn makes e expire ttl from now. A ttl <= 0 removes the expiry.
+func (e *entry) expireIn(ttl time.Duration, now time.Time) {
+	e.ttl = ttl
+	e.expiresAt = time.Time{}
 
-	switch val := e.value.(type) {
-	case nil:
-		return 0
-	case []byte:
-		_, _ = hash.Write(val)
-	case string:
-		_, _ = hash.Write([]byte(val))
-	case int, int8, int16, int32, int64:
-		fmt.Fprintf(hash, "%d", val)
-	case uint, uint8, uint16, uint32, uint64, uintptr:
-		fmt.Fprintf(hash, "%d", val)
-	case float32, float64:
-		fmt.Fprintf(hash, "%g", val)
-	case bool:
-		_, _ = hash.Write([]byte{boolToUint8(val)})
-	default:
-		// Unsupported type: no stable, cheap digest defined.
-		return 0
+	if ttl > 0 {
+		e.expiresAt = now.Add(ttl)
 	}
+}
 
-	return Digest(hash.Sum64())
+func (e *entry) AsBytes() ([]byte, error) {
+	b, ok := e.value.([]byte)
+	if !ok {
+		return nil, ErrType
+	}
+
+	return b, nil
 }
 
-func boolToUint8(b bool) uint8 {
-	if b {
-		return 1
+// Digest returns the digest of the value computed with newHash, or 0 if the
+// entry is expired at now.
+func (e entry) Digest(newHash HashFunc, now time.Time) Digest {
+	if e.IsExpired(now) {
+		return 0
 	}
 
-	return 0
+	return DigestWith(e.value, newHash)
 }
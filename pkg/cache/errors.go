@@ -23,4 +23,18 @@ var (
 	ErrNotFound = errors.New("not found")
 	// ErrAborted indicates an operation was cancelled via context.
 	ErrAborted = errors.New("operation aborted")
+	// ErrSnapshot indicates a snapshot is corrupt, truncated or of an
+	// unsupported version.
+	ErrSnapshot = errors.New("invalid snapshot")
+	// ErrWriteLog indicates a write log is corrupt or of an unsupported
+	// version, or that a cache has no write log.
+	ErrWriteLog = errors.New("invalid write log")
+	// ErrBadPattern indicates a malformed glob pattern.
+	ErrBadPattern = errors.New("syntax error in pattern")
+	// ErrTooLarge indicates a value exceeds the size limits of a cache. See
+	// SizeError.
+	ErrTooLarge = errors.New("value too large")
+	// ErrOverflow indicates an atomic counter update would overflow the
+	// integer type of the value.
+	ErrOverflow = errors.New("integer overflow")
 )
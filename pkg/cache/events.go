@@ -0,0 +1,258 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"slices"
+)
+
+// DefaultEventQueueSize is the default number of events buffered per
+// subscriber.
+const DefaultEventQueueSize = 1024
+
+// EventReason tells why an entry left the cache.
+type EventReason int
+
+const (
+	// ReasonDeleted means the entry was removed by Delete, DeletePrefix or
+	// DeleteMatch.
+	ReasonDeleted EventReason = iota + 1
+	// ReasonReplaced means the entry was overwritten by Set, Restore or a
+	// background refresh. The event carries the old value.
+	ReasonReplaced
+	// ReasonExpired means Get found the entry expired and evicted it.
+	ReasonExpired
+	// ReasonCleaned means the background cleaner evicted the expired entry.
+	ReasonCleaned
+	// ReasonEvicted means the cache Policy evicted the entry to respect the
+	// capacity bound, or rejected it on Set.
+	ReasonEvicted
+	// ReasonInvalidated means the entry was removed by InvalidateTags.
+	ReasonInvalidated
+	// ReasonClosed means the cache was closed while holding the entry.
+	ReasonClosed
+)
+
+// String returns the lower-case name of the reason.
+func (r EventReason) String() string {
+	switch r {
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonReplaced:
+		return "replaced"
+	case ReasonExpired:
+		return "expired"
+	case ReasonCleaned:
+		return "cleaned"
+	case ReasonEvicted:
+		return "evicted"
+	case ReasonInvalidated:
+		return "invalidated"
+	case ReasonClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes an entry leaving the cache.
+type Event struct {
+	Key    string
+	Value  any
+	Reason EventReason
+}
+
+// subscriber is a registered callback with its own bounded queue and
+// delivery goroutine.
+type subscriber struct {
+	fn     func(Event)
+	events chan Event
+	done   chan struct{}
+	drain  bool // deliver queued events after done is closed
+}
+
+// Subscribe registers fn to be called for every entry leaving the cache, and
+// returns a function that cancels the subscription.
+//
+// Every subscriber gets its own goroutine and a queue of
+// DefaultEventQueueSize events (see WithEventQueueSize). fn is called from
+// that goroutine, in the order the events happened, without any cache lock
+// held, so it may use the cache. Cache operations never wait for subscribers:
+// when a queue is full the event is dropped for that subscriber and counted
+// in Metrics.EventDrops.
+//
+// Close reports the remaining entries with ReasonClosed and waits until
+// queued events are delivered or its context is done. Once the context is
+// done, Close reports no further entries and counts them as dropped. Events
+// still queued when a subscription is cancelled may be dropped. Subscribing to a closed
+// cache does nothing.
+func (mc *MemCache) Subscribe(fn func(Event)) func() {
+	mc.subsMx.Lock()
+	defer mc.subsMx.Unlock()
+
+	if mc.subsClosed {
+		return func() {}
+	}
+
+	sub := &subscriber{
+		fn:     fn,
+		events: make(chan Event, mc.queueSize),
+		done:   make(chan struct{}),
+		drain:  false,
+	}
+
+	mc.storeSubscribers(append(mc.subscribers(), sub))
+
+	mc.subsWG.Add(1)
+
+	go mc.deliver(sub)
+
+	return func() {
+		mc.subsMx.Lock()
+		defer mc.subsMx.Unlock()
+
+		subs := mc.subscribers()
+
+		i := slices.Index(subs, sub)
+		if i < 0 {
+			return
+		}
+
+		mc.storeSubscribers(slices.Delete(slices.Clone(subs), i, i+1))
+		close(sub.done)
+	}
+}
+
+// subscribers returns the current subscriber list, which must not be
+// modified.
+func (mc *MemCache) subscribers() []*subscriber {
+	if subs := mc.subs.Load(); subs != nil {
+		return *subs
+	}
+
+	return nil
+}
+
+// storeSubscribers replaces the subscriber list. The caller must hold subsMx.
+func (mc *MemCache) storeSubscribers(subs []*subscriber) {
+	if len(subs) == 0 {
+		mc.subs.Store(nil)
+		return
+	}
+
+	mc.subs.Store(&subs)
+}
+
+// deliver calls the subscriber callback for queued events until the
+// subscription ends.
+func (mc *MemCache) deliver(sub *subscriber) {
+	defer mc.subsWG.Done()
+
+	for {
+		select {
+		case ev := <-sub.events:
+			sub.fn(ev)
+		case <-sub.done:
+			for sub.drain {
+				select {
+				case ev := <-sub.events:
+					sub.fn(ev)
+				default:
+					return
+				}
+			}
+
+			return
+		}
+	}
+}
+
+// emitLocked queues an event for every subscriber without blocking. It is
+// called with the write lock held, so events are queued in the order the
+// entries left.
+func (mc *MemCache) emitLocked(key string, value any, reason EventReason) {
+	subs := mc.subs.Load()
+	if subs == nil {
+		return
+	}
+
+	ev := Event{Key: key, Value: value, Reason: reason}
+
+	for _, sub := range *subs {
+		select {
+		case sub.events <- ev:
+		default:
+			mc.metrics.AddEventDrop()
+		}
+	}
+}
+
+// closeSubscribers reports the remaining entries with ReasonClosed, ends all
+// subscriptions and waits for queued events to be delivered, or for ctx to
+// be done.
+func (mc *MemCache) closeSubscribers(ctx context.Context) {
+	mc.subsMx.Lock()
+	subs := mc.subscribers()
+	mc.subsClosed = true
+	mc.storeSubscribers(nil)
+	mc.subsMx.Unlock()
+
+	if len(subs) == 0 {
+		return
+	}
+
+	mc.mx.RLock()
+
+	events := make([]Event, 0, len(mc.items))
+	for key, val := range mc.items {
+		events = append(events, Event{Key: key, Value: val.value, Reason: ReasonClosed})
+	}
+
+	mc.mx.RUnlock()
+
+	for _, sub := range subs {
+		for _, ev := range events {
+			// A done ctx cuts off every remaining event; left to the select,
+			// sends that are ready would race with ctx.Done.
+			if ctx.Err() != nil {
+				mc.metrics.AddEventDrop()
+				continue
+			}
+
+			select {
+			case sub.events <- ev:
+			case <-ctx.Done():
+				mc.metrics.AddEventDrop()
+			}
+		}
+
+		sub.drain = ctx.Err() == nil
+		close(sub.done)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		mc.subsWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		mc.log.Warn().Err(ctx.Err()).Msg("gave up waiting for event subscribers")
+	}
+}
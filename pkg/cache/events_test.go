@@ -0,0 +1,221 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/cachetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventLog collects events delivered to a subscriber.
+type eventLog struct {
+	events []cache.Event
+	mx     sync.Mutex
+}
+
+func (l *eventLog) add(ev cache.Event) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	l.events = append(l.events, ev)
+}
+
+func (l *eventLog) reasons() map[string]cache.EventReason {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	reasons := make(map[string]cache.EventReason, len(l.events))
+	for _, ev := range l.events {
+		reasons[fmt.Sprintf("%s=%v", ev.Key, ev.Value)] = ev.Reason
+	}
+
+	return reasons
+}
+
+func (l *eventLog) len() int {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	return len(l.events)
+}
+
+func TestMemCacheEvents(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	mcache := cache.WithDeleteInterval(5*time.Millisecond, Logger(t), cache.WithMaxEntries(3), cache.WithClock(clock))
+
+	var log eventLog
+
+	mcache.Subscribe(log.add)
+
+	require.NoError(t, mcache.Set(ctx, "replaced", 1, 0))
+	require.NoError(t, mcache.Set(ctx, "replaced", 2, 0))
+	require.NoError(t, mcache.Set(ctx, "deleted", 1, 0))
+	require.NoError(t, mcache.Delete(ctx, "deleted"))
+	require.NoError(t, mcache.SetWithTags(ctx, "invalidated", 1, 0, "tag"))
+	require.NoError(t, mcache.InvalidateTags(ctx, "tag"))
+
+	require.NoError(t, mcache.Set(ctx, "expired", 1, time.Nanosecond))
+	clock.Advance(time.Millisecond)
+
+	_, err := mcache.Get(ctx, "expired")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	require.NoError(t, mcache.Set(ctx, "cleaned", 1, time.Nanosecond))
+	clock.Advance(5 * time.Millisecond)
+	waitCleanupRuns(t, mcache, 1)
+	assert.Equal(t, uint64(1), mcache.Metrics().ScheduledEvictions)
+
+	// The cache holds "replaced", so "b" pushes out that least recently used
+	// entry.
+	require.NoError(t, mcache.Set(ctx, "c", 1, 0))
+	require.NoError(t, mcache.Set(ctx, "a", 1, 0))
+	require.NoError(t, mcache.Set(ctx, "b", 1, 0))
+
+	require.NoError(t, mcache.Close(ctx))
+
+	assert.Equal(t, map[string]cache.EventReason{
+		"replaced=1":    cache.ReasonReplaced,
+		"deleted=1":     cache.ReasonDeleted,
+		"invalidated=1": cache.ReasonInvalidated,
+		"expired=1":     cache.ReasonExpired,
+		"cleaned=1":     cache.ReasonCleaned,
+		"replaced=2":    cache.ReasonEvicted,
+		"c=1":           cache.ReasonClosed,
+		"a=1":           cache.ReasonClosed,
+		"b=1":           cache.ReasonClosed,
+	}, log.reasons())
+	assert.Equal(t, 9, log.len())
+
+	assert.Equal(t, "evicted", cache.ReasonEvicted.String())
+	assert.Equal(t, "unknown", cache.EventReason(0).String())
+}
+
+func TestMemCacheEventsSlowSubscriber(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := cache.New(Logger(t), cache.WithEventQueueSize(4))
+
+	release := make(chan struct{})
+
+	var slow eventLog
+
+	mcache.Subscribe(func(ev cache.Event) {
+		<-release
+		slow.add(ev)
+	})
+
+	// Every Set and Delete completes while the subscriber is blocked.
+	for i := range 100 {
+		key := fmt.Sprintf("k-%d", i)
+		require.NoError(t, mcache.Set(ctx, key, i, 0))
+		require.NoError(t, mcache.Delete(ctx, key))
+	}
+
+	drops := mcache.Metrics().EventDrops
+	assert.GreaterOrEqual(t, drops, uint64(100-4-1), "events beyond the queue are dropped")
+
+	close(release)
+	require.NoError(t, mcache.Close(ctx))
+
+	assert.Equal(t, uint64(100), uint64(slow.len())+drops)
+}
+
+func TestMemCacheEventsCloseCancelled(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := cache.New(Logger(t), cache.WithEventQueueSize(100))
+
+	var log eventLog
+
+	mcache.Subscribe(log.add)
+
+	for i := range 10 {
+		require.NoError(t, mcache.Set(ctx, fmt.Sprintf("k-%d", i), i, 0))
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	require.NoError(t, mcache.Close(cancelled))
+	assert.Zero(t, log.len(), "no entry is reported once the context is done")
+	assert.Equal(t, uint64(10), mcache.Metrics().EventDrops)
+}
+
+func TestMemCacheEventsReentrant(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := newMemCache(t)
+
+	var log eventLog
+
+	unsubscribe := mcache.Subscribe(func(ev cache.Event) {
+		// Callbacks run without cache locks held and may use the cache.
+		if ev.Reason == cache.ReasonDeleted {
+			assert.NoError(t, mcache.Set(ctx, "audit:"+ev.Key, ev.Value, 0))
+		}
+
+		log.add(ev)
+	})
+
+	require.NoError(t, mcache.Set(ctx, "key", 1, 0))
+	require.NoError(t, mcache.Delete(ctx, "key"))
+
+	require.Eventually(t, func() bool {
+		_, err := mcache.Get(ctx, "audit:key")
+		return err == nil
+	}, time.Second, time.Millisecond)
+
+	unsubscribe()
+	unsubscribe()
+
+	require.NoError(t, mcache.Delete(ctx, "audit:key"))
+	time.Sleep(10 * time.Millisecond)
+
+	assert.Equal(t, 1, log.len(), "no events after unsubscribing")
+}
+
+func TestShardedEvents(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	sharded := cache.NewSharded(4, time.Minute, Logger(t))
+
+	var log eventLog
+
+	sharded.Subscribe(log.add)
+
+	for i := range 100 {
+		require.NoError(t, sharded.Set(ctx, fmt.Sprintf("k-%d", i), i, 0))
+	}
+
+	require.NoError(t, sharded.Close(ctx))
+	assert.Equal(t, 100, log.len())
+
+	sharded.Subscribe(log.add)()
+}
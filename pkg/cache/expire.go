@@ -0,0 +1,138 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// SetSliding stores key/value with a sliding expiration: like Set, the entry
+// expires ttl from now, but every successful Get extends its expiry to ttl
+// from the time of the read. Other reads, such as Digest and iteration, do
+// not. If ttl <= 0, the entry does not expire and SetSliding behaves like
+// Set.
+//
+// Each extension briefly takes the write lock, so reads of sliding entries
+// contend with writes. Extensions are not written to the write log of a
+// cache opened with Open; see Open for how sliding entries are replayed.
+func (mc *MemCache) SetSliding(_ context.Context, key string, value any, ttl time.Duration) error {
+	val := newEntry(value, ttl, mc.now())
+	val.sliding = true
+
+	return mc.set(key, val)
+}
+
+// TTL returns the time left until key expires, or 0 if it does not expire.
+// If key is missing or expired, TTL returns ErrNotFound.
+func (mc *MemCache) TTL(_ context.Context, key string) (time.Duration, error) {
+	mc.mx.RLock()
+	defer mc.mx.RUnlock()
+
+	now := mc.now()
+
+	val, ok := mc.items[key]
+	if !ok || val.IsExpired(now) {
+		return 0, ErrNotFound
+	}
+
+	if val.expiresAt.IsZero() {
+		return 0, nil
+	}
+
+	return max(val.expiresAt.Sub(now), time.Nanosecond), nil
+}
+
+// Expire makes key expire ttl from now, keeping its value. If ttl <= 0, the
+// entry no longer expires. The new ttl also becomes the window of Touch and,
+// for entries written with SetSliding, of later reads.
+//
+// If key is missing or expired, Expire returns ErrNotFound.
+func (mc *MemCache) Expire(_ context.Context, key string, ttl time.Duration) error {
+	return mc.updateExpiry(key, func(val *entry) {
+		val.expireIn(ttl, mc.now())
+	})
+}
+
+// Persist removes the expiry of key, like Expire with a ttl <= 0. If key is
+// missing or expired, Persist returns ErrNotFound.
+func (mc *MemCache) Persist(ctx context.Context, key string) error {
+	return mc.Expire(ctx, key, 0)
+}
+
+// Touch marks key as used without reading it: the cache Policy records an
+// access, and an entry with a TTL expires that TTL from now again, as if it
+// had just been written. The TTL is the one the entry was written with or
+// last given by Expire.
+//
+// If key is missing or expired, Touch returns ErrNotFound.
+func (mc *MemCache) Touch(_ context.Context, key string) error {
+	if mc.policy != nil {
+		mc.policy.Access(key)
+	}
+
+	return mc.updateExpiry(key, func(val *entry) {
+		val.expireIn(val.ttl, mc.now())
+	})
+}
+
+// updateExpiry applies update to the live entry of key in place. The value,
+// size and tags of the entry must not change.
+func (mc *MemCache) updateExpiry(key string, update func(val *entry)) error {
+	mc.mx.Lock()
+
+	val, ok := mc.liveLocked(key)
+	if !ok {
+		mc.mx.Unlock()
+		return ErrNotFound
+	}
+
+	update(&val)
+	mc.items[key] = val
+	mc.expiry.schedule(key, val.expiresAt)
+	mc.logExpire(key, val)
+
+	mc.mx.Unlock()
+
+	if mc.wlog != nil {
+		return mc.wlog.commit()
+	}
+
+	return nil
+}
+
+// slide extends the expiry of the sliding entry of key after a read.
+func (mc *MemCache) slide(key string) {
+	mc.mx.Lock()
+	defer mc.mx.Unlock()
+
+	mc.slideLocked(key)
+}
+
+// slideLocked extends the expiry of the sliding entry of key. An entry that
+// expired or was overwritten by a non-sliding one since it was read is left
+// alone. The extension is not logged. The caller must hold the write lock.
+func (mc *MemCache) slideLocked(key string) {
+	now := mc.now()
+
+	val, ok := mc.items[key]
+	if !ok || !val.sliding || val.IsExpired(now) {
+		return
+	}
+
+	val.expireIn(val.ttl, now)
+	mc.items[key] = val
+	mc.expiry.schedule(key, val.expiresAt)
+}
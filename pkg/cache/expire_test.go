@@ -0,0 +1,282 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/cachetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemCacheTTL(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	mcache := newMemCache(t, cache.WithClock(clock))
+
+	require.NoError(t, mcache.Set(ctx, "forever", "v", 0))
+	require.NoError(t, mcache.Set(ctx, "hour", "v", time.Hour))
+	require.NoError(t, mcache.Set(ctx, "expired", "v", time.Second))
+	clock.Advance(time.Minute)
+
+	ttl, err := mcache.TTL(ctx, "forever")
+	require.NoError(t, err)
+	assert.Zero(t, ttl)
+
+	ttl, err = mcache.TTL(ctx, "hour")
+	require.NoError(t, err)
+	assert.Equal(t, 59*time.Minute, ttl)
+
+	for _, key := range []string{"expired", "missing"} {
+		_, err = mcache.TTL(ctx, key)
+		require.ErrorIs(t, err, cache.ErrNotFound, key)
+	}
+}
+
+func TestMemCacheExpire(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	mcache := newMemCache(t, cache.WithClock(clock))
+
+	require.NoError(t, mcache.SetWithTags(ctx, "key", "value", 0, "tag"))
+	require.NoError(t, mcache.Expire(ctx, "key", 20*time.Millisecond))
+
+	ttl, err := mcache.TTL(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, 20*time.Millisecond, ttl)
+
+	require.NoError(t, mcache.Persist(ctx, "key"))
+	clock.Advance(30 * time.Millisecond)
+
+	value, err := mcache.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	require.NoError(t, mcache.Expire(ctx, "key", time.Nanosecond))
+	clock.Advance(time.Millisecond)
+
+	_, err = mcache.Get(ctx, "key")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	for _, update := range []func() error{
+		func() error { return mcache.Expire(ctx, "key", time.Hour) },
+		func() error { return mcache.Persist(ctx, "missing") },
+		func() error { return mcache.Touch(ctx, "missing") },
+	} {
+		require.ErrorIs(t, update(), cache.ErrNotFound)
+	}
+
+	require.NoError(t, mcache.InvalidateTags(ctx, "tag"))
+	assert.Zero(t, mcache.Size())
+}
+
+func TestMemCacheTouch(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	mcache := newMemCache(t, cache.WithClock(clock))
+
+	require.NoError(t, mcache.Set(ctx, "key", "value", time.Minute))
+
+	for range 4 {
+		clock.Advance(40 * time.Second)
+		require.NoError(t, mcache.Touch(ctx, "key"))
+	}
+
+	ttl, err := mcache.TTL(ctx, "key")
+	require.NoError(t, err, "touched entries outlive their first deadline")
+	assert.Equal(t, time.Minute, ttl)
+
+	clock.Advance(time.Minute + time.Nanosecond)
+
+	_, err = mcache.Get(ctx, "key")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	require.NoError(t, mcache.Set(ctx, "key", "value", time.Minute))
+
+	require.NoError(t, mcache.Expire(ctx, "key", time.Hour))
+	require.NoError(t, mcache.Touch(ctx, "key"))
+
+	ttl, err = mcache.TTL(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, ttl, "Touch uses the ttl set by Expire")
+}
+
+func TestMemCacheTouchPolicy(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := newMemCache(t, cache.WithMaxEntries(2))
+
+	require.NoError(t, mcache.Set(ctx, "a", 1, 0))
+	require.NoError(t, mcache.Set(ctx, "b", 2, 0))
+	require.NoError(t, mcache.Touch(ctx, "a"))
+	require.NoError(t, mcache.Set(ctx, "c", 3, 0))
+
+	_, err := mcache.Get(ctx, "a")
+	require.NoError(t, err)
+
+	_, err = mcache.Get(ctx, "b")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+}
+
+func TestMemCacheSliding(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	mcache := cache.WithDeleteInterval(time.Second, Logger(t), cache.WithClock(clock))
+
+	t.Cleanup(func() {
+		require.NoError(t, mcache.Close(ctx))
+	})
+
+	require.NoError(t, mcache.SetSliding(ctx, "session", "user", time.Minute))
+	require.NoError(t, mcache.Set(ctx, "fixed", "user", 30*time.Second))
+
+	for range 5 {
+		clock.Advance(20 * time.Second)
+
+		value, err := mcache.Get(ctx, "session")
+		require.NoError(t, err, "reads keep sliding entries alive past the cleaner")
+		assert.Equal(t, "user", value)
+
+		_, _ = mcache.Get(ctx, "fixed")
+	}
+
+	_, err := mcache.Get(ctx, "fixed")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	assert.Positive(t, mcache.Digest(ctx, "session"))
+
+	clock.Advance(time.Minute + time.Nanosecond)
+
+	require.Eventually(t, func() bool {
+		return mcache.Size() == 0
+	}, time.Second, time.Millisecond, "idle sliding entries expire")
+
+	_, err = mcache.Get(ctx, "session")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+}
+
+func TestMemCacheSlidingOverwrite(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := newMemCache(t)
+
+	require.NoError(t, mcache.SetSliding(ctx, "key", "v1", time.Hour))
+	require.NoError(t, mcache.Set(ctx, "key", "v2", 50*time.Millisecond))
+
+	_, err := mcache.Get(ctx, "key")
+	require.NoError(t, err)
+
+	ttl, err := mcache.TTL(ctx, "key")
+	require.NoError(t, err)
+	assert.LessOrEqual(t, ttl, 50*time.Millisecond, "Set ends sliding expiration")
+}
+
+func TestMemCacheSlidingNotLogged(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	path := filepath.Join(t.TempDir(), "cache.log")
+	clock := cachetest.NewClock(time.Now())
+	first := openLogged(t, path, cache.WithClock(clock), cache.WithFsync(cache.FsyncAlways))
+
+	require.NoError(t, first.SetSliding(ctx, "session", "user", time.Hour))
+
+	before, err := os.Stat(path)
+	require.NoError(t, err)
+
+	for range 10 {
+		clock.Advance(3 * time.Minute)
+
+		_, err := first.Get(ctx, "session")
+		require.NoError(t, err)
+	}
+
+	after, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, before.Size(), after.Size(), "reads do not grow the log")
+	require.NoError(t, first.Close(ctx))
+
+	// 50 minutes after the write, the logged expiry has not passed yet.
+	clock.Advance(20 * time.Minute)
+
+	second := openLogged(t, path, cache.WithClock(clock))
+
+	ttl, err := second.TTL(ctx, "session")
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, ttl, "replayed sliding entries get a full window")
+}
+
+func TestMemCacheExpirePersistence(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	path := filepath.Join(t.TempDir(), "cache.log")
+	clock := cachetest.NewClock(time.Now())
+	first := openLogged(t, path, cache.WithClock(clock))
+
+	require.NoError(t, first.SetSliding(ctx, "session", "user", time.Hour))
+	require.NoError(t, first.SetWithTags(ctx, "tagged", "v", 0, "tag"))
+	require.NoError(t, first.Expire(ctx, "tagged", 2*time.Hour))
+	require.NoError(t, first.Set(ctx, "short", "v", time.Hour))
+	require.NoError(t, first.Expire(ctx, "short", 20*time.Millisecond))
+
+	var snapshot bytes.Buffer
+
+	require.NoError(t, first.Snapshot(ctx, &snapshot))
+	require.NoError(t, first.Close(ctx))
+
+	second := openLogged(t, path, cache.WithClock(clock))
+	restored := newMemCache(t, cache.WithClock(clock))
+
+	require.NoError(t, restored.Restore(ctx, &snapshot))
+
+	for name, mcache := range map[string]*cache.MemCache{"write log": second, "snapshot": restored} {
+		ttl, err := mcache.TTL(ctx, "tagged")
+		require.NoError(t, err, name)
+		assert.Greater(t, ttl, time.Hour, name)
+
+		require.NoError(t, mcache.Expire(ctx, "session", 50*time.Millisecond), name)
+		clock.Advance(30 * time.Millisecond)
+
+		_, err = mcache.Get(ctx, "session")
+		require.NoError(t, err, name)
+
+		ttl, err = mcache.TTL(ctx, "session")
+		require.NoError(t, err, name)
+		assert.Equal(t, 50*time.Millisecond, ttl, "%s: entries keep sliding", name)
+
+		_, err = mcache.Get(ctx, "short")
+		require.ErrorIs(t, err, cache.ErrNotFound, name)
+
+		require.NoError(t, mcache.InvalidateTags(ctx, "tag"), name)
+		assert.Equal(t, 1, mcache.Size(), name)
+	}
+}
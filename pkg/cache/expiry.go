@@ -0,0 +1,148 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"container/heap"
+	"time"
+)
+
+// cleanupBatchSize is the number of expired entries the background cleaner
+// removes per write lock acquisition, so that readers are not blocked for a
+// whole run.
+const cleanupBatchSize = 256
+
+// expiryQueue indexes the keys of entries with an expiry by their expiresAt
+// in a min-heap, so that the background cleaner finds expired entries in
+// O(log n) each instead of scanning the whole cache. It is guarded by the
+// write lock of the cache.
+type expiryQueue struct {
+	heap []*expiryItem
+	keys map[string]*expiryItem
+}
+
+type expiryItem struct {
+	key       string
+	expiresAt int64 // Unix nanoseconds
+	index     int   // position in the heap
+}
+
+func newExpiryQueue() expiryQueue {
+	return expiryQueue{heap: nil, keys: make(map[string]*expiryItem)}
+}
+
+// schedule makes key expire at expiresAt, replacing its previous expiry. A
+// zero expiresAt unschedules key.
+func (q *expiryQueue) schedule(key string, expiresAt time.Time) {
+	if expiresAt.IsZero() {
+		q.unschedule(key)
+		return
+	}
+
+	if item, ok := q.keys[key]; ok {
+		item.expiresAt = expiresAt.UnixNano()
+		heap.Fix(q, item.index)
+
+		return
+	}
+
+	item := &expiryItem{key: key, expiresAt: expiresAt.UnixNano(), index: 0}
+	q.keys[key] = item
+	heap.Push(q, item)
+}
+
+// unschedule removes key from the queue, if it is scheduled.
+func (q *expiryQueue) unschedule(key string) {
+	if item, ok := q.keys[key]; ok {
+		heap.Remove(q, item.index)
+		delete(q.keys, key)
+	}
+}
+
+// popExpired removes and returns the key expiring first if it is expired at
+// now, as entry.IsExpired decides.
+func (q *expiryQueue) popExpired(now time.Time) (string, bool) {
+	if len(q.heap) == 0 || q.heap[0].expiresAt >= now.UnixNano() {
+		return "", false
+	}
+
+	item, _ := heap.Pop(q).(*expiryItem)
+	delete(q.keys, item.key)
+
+	return item.key, true
+}
+
+func (q *expiryQueue) Len() int {
+	return len(q.heap)
+}
+
+func (q *expiryQueue) Less(i, j int) bool {
+	return q.heap[i].expiresAt < q.heap[j].expiresAt
+}
+
+func (q *expiryQueue) Swap(i, j int) {
+	q.heap[i], q.heap[j] = q.heap[j], q.heap[i]
+	q.heap[i].index = i
+	q.heap[j].index = j
+}
+
+func (q *expiryQueue) Push(x any) {
+	item, _ := x.(*expiryItem)
+	item.index = len(q.heap)
+	q.heap = append(q.heap, item)
+}
+
+func (q *expiryQueue) Pop() any {
+	last := len(q.heap) - 1
+	item := q.heap[last]
+	q.heap[last] = nil
+	q.heap = q.heap[:last]
+
+	return item
+}
+
+// cleanExpired removes up to the cleanup budget entries expired at now,
+// earliest first, in batches of cleanupBatchSize under the write lock, and
+// returns how many it removed.
+func (mc *MemCache) cleanExpired(now time.Time) uint64 {
+	deleted := 0
+
+	for deleted < mc.cleanupBudget {
+		batch := min(cleanupBatchSize, mc.cleanupBudget-deleted)
+		removed := 0
+
+		mc.mx.Lock()
+
+		for removed < batch {
+			key, ok := mc.expiry.popExpired(now)
+			if !ok {
+				break
+			}
+
+			mc.removeLocked(key, ReasonCleaned)
+			removed++
+		}
+
+		mc.mx.Unlock()
+
+		deleted += removed
+
+		if removed < batch {
+			break
+		}
+	}
+
+	return uint64(deleted) //nolint:gosec // not negative
+}
@@ -0,0 +1,213 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/cachetest"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitCleanupRuns waits until the background cleaner of mcache has run runs
+// times.
+func waitCleanupRuns(t *testing.T, mcache *cache.MemCache, runs uint64) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		return mcache.Metrics().CleanupRuns == runs
+	}, time.Second, time.Millisecond)
+}
+
+func TestMemCacheCleanerEarliestFirst(t *testing.T) {
+	t.Parallel()
+
+	const (
+		expiring = 1000
+		budget   = 300
+	)
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	mcache := cache.WithDeleteInterval(time.Hour, Logger(t),
+		cache.WithClock(clock),
+		cache.WithCleanupBudget(budget),
+		cache.WithEventQueueSize(expiring),
+	)
+
+	t.Cleanup(func() {
+		require.NoError(t, mcache.Close(ctx))
+	})
+
+	var (
+		mx      sync.Mutex
+		cleaned []string
+	)
+
+	mcache.Subscribe(func(event cache.Event) {
+		if event.Reason == cache.ReasonCleaned {
+			mx.Lock()
+			cleaned = append(cleaned, event.Key)
+			mx.Unlock()
+		}
+	})
+
+	for _, i := range rand.Perm(expiring) {
+		require.NoError(t, mcache.Set(ctx, fmt.Sprintf("k%04d", i), i, time.Duration(i+1)*time.Second))
+	}
+
+	for i := range 100 {
+		require.NoError(t, mcache.Set(ctx, fmt.Sprintf("forever-%d", i), i, 0))
+		require.NoError(t, mcache.Set(ctx, fmt.Sprintf("long-%d", i), i, 24*time.Hour))
+	}
+
+	clock.Advance(time.Hour)
+	waitCleanupRuns(t, mcache, 1)
+
+	metrics := mcache.Metrics()
+	assert.Equal(t, uint64(budget), metrics.ScheduledEvictions)
+	assert.Equal(t, uint64(budget), metrics.LastCleanupItems)
+
+	expected := make([]string, budget)
+	for i := range expected {
+		expected[i] = fmt.Sprintf("k%04d", i)
+	}
+
+	require.Eventually(t, func() bool {
+		mx.Lock()
+		defer mx.Unlock()
+
+		return len(cleaned) == budget
+	}, time.Second, time.Millisecond)
+
+	mx.Lock()
+	assert.Equal(t, expected, cleaned, "the cleaner removes the earliest expiries first")
+	mx.Unlock()
+
+	for run := uint64(2); run <= 5; run++ {
+		clock.Advance(time.Hour)
+		waitCleanupRuns(t, mcache, run)
+	}
+
+	metrics = mcache.Metrics()
+	assert.Equal(t, uint64(expiring), metrics.ScheduledEvictions)
+	assert.Zero(t, metrics.LastCleanupItems)
+	assert.Equal(t, 200, mcache.Size())
+}
+
+func TestMemCacheCleanerExpiryChanges(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	mcache := cache.WithDeleteInterval(time.Minute, Logger(t), cache.WithClock(clock))
+
+	t.Cleanup(func() {
+		require.NoError(t, mcache.Close(ctx))
+	})
+
+	for _, key := range []string{"plain", "persisted", "extended", "touched", "overwritten", "deleted"} {
+		require.NoError(t, mcache.Set(ctx, key, key, 50*time.Second))
+	}
+
+	require.NoError(t, mcache.SetSliding(ctx, "sliding", "sliding", 50*time.Second))
+	require.NoError(t, mcache.Set(ctx, "shortened", "shortened", 0))
+
+	require.NoError(t, mcache.Persist(ctx, "persisted"))
+	require.NoError(t, mcache.Expire(ctx, "extended", 3*time.Minute))
+	require.NoError(t, mcache.Expire(ctx, "shortened", 10*time.Second))
+	require.NoError(t, mcache.Set(ctx, "overwritten", "overwritten", 0))
+	require.NoError(t, mcache.Delete(ctx, "deleted"))
+
+	clock.Advance(20 * time.Second)
+	require.NoError(t, mcache.Touch(ctx, "touched"))
+
+	_, err := mcache.Get(ctx, "sliding")
+	require.NoError(t, err)
+
+	// At 1m: plain and shortened expired, touched and sliding expire at 1m10s.
+	clock.Advance(40 * time.Second)
+	waitCleanupRuns(t, mcache, 1)
+	assert.Equal(t, uint64(2), mcache.Metrics().ScheduledEvictions)
+	assert.Equal(t, 5, mcache.Size())
+
+	// At 2m: touched and sliding expired too.
+	clock.Advance(time.Minute)
+	waitCleanupRuns(t, mcache, 2)
+	assert.Equal(t, uint64(4), mcache.Metrics().ScheduledEvictions)
+
+	for _, key := range []string{"persisted", "extended", "overwritten"} {
+		value, err := mcache.Get(ctx, key)
+		require.NoError(t, err, key)
+		assert.Equal(t, key, value)
+	}
+
+	assert.Equal(t, 3, mcache.Size())
+}
+
+// BenchmarkMemCacheCleanup measures cleanup passes removing a hundred
+// expired entries from caches of growing numbers of live entries, with the
+// expiry heap used by the background cleaner and with a full scan as a
+// baseline.
+func BenchmarkMemCacheCleanup(b *testing.B) {
+	const expiredPerRun = 100
+
+	cleanups := []struct {
+		name    string
+		cleanup func(mcache *cache.MemCache) uint64
+	}{
+		{name: "heap", cleanup: (*cache.MemCache).CleanExpired},
+		{name: "scan", cleanup: (*cache.MemCache).CleanExpiredByScan},
+	}
+
+	for _, live := range []int{10_000, 100_000, 1_000_000} {
+		for _, cleanup := range cleanups {
+			b.Run(fmt.Sprintf("%s/live=%d", cleanup.name, live), func(b *testing.B) {
+				ctx := b.Context()
+				clock := cachetest.NewClock(time.Now())
+				// The background cleaner never runs: the clock does not reach
+				// its first tick.
+				mcache := cache.WithDeleteInterval(1000*24*time.Hour, zerolog.Nop(), cache.WithClock(clock))
+
+				b.Cleanup(func() {
+					_ = mcache.Close(ctx)
+				})
+
+				for i := range live {
+					_ = mcache.Set(ctx, fmt.Sprintf("live-%d", i), i, 100*365*24*time.Hour)
+				}
+
+				for b.Loop() {
+					for i := range expiredPerRun {
+						_ = mcache.Set(ctx, fmt.Sprintf("expired-%d", i), i, time.Second)
+					}
+
+					clock.Advance(time.Minute)
+
+					if removed := cleanup.cleanup(mcache); removed != expiredPerRun {
+						b.Fatalf("removed %d entries, want %d", removed, expiredPerRun)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+// CleanExpired runs one background cleanup pass at the current time of the
+// cache Clock and returns the number of entries removed.
+func (mc *MemCache) CleanExpired() uint64 {
+	return mc.cleanExpired(mc.now())
+}
+
+// CleanExpiredByScan removes expired entries the way the background cleaner
+// did before expiring entries were indexed in a heap: it scans the whole
+// cache under the read lock for up to the cleanup budget expired keys, then
+// removes them one write lock at a time. It is the baseline of
+// BenchmarkMemCacheCleanup.
+func (mc *MemCache) CleanExpiredByScan() uint64 {
+	now := mc.now()
+
+	mc.mx.RLock()
+
+	keys := make([]string, 0, mc.cleanupBudget)
+	for key, val := range mc.items {
+		if len(keys) >= mc.cleanupBudget {
+			break
+		}
+
+		if val.IsExpired(now) {
+			keys = append(keys, key)
+		}
+	}
+
+	mc.mx.RUnlock()
+
+	deleted := uint64(0)
+
+	for _, key := range keys {
+		if mc.invalidated(key, ReasonCleaned) {
+			deleted++
+		}
+	}
+
+	return deleted
+}
@@ -0,0 +1,169 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import "unicode/utf8"
+
+// matchGlob reports whether key matches the glob pattern. The pattern must
+// have been checked with validGlob.
+func matchGlob(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+
+			if len(pattern) == 0 {
+				return true
+			}
+
+			for i := 0; i <= len(key); {
+				if matchGlob(pattern, key[i:]) {
+					return true
+				}
+
+				if i == len(key) {
+					break
+				}
+
+				_, size := utf8.DecodeRuneInString(key[i:])
+				i += size
+			}
+
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+
+			_, size := utf8.DecodeRuneInString(key)
+			pattern, key = pattern[1:], key[size:]
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+
+			char, size := utf8.DecodeRuneInString(key)
+
+			matched, rest := matchClass(pattern[1:], char)
+			if !matched {
+				return false
+			}
+
+			pattern, key = rest, key[size:]
+		default:
+			if pattern[0] == '\\' {
+				pattern = pattern[1:]
+			}
+
+			want, wantSize := utf8.DecodeRuneInString(pattern)
+			got, gotSize := utf8.DecodeRuneInString(key)
+
+			if len(key) == 0 || want != got {
+				return false
+			}
+
+			pattern, key = pattern[wantSize:], key[gotSize:]
+		}
+	}
+
+	return len(key) == 0
+}
+
+// matchClass matches char against the character class at the start of
+// pattern, just after '[', and returns the pattern after the closing ']'.
+func matchClass(pattern string, char rune) (bool, string) {
+	negated := false
+	if len(pattern) > 0 && (pattern[0] == '^' || pattern[0] == '!') {
+		negated = true
+		pattern = pattern[1:]
+	}
+
+	matched := false
+
+	for first := true; ; first = false {
+		if pattern[0] == ']' && !first {
+			return matched != negated, pattern[1:]
+		}
+
+		low, rest := classChar(pattern)
+		high := low
+
+		if len(rest) > 1 && rest[0] == '-' && rest[1] != ']' {
+			high, rest = classChar(rest[1:])
+		}
+
+		if low <= char && char <= high {
+			matched = true
+		}
+
+		pattern = rest
+	}
+}
+
+// classChar decodes one, possibly escaped, character of a class.
+func classChar(pattern string) (rune, string) {
+	if pattern[0] == '\\' {
+		pattern = pattern[1:]
+	}
+
+	char, size := utf8.DecodeRuneInString(pattern)
+
+	return char, pattern[size:]
+}
+
+// validGlob returns ErrBadPattern if pattern has an unterminated character
+// class or ends with an unfinished escape.
+func validGlob(pattern string) error {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+			if i == len(pattern) {
+				return ErrBadPattern
+			}
+		case '[':
+			end, ok := classEnd(pattern[i+1:])
+			if !ok {
+				return ErrBadPattern
+			}
+
+			i += end + 1
+		}
+	}
+
+	return nil
+}
+
+// classEnd returns the index of the ']' closing the class at the start of
+// pattern, just after '['.
+func classEnd(pattern string) (int, bool) {
+	i := 0
+	if i < len(pattern) && (pattern[i] == '^' || pattern[i] == '!') {
+		i++
+	}
+
+	for first := true; i < len(pattern); i, first = i+1, false {
+		switch {
+		case pattern[i] == ']' && !first:
+			return i, true
+		case pattern[i] == '\\':
+			i++
+		}
+	}
+
+	return 0, false
+}
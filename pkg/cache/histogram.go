@@ -0,0 +1,154 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"math"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBounds are the inclusive upper bounds of the Histogram buckets.
+var latencyBounds = [...]time.Duration{
+	time.Microsecond,
+	5 * time.Microsecond,
+	10 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// LatencyBuckets is the number of Histogram buckets: one per bound returned
+// by LatencyBounds and a last one for slower observations.
+const LatencyBuckets = len(latencyBounds) + 1
+
+// LatencyBounds returns the inclusive upper bounds of the Histogram buckets.
+func LatencyBounds() []time.Duration {
+	return slices.Clone(latencyBounds[:])
+}
+
+// Histogram counts operation latencies in fixed buckets.
+//
+// Buckets are not cumulative: Buckets[i] counts observations above bound i-1
+// and up to bound i of LatencyBounds, the last bucket counts observations
+// above the largest bound. All fields are updated atomically.
+type Histogram struct {
+	Count   uint64                 `json:"count"`
+	SumNs   uint64                 `json:"sum_ns"`  // Sum of all observations in nanoseconds
+	Buckets [LatencyBuckets]uint64 `json:"buckets"` // Observations per bucket
+}
+
+// Observe records one operation that took d.
+func (h *Histogram) Observe(d time.Duration) {
+	d = max(d, 0)
+
+	i := 0
+	for i < len(latencyBounds) && d > latencyBounds[i] {
+		i++
+	}
+
+	atomic.AddUint64(&h.Buckets[i], 1)
+	atomic.AddUint64(&h.SumNs, uint64(d)) //nolint:gosec // d is not negative
+	atomic.AddUint64(&h.Count, 1)
+}
+
+// ObserveSince records one operation started at start. It is meant to be
+// deferred as defer h.ObserveSince(time.Now()).
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start))
+}
+
+// Snapshot returns an atomic-load copy of the histogram.
+//
+// Its Count is the sum of the copied buckets rather than a separate load, so
+// that it agrees with them while observations are recorded concurrently, as
+// exporters of cumulative buckets require. SumNs may be slightly ahead of or
+// behind the buckets.
+func (h *Histogram) Snapshot() Histogram {
+	var snapshot Histogram
+
+	snapshot.SumNs = atomic.LoadUint64(&h.SumNs)
+
+	for i := range h.Buckets {
+		snapshot.Buckets[i] = atomic.LoadUint64(&h.Buckets[i])
+		snapshot.Count += snapshot.Buckets[i]
+	}
+
+	return snapshot
+}
+
+// Mean returns the average observed latency.
+func (h *Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+
+	return time.Duration(h.SumNs / h.Count) //nolint:gosec // a mean never exceeds the largest observation
+}
+
+// Quantile returns an upper estimate of the q-quantile (0 < q <= 1) of the
+// observed latencies: the upper bound of the bucket holding it. Latencies
+// above the largest bound are reported as that bound.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+
+	rank := max(uint64(math.Ceil(q*float64(h.Count))), 1)
+	seen := uint64(0)
+
+	for i, bound := range latencyBounds {
+		seen += h.Buckets[i]
+		if seen >= rank {
+			return bound
+		}
+	}
+
+	return latencyBounds[len(latencyBounds)-1]
+}
+
+// reset zeroes the histogram and returns its previous contents, with Count
+// computed from the buckets as by Snapshot.
+func (h *Histogram) reset() Histogram {
+	var previous Histogram
+
+	atomic.StoreUint64(&h.Count, 0)
+	previous.SumNs = atomic.SwapUint64(&h.SumNs, 0)
+
+	for i := range h.Buckets {
+		previous.Buckets[i] = atomic.SwapUint64(&h.Buckets[i], 0)
+		previous.Count += previous.Buckets[i]
+	}
+
+	return previous
+}
+
+// merge accumulates other into h. h must not be shared.
+func (h *Histogram) merge(other Histogram) {
+	h.Count += other.Count
+	h.SumNs += other.SumNs
+
+	for i := range h.Buckets {
+		h.Buckets[i] += other.Buckets[i]
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram(t *testing.T) {
+	t.Parallel()
+
+	var hist cache.Histogram
+
+	assert.Zero(t, hist.Mean())
+	assert.Zero(t, hist.Quantile(0.99))
+
+	hist.Observe(-time.Second) // counted as zero
+	hist.Observe(time.Microsecond)
+	hist.Observe(3 * time.Microsecond)
+	hist.Observe(2 * time.Millisecond)
+	hist.Observe(time.Minute)
+
+	snps := hist.Snapshot()
+	bounds := cache.LatencyBounds()
+
+	assert.Len(t, bounds, cache.LatencyBuckets-1)
+	assert.Equal(t, uint64(5), snps.Count)
+	assert.Equal(t, uint64(time.Minute+2*time.Millisecond+4*time.Microsecond), snps.SumNs)
+	assert.Equal(t, uint64(2), snps.Buckets[0], "bounds are inclusive")
+	assert.Equal(t, uint64(1), snps.Buckets[1])
+	assert.Equal(t, uint64(1), snps.Buckets[7])
+	assert.Equal(t, uint64(1), snps.Buckets[cache.LatencyBuckets-1])
+
+	assert.Equal(t, time.Microsecond, snps.Quantile(0.4))
+	assert.Equal(t, 5*time.Microsecond, snps.Quantile(0.5))
+	assert.Equal(t, 5*time.Millisecond, snps.Quantile(0.8))
+	assert.Equal(t, time.Second, snps.Quantile(1), "slower observations report the largest bound")
+	assert.Equal(t, (time.Minute+2*time.Millisecond+4*time.Microsecond)/5, snps.Mean())
+
+	bounds[0] = time.Hour
+	assert.Equal(t, time.Microsecond, cache.LatencyBounds()[0], "bounds are copied")
+}
+
+func TestHistogramConcurrentSnapshot(t *testing.T) {
+	t.Parallel()
+
+	var (
+		hist cache.Histogram
+		wg   sync.WaitGroup
+	)
+
+	done := make(chan struct{})
+
+	for range 4 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+					hist.Observe(time.Duration(i%2000) * time.Millisecond)
+				}
+			}
+		}()
+	}
+
+	for range 1000 {
+		snps := hist.Snapshot()
+
+		var cumulative uint64
+		for _, count := range snps.Buckets {
+			cumulative += count
+		}
+
+		if !assert.Equal(t, cumulative, snps.Count, "count, exported as the +Inf bucket, agrees with the buckets") {
+			break
+		}
+	}
+
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,154 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"iter"
+	"slices"
+	"strings"
+)
+
+const (
+	// iterBatchSize is the number of entries looked up per read lock while
+	// iterating.
+	iterBatchSize = 256
+	// deleteBatchSize is the number of keys removed per write lock by
+	// DeletePrefix and DeleteMatch.
+	deleteBatchSize = 1024
+)
+
+// All returns an iterator over the keys and values of live entries.
+//
+// The key set is copied under a read lock when iteration starts; values are
+// then looked up in small batches, each under a short read lock, and yielded
+// without holding any lock, so the loop body may use the cache. Entries
+// removed or expired before they are reached are skipped, entries added after
+// iteration started are not visited, and an overwritten entry yields its
+// newest value. The order is unspecified. Expired entries are not evicted.
+func (mc *MemCache) All() iter.Seq2[string, any] {
+	return mc.scan(func(string) bool { return true })
+}
+
+// Keys returns an iterator over the keys of live entries. It has the same
+// consistency guarantees as All.
+func (mc *MemCache) Keys() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for key := range mc.All() {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// ScanPrefix returns an iterator over the keys and values of live entries
+// whose key starts with prefix. It has the same consistency guarantees as All.
+func (mc *MemCache) ScanPrefix(prefix string) iter.Seq2[string, any] {
+	return mc.scan(func(key string) bool { return strings.HasPrefix(key, prefix) })
+}
+
+func (mc *MemCache) scan(match func(key string) bool) iter.Seq2[string, any] {
+	return func(yield func(string, any) bool) {
+		keys := mc.matchKeys(match)
+		batch := make([]entry, 0, iterBatchSize)
+		found := make([]bool, 0, iterBatchSize)
+
+		for chunk := range slices.Chunk(keys, iterBatchSize) {
+			batch, found = batch[:0], found[:0]
+
+			mc.mx.RLock()
+			for _, key := range chunk {
+				val, ok := mc.items[key]
+				batch = append(batch, val)
+				found = append(found, ok)
+			}
+			mc.mx.RUnlock()
+
+			now := mc.now()
+
+			for i, val := range batch {
+				if !found[i] || val.IsExpired(now) {
+					continue
+				}
+
+				if !yield(chunk[i], val.value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// matchKeys returns the keys accepted by match, copied under a read lock.
+func (mc *MemCache) matchKeys(match func(key string) bool) []string {
+	mc.mx.RLock()
+	defer mc.mx.RUnlock()
+
+	keys := make([]string, 0, len(mc.items))
+
+	for key := range mc.items {
+		if match(key) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// DeletePrefix removes every entry whose key starts with prefix.
+//
+// Matching keys are collected under a read lock and removed in batches, each
+// under one write lock, so other operations interleave with a large delete.
+// Entries added after the keys were collected are not removed.
+//
+// The operation can be cancelled via the context. If cancelled, DeletePrefix
+// returns ErrAborted; keys deleted before the cancellation stay deleted.
+func (mc *MemCache) DeletePrefix(ctx context.Context, prefix string) error {
+	return mc.deleteMatching(ctx, func(key string) bool { return strings.HasPrefix(key, prefix) })
+}
+
+// DeleteMatch removes every entry whose key matches the glob pattern, with the
+// same batching and cancellation semantics as DeletePrefix.
+//
+// The pattern syntax is that of Redis KEYS: '*' matches any sequence of
+// characters, '?' any single character, '[abc]', '[a-z]' and '[^a]' match
+// character classes, and '\' escapes the next character. Unlike path.Match,
+// '*' also matches '/'. A malformed pattern returns ErrBadPattern and deletes
+// nothing.
+func (mc *MemCache) DeleteMatch(ctx context.Context, pattern string) error {
+	if err := validGlob(pattern); err != nil {
+		return err
+	}
+
+	return mc.deleteMatching(ctx, func(key string) bool { return matchGlob(pattern, key) })
+}
+
+func (mc *MemCache) deleteMatching(ctx context.Context, match func(key string) bool) error {
+	select {
+	case <-ctx.Done():
+		mc.log.Error().Err(ctx.Err()).Msg("delete matching keys aborted")
+		return ErrAborted
+	default:
+	}
+
+	for chunk := range slices.Chunk(mc.matchKeys(match), deleteBatchSize) {
+		if err := mc.Delete(ctx, chunk...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/cachetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemCacheIterators(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	mcache := newMemCache(t, cache.WithClock(clock))
+
+	want := make(map[string]any)
+
+	for i := range 1000 {
+		key := fmt.Sprintf("user:%d", i)
+		if i%2 == 1 {
+			key = fmt.Sprintf("order:%d", i)
+		}
+
+		require.NoError(t, mcache.Set(ctx, key, i, 0))
+
+		want[key] = i
+	}
+
+	require.NoError(t, mcache.Set(ctx, "user:expired", "v", time.Nanosecond))
+	require.NoError(t, mcache.Set(ctx, "nil", nil, 0))
+
+	want["nil"] = nil
+
+	clock.Advance(time.Millisecond)
+
+	assert.Equal(t, want, maps.Collect(mcache.All()))
+	assert.ElementsMatch(t, slices.Collect(maps.Keys(want)), slices.Collect(mcache.Keys()))
+
+	users := maps.Collect(mcache.ScanPrefix("user:"))
+	assert.Len(t, users, 500)
+
+	for key := range users {
+		assert.Contains(t, key, "user:")
+	}
+
+	assert.Equal(t, 1002, mcache.Size(), "iteration does not evict expired entries")
+
+	// Breaking out early stops the iteration.
+	seen := 0
+	for range mcache.All() {
+		seen++
+		if seen == 10 {
+			break
+		}
+	}
+
+	assert.Equal(t, 10, seen)
+}
+
+func TestMemCacheIteratorUsesCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := newMemCache(t)
+
+	for i := range 1000 {
+		require.NoError(t, mcache.Set(ctx, fmt.Sprintf("k-%d", i), i, 0))
+	}
+
+	// The loop body may write to the cache: no lock is held while yielding.
+	visited := 0
+
+	for key := range mcache.Keys() {
+		require.NoError(t, mcache.Delete(ctx, key))
+		require.NoError(t, mcache.Set(ctx, "new-"+key, 0, 0))
+
+		visited++
+	}
+
+	assert.Equal(t, 1000, visited, "keys added during iteration are not visited")
+	assert.Equal(t, 1000, mcache.Size())
+}
+
+func TestMemCacheDeletePrefix(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := newMemCache(t)
+
+	for i := range 3000 {
+		require.NoError(t, mcache.Set(ctx, fmt.Sprintf("a:%d", i), i, 0))
+		require.NoError(t, mcache.Set(ctx, fmt.Sprintf("b:%d", i), i, 0))
+	}
+
+	require.NoError(t, mcache.DeletePrefix(ctx, "a:"))
+
+	assert.Equal(t, 3000, mcache.Size())
+	assert.Equal(t, uint64(3000), mcache.Metrics().Deletes)
+	assert.Empty(t, maps.Collect(mcache.ScanPrefix("a:")))
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	require.ErrorIs(t, mcache.DeletePrefix(cancelled, "b:"), cache.ErrAborted)
+	require.ErrorIs(t, mcache.DeleteMatch(cancelled, "b:*"), cache.ErrAborted)
+	assert.Equal(t, 3000, mcache.Size())
+}
+
+func TestMemCacheDeleteMatch(t *testing.T) {
+	t.Parallel()
+
+	keys := []string{"user:1", "user:22", "user:a/b", "order:1", "uſer:1", "u*", "u?", "[x]", "ab", "a-"}
+
+	tests := []struct {
+		pattern string
+		deleted []string
+	}{
+		{pattern: "*", deleted: keys},
+		{pattern: "user:*", deleted: []string{"user:1", "user:22", "user:a/b"}},
+		{pattern: "user:?", deleted: []string{"user:1"}},
+		{pattern: "u?er:1", deleted: []string{"user:1", "uſer:1"}},
+		{pattern: "*:1", deleted: []string{"user:1", "order:1", "uſer:1"}},
+		{pattern: "user:[0-9]*", deleted: []string{"user:1", "user:22"}},
+		{pattern: "user:[^0-9]*", deleted: []string{"user:a/b"}},
+		{pattern: "user:[!0-9]*", deleted: []string{"user:a/b"}},
+		{pattern: "u\\*", deleted: []string{"u*"}},
+		{pattern: "u\\?", deleted: []string{"u?"}},
+		{pattern: "\\[x]", deleted: []string{"[x]"}},
+		{pattern: "a[-b]", deleted: []string{"ab", "a-"}},
+		{pattern: "a[b-]", deleted: []string{"ab", "a-"}},
+		{pattern: "[]x[]*", deleted: []string{"[x]"}},
+		{pattern: "user:1*2", deleted: nil},
+		{pattern: "nothing", deleted: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			mcache := newMemCache(t)
+
+			for _, key := range keys {
+				require.NoError(t, mcache.Set(ctx, key, key, 0))
+			}
+
+			require.NoError(t, mcache.DeleteMatch(ctx, tt.pattern))
+
+			var deleted []string
+
+			for _, key := range keys {
+				if _, err := mcache.Get(ctx, key); err != nil {
+					deleted = append(deleted, key)
+				}
+			}
+
+			assert.ElementsMatch(t, tt.deleted, deleted)
+		})
+	}
+
+	mcache := newMemCache(t)
+	for _, pattern := range []string{"[abc", "abc\\", "[\\", "[]"} {
+		require.ErrorIs(t, mcache.DeleteMatch(t.Context(), pattern), cache.ErrBadPattern, pattern)
+	}
+}
+
+func TestShardedIterators(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	sharded := cache.NewSharded(4, time.Minute, Logger(t))
+
+	t.Cleanup(func() {
+		require.NoError(t, sharded.Close(t.Context()))
+	})
+
+	for i := range 100 {
+		require.NoError(t, sharded.Set(ctx, fmt.Sprintf("k-%d", i), i, 0))
+		require.NoError(t, sharded.Set(ctx, fmt.Sprintf("x-%d", i), i, 0))
+	}
+
+	assert.Len(t, maps.Collect(sharded.All()), 200)
+	assert.Len(t, slices.Collect(sharded.Keys()), 200)
+	assert.Len(t, maps.Collect(sharded.ScanPrefix("k-")), 100)
+
+	require.NoError(t, sharded.DeleteMatch(ctx, "k-?"))
+	require.NoError(t, sharded.DeletePrefix(ctx, "x-"))
+	require.ErrorIs(t, sharded.DeleteMatch(ctx, "[k"), cache.ErrBadPattern)
+
+	assert.Equal(t, 90, sharded.Size())
+}
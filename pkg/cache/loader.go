@@ -0,0 +1,258 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LoaderFunc produces the value of a key that is missing from the cache.
+type LoaderFunc func(ctx context.Context) (any, error)
+
+// loadCall is a loader run shared by every caller waiting for the same key.
+type loadCall struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	value   any
+	err     error
+	waiters int
+}
+
+// loadFailure is a cached loader error.
+type loadFailure struct {
+	err       error
+	expiresAt time.Time
+}
+
+// loadGroup deduplicates concurrent loads of the same key and remembers
+// recent loader failures.
+//
+// It also tracks keys with a background refresh pending or running.
+type loadGroup struct {
+	calls      map[string]*loadCall
+	failures   map[string]loadFailure
+	refreshing map[string]struct{}
+	mx         sync.Mutex
+}
+
+func newLoadGroup() loadGroup {
+	return loadGroup{
+		calls:      make(map[string]*loadCall),
+		failures:   make(map[string]loadFailure),
+		refreshing: make(map[string]struct{}),
+		mx:         sync.Mutex{},
+	}
+}
+
+// failure returns a cached, non-expired loader error for key, or nil.
+// The caller must hold the group lock.
+func (g *loadGroup) failure(key string, now time.Time) error {
+	failure, ok := g.failures[key]
+	if !ok {
+		return nil
+	}
+
+	if now.After(failure.expiresAt) {
+		delete(g.failures, key)
+		return nil
+	}
+
+	return failure.err
+}
+
+// prune drops expired loader errors.
+func (g *loadGroup) prune(now time.Time) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	for key, failure := range g.failures {
+		if now.After(failure.expiresAt) {
+			delete(g.failures, key)
+		}
+	}
+}
+
+// GetOrLoad returns the cached value for key, calling loader to produce and
+// cache it (with the provided TTL) if the key is missing or expired.
+//
+// At most one loader runs per key at a time: callers that miss while a load
+// is in flight wait for it and share its result. Each caller waits only as
+// long as its own context allows; a cancelled caller gets ErrAborted while the
+// load continues for the remaining waiters. The loader context is cancelled
+// once every waiter has given up.
+//
+// If the cache was created with WithNegativeTTL, a loader error is remembered
+// for that long and returned to subsequent callers without calling the loader
+// again. A panicking loader is reported as an error.
+//
+// Close cancels running loads and waits for them; their values are returned
+// to the waiters but not stored. GetOrLoad on a closed cache returns
+// ErrAborted on a miss.
+func (mc *MemCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (any, error) {
+	if value, err := mc.Get(ctx, key); err == nil {
+		return value, nil
+	}
+
+	mc.loads.mx.Lock()
+
+	if err := mc.loads.failure(key, mc.now()); err != nil {
+		mc.loads.mx.Unlock()
+		return nil, err
+	}
+
+	call, inFlight := mc.loads.calls[key]
+	if inFlight {
+		call.waiters++
+		mc.loads.mx.Unlock()
+		mc.metrics.AddLoadDedup()
+
+		return mc.waitLoad(ctx, key, call)
+	}
+
+	// A load finishing between the Get above and taking the lock has already
+	// stored its value.
+	if value, ok := mc.peek(key); ok {
+		mc.loads.mx.Unlock()
+		return value, nil
+	}
+
+	if mc.closed.Load() {
+		mc.loads.mx.Unlock()
+		return nil, ErrAborted
+	}
+
+	loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	call = &loadCall{
+		done:    make(chan struct{}),
+		cancel:  cancel,
+		value:   nil,
+		err:     nil,
+		waiters: 1,
+	}
+	mc.loads.calls[key] = call
+	mc.loadWG.Add(1)
+	mc.loads.mx.Unlock()
+	mc.metrics.AddLoad()
+
+	go mc.load(loadCtx, key, ttl, loader, call)
+
+	return mc.waitLoad(ctx, key, call)
+}
+
+// waitLoad blocks until call completes or ctx is done. A call completing
+// together with ctx still returns its outcome.
+func (mc *MemCache) waitLoad(ctx context.Context, key string, call *loadCall) (any, error) {
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+	}
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	default:
+	}
+
+	mc.loads.mx.Lock()
+
+	call.waiters--
+	if call.waiters == 0 {
+		call.cancel()
+
+		if mc.loads.calls[key] == call {
+			delete(mc.loads.calls, key)
+		}
+	}
+	mc.loads.mx.Unlock()
+
+	mc.log.Error().
+		Err(ctx.Err()).
+		Str("key", key).
+		Msg("load key aborted")
+
+	return nil, ErrAborted
+}
+
+// load runs loader, caches its outcome and releases the waiters of call.
+func (mc *MemCache) load(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc, call *loadCall) {
+	defer mc.loadWG.Done()
+	defer call.cancel()
+
+	value, err := runLoader(ctx, loader)
+
+	switch {
+	case err != nil:
+		mc.metrics.AddLoadError()
+	case mc.closed.Load():
+		mc.log.Warn().Str("key", key).Msg("cache closed, loaded value not stored")
+	default:
+		if setErr := mc.Set(ctx, key, value, ttl); setErr != nil {
+			mc.log.Error().Err(setErr).Str("key", key).Msg("storing loaded value failed")
+		}
+	}
+
+	mc.loads.mx.Lock()
+
+	if err != nil && mc.negativeTTL > 0 && ctx.Err() == nil {
+		mc.loads.failures[key] = loadFailure{err: err, expiresAt: mc.now().Add(mc.negativeTTL)}
+	}
+
+	if mc.loads.calls[key] == call {
+		delete(mc.loads.calls, key)
+	}
+
+	call.value, call.err = value, err
+	close(call.done)
+	mc.loads.mx.Unlock()
+}
+
+// peek returns the live value of key without recording a hit or a miss.
+func (mc *MemCache) peek(key string) (any, bool) {
+	mc.mx.RLock()
+	defer mc.mx.RUnlock()
+
+	val, ok := mc.items[key]
+	if !ok || val.IsExpired(mc.now()) {
+		return nil, false
+	}
+
+	return val.value, true
+}
+
+// stopLoads cancels the loader context of every running load. Loads started
+// afterwards see the cache closed.
+func (mc *MemCache) stopLoads() {
+	mc.loads.mx.Lock()
+	defer mc.loads.mx.Unlock()
+
+	for _, call := range mc.loads.calls {
+		call.cancel()
+	}
+}
+
+// runLoader calls loader, converting a panic into an error.
+func runLoader(ctx context.Context, loader LoaderFunc) (value any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			value, err = nil, fmt.Errorf("cache loader panic: %v", r)
+		}
+	}()
+
+	return loader(ctx)
+}
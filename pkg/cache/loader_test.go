@@ -0,0 +1,284 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/cachetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errLoad = errors.New("load failed")
+
+func TestMemCacheGetOrLoad(t *testing.T) {
+	t.Parallel()
+
+	log := Logger(t)
+
+	t.Run("concurrent misses share one loader call", func(t *testing.T) {
+		t.Parallel()
+
+		mcache := cache.New(log)
+
+		t.Cleanup(func() {
+			require.NoError(t, mcache.Close(t.Context()))
+		})
+
+		const waiters = 50
+
+		var (
+			calls   atomic.Int32
+			wg      sync.WaitGroup
+			release = make(chan struct{})
+		)
+
+		loader := func(context.Context) (any, error) {
+			calls.Add(1)
+			<-release
+
+			return "loaded", nil
+		}
+
+		wg.Add(waiters)
+
+		for range waiters {
+			go func() {
+				defer wg.Done()
+
+				v, err := mcache.GetOrLoad(t.Context(), "key", time.Minute, loader)
+				assert.NoError(t, err)
+				assert.Equal(t, "loaded", v)
+			}()
+		}
+
+		require.Eventually(
+			t,
+			func() bool { return mcache.Metrics().LoadDedups == waiters-1 },
+			time.Second,
+			time.Millisecond,
+		)
+		close(release)
+		wg.Wait()
+
+		v, err := mcache.Get(t.Context(), "key")
+		require.NoError(t, err)
+		assert.Equal(t, "loaded", v)
+
+		mtrcs := mcache.Metrics()
+		assert.Equal(t, int32(1), calls.Load())
+		assert.Equal(t, uint64(1), mtrcs.Loads)
+		assert.Equal(t, uint64(waiters-1), mtrcs.LoadDedups)
+		assert.Equal(t, uint64(0), mtrcs.LoadErrors)
+	})
+
+	t.Run("cached value skips loader", func(t *testing.T) {
+		t.Parallel()
+
+		mcache := cache.New(log)
+
+		t.Cleanup(func() {
+			require.NoError(t, mcache.Close(t.Context()))
+		})
+
+		require.NoError(t, mcache.Set(t.Context(), "key", "cached", 0))
+
+		v, err := mcache.GetOrLoad(t.Context(), "key", 0, func(context.Context) (any, error) {
+			return nil, errLoad
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "cached", v)
+		assert.Equal(t, uint64(0), mcache.Metrics().Loads)
+	})
+
+	t.Run("cancelled waiter does not stop others", func(t *testing.T) {
+		t.Parallel()
+
+		mcache := cache.New(log)
+
+		t.Cleanup(func() {
+			require.NoError(t, mcache.Close(t.Context()))
+		})
+
+		release := make(chan struct{})
+		loader := func(context.Context) (any, error) {
+			<-release
+			return 7, nil
+		}
+
+		result := make(chan error, 1)
+
+		go func() {
+			_, err := mcache.GetOrLoad(t.Context(), "key", 0, loader)
+			result <- err
+		}()
+
+		require.Eventually(t, func() bool { return mcache.Metrics().Loads == 1 }, time.Second, time.Millisecond)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		_, err := mcache.GetOrLoad(ctx, "key", 0, loader)
+		require.ErrorIs(t, err, cache.ErrAborted)
+
+		close(release)
+		require.NoError(t, <-result)
+
+		v, err := mcache.Get(t.Context(), "key")
+		require.NoError(t, err)
+		assert.Equal(t, 7, v)
+	})
+
+	t.Run("loader context is cancelled when all waiters leave", func(t *testing.T) {
+		t.Parallel()
+
+		mcache := cache.New(log)
+
+		t.Cleanup(func() {
+			require.NoError(t, mcache.Close(t.Context()))
+		})
+
+		loaderDone := make(chan error, 1)
+		ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+
+		defer cancel()
+
+		_, err := mcache.GetOrLoad(ctx, "key", 0, func(loadCtx context.Context) (any, error) {
+			<-loadCtx.Done()
+			loaderDone <- loadCtx.Err()
+
+			return nil, loadCtx.Err()
+		})
+		require.ErrorIs(t, err, cache.ErrAborted)
+		require.ErrorIs(t, <-loaderDone, context.Canceled)
+	})
+
+	t.Run("loader errors are returned and counted", func(t *testing.T) {
+		t.Parallel()
+
+		mcache := cache.New(log)
+
+		t.Cleanup(func() {
+			require.NoError(t, mcache.Close(t.Context()))
+		})
+
+		var calls atomic.Int32
+
+		loader := func(context.Context) (any, error) {
+			calls.Add(1)
+			return nil, errLoad
+		}
+
+		for range 2 {
+			_, err := mcache.GetOrLoad(t.Context(), "key", 0, loader)
+			require.ErrorIs(t, err, errLoad)
+		}
+
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Equal(t, uint64(2), mcache.Metrics().LoadErrors)
+		assert.Equal(t, 0, mcache.Size())
+	})
+
+	t.Run("negative ttl caches loader errors", func(t *testing.T) {
+		t.Parallel()
+
+		clock := cachetest.NewClock(time.Now())
+		mcache := cache.New(log, cache.WithNegativeTTL(30*time.Millisecond), cache.WithClock(clock))
+
+		t.Cleanup(func() {
+			require.NoError(t, mcache.Close(t.Context()))
+		})
+
+		var calls atomic.Int32
+
+		loader := func(context.Context) (any, error) {
+			calls.Add(1)
+			return nil, errLoad
+		}
+
+		for range 3 {
+			_, err := mcache.GetOrLoad(t.Context(), "key", 0, loader)
+			require.ErrorIs(t, err, errLoad)
+		}
+
+		assert.Equal(t, int32(1), calls.Load())
+
+		clock.Advance(40 * time.Millisecond)
+
+		_, err := mcache.GetOrLoad(t.Context(), "key", 0, loader)
+		require.ErrorIs(t, err, errLoad)
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Equal(t, uint64(2), mcache.Metrics().LoadErrors)
+	})
+
+	t.Run("close waits for running loads", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "cache.log")
+		mcache := openLogged(t, path)
+
+		var finished atomic.Bool
+
+		started := make(chan struct{})
+		result := make(chan any, 1)
+
+		go func() {
+			v, err := mcache.GetOrLoad(t.Context(), "key", 0, func(ctx context.Context) (any, error) {
+				close(started)
+				<-ctx.Done()
+				finished.Store(true)
+
+				return "late", nil
+			})
+			assert.NoError(t, err)
+			result <- v
+		}()
+
+		<-started
+		require.NoError(t, mcache.Close(t.Context()))
+		assert.True(t, finished.Load(), "Close returns after the loader")
+		assert.Equal(t, "late", <-result, "waiters still get the loaded value")
+
+		_, err := mcache.GetOrLoad(t.Context(), "other", 0, func(context.Context) (any, error) {
+			return "value", nil
+		})
+		require.ErrorIs(t, err, cache.ErrAborted)
+
+		assert.Equal(t, 0, openLogged(t, path).Size(), "values loaded during Close are not stored")
+	})
+
+	t.Run("loader panic becomes error", func(t *testing.T) {
+		t.Parallel()
+
+		mcache := cache.New(log)
+
+		t.Cleanup(func() {
+			require.NoError(t, mcache.Close(t.Context()))
+		})
+
+		_, err := mcache.GetOrLoad(t.Context(), "key", 0, func(context.Context) (any, error) {
+			panic("boom")
+		})
+		require.ErrorContains(t, err, "boom")
+	})
+}
@@ -0,0 +1,104 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lru is a Policy that evicts the least recently used key.
+type lru struct {
+	ll       *list.List
+	elems    map[string]*list.Element
+	capacity int
+	mx       sync.Mutex
+}
+
+// NewLRU returns a Policy that evicts the least recently used key once more
+// than capacity keys are stored. It is the default policy of WithMaxEntries.
+func NewLRU(capacity int) Policy {
+	return &lru{
+		ll:       list.New(),
+		elems:    make(map[string]*list.Element),
+		capacity: capacity,
+		mx:       sync.Mutex{},
+	}
+}
+
+// Add marks key as most recently used. If inserting a new key pushes the
+// list over capacity, the least recently used key is evicted.
+func (l *lru) Add(key string) Admission {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if elem, ok := l.elems[key]; ok {
+		l.ll.MoveToFront(elem)
+		return Admission{}
+	}
+
+	l.elems[key] = l.ll.PushFront(key)
+
+	if l.ll.Len() <= l.capacity {
+		return Admission{}
+	}
+
+	oldest := l.ll.Back()
+	victim, _ := oldest.Value.(string)
+
+	l.ll.Remove(oldest)
+	delete(l.elems, victim)
+
+	return Admission{Evicted: []string{victim}, Decision: DecisionNone}
+}
+
+// Evict forgets and returns the least recently used key.
+func (l *lru) Evict() (string, bool) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	oldest := l.ll.Back()
+	if oldest == nil {
+		return "", false
+	}
+
+	victim, _ := oldest.Value.(string)
+
+	l.ll.Remove(oldest)
+	delete(l.elems, victim)
+
+	return victim, true
+}
+
+// Access marks an existing key as most recently used.
+func (l *lru) Access(key string) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if elem, ok := l.elems[key]; ok {
+		l.ll.MoveToFront(elem)
+	}
+}
+
+// Remove forgets key. Unknown keys are ignored.
+func (l *lru) Remove(key string) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if elem, ok := l.elems[key]; ok {
+		l.ll.Remove(elem)
+		delete(l.elems, key)
+	}
+}
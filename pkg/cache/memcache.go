@@ -16,6 +16,8 @@ package cache
 
 import (
 	"context"
+	"math"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -36,56 +38,137 @@ const (
 // It performs eviction:
 //   - lazily on Get (expired items are removed on access)
 //   - periodically via a background cleaner (best-effort, capped per run)
+//   - on Set when a capacity or byte bound is configured (as decided by its
+//     Policy)
+//
+// Entries written with SetWithRefresh are refreshed ahead of their expiry by a
+// pool of background workers.
 //
 // MemCache uses read-write locks to allow concurrent reads while ensuring
 // thread safety. Write operations (Set, Delete) block readers, but reads
 // (Get) use read locks for better concurrency.
 //
-// Close must be called to stop the background cleaner and release resources.
+// Close must be called to stop the background cleaner and refresh workers
+// and release resources.
 type MemCache struct {
-	items     map[string]entry
-	stopCh    chan struct{}
-	metrics   Metrics
-	log       zerolog.Logger
-	mx        sync.RWMutex
-	cleanerWG sync.WaitGroup
-	closed    atomic.Bool
+	items         map[string]entry
+	expiry        expiryQueue
+	tags          map[string]map[string]struct{}
+	policy        Policy
+	refreshFn     RefreshFunc
+	codec         Codec
+	sizer         Sizer
+	newHash       HashFunc
+	clock         Clock
+	wlog          *writeLog
+	subs          atomic.Pointer[[]*subscriber]
+	stopCh        chan struct{}
+	refreshCh     chan refreshJob
+	stopRefresh   context.CancelFunc
+	loads         loadGroup
+	metrics       Metrics
+	log           zerolog.Logger
+	cleanupBudget int
+	maxBytes      int64
+	maxValueBytes int64
+	bytes         int64
+	negativeTTL   time.Duration
+	queueSize     int
+	mx            sync.RWMutex
+	subsMx        sync.Mutex
+	cleanerWG     sync.WaitGroup
+	refreshWG     sync.WaitGroup
+	loadWG        sync.WaitGroup
+	logWG         sync.WaitGroup
+	subsWG        sync.WaitGroup
+	closed        atomic.Bool
+	subsClosed    bool
 }
 
 // New returns a MemCache using DefaultCleanupInterval for background cleanup.
 // The returned cache starts a background goroutine that periodically removes
 // expired entries. Call Close to stop the background cleaner.
-func New(log zerolog.Logger) *MemCache {
-	return WithDeleteInterval(DefaultCleanupInterval, log)
+func New(log zerolog.Logger, opts ...Option) *MemCache {
+	return WithDeleteInterval(DefaultCleanupInterval, log, opts...)
 }
 
 // WithDeleteInterval returns a MemCache that runs the background cleaner at the
 // provided interval.
 //
 // The background cleaner removes expired entries in batches, processing at most
-// MaxDeletesPerRun items per interval to bound cleanup work.
+// MaxDeletesPerRun items per interval (see WithCleanupBudget) to bound cleanup
+// work.
 //
 // If cleanupInterval is <= 0, DefaultCleanupInterval is used instead to avoid
 // ticker panics.
 //
 // The returned cache starts a background goroutine. Call Close to stop it.
-func WithDeleteInterval(cleanupInterval time.Duration, log zerolog.Logger) *MemCache {
+func WithDeleteInterval(cleanupInterval time.Duration, log zerolog.Logger, opts ...Option) *MemCache {
 	if cleanupInterval <= 0 {
 		cleanupInterval = DefaultCleanupInterval
 	}
 
+	return newMemCache(cleanupInterval, log, newOptions(opts))
+}
+
+func newMemCache(cleanupInterval time.Duration, log zerolog.Logger, cfg options) *MemCache {
 	cache := &MemCache{
-		items:     make(map[string]entry),
-		stopCh:    make(chan struct{}),
-		metrics:   Metrics{},
-		log:       log,
-		mx:        sync.RWMutex{},
-		cleanerWG: sync.WaitGroup{},
-		closed:    atomic.Bool{},
+		items:         make(map[string]entry),
+		expiry:        newExpiryQueue(),
+		tags:          make(map[string]map[string]struct{}),
+		policy:        nil,
+		refreshFn:     cfg.refreshFn,
+		codec:         cfg.codec,
+		sizer:         cfg.sizer,
+		newHash:       cfg.newHash,
+		clock:         cfg.clock,
+		wlog:          nil,
+		subs:          atomic.Pointer[[]*subscriber]{},
+		stopCh:        make(chan struct{}),
+		refreshCh:     nil,
+		stopRefresh:   func() {},
+		loads:         newLoadGroup(),
+		metrics:       Metrics{},
+		log:           log,
+		cleanupBudget: cfg.cleanupBudget,
+		maxBytes:      cfg.maxBytes,
+		maxValueBytes: cfg.maxValueBytes,
+		bytes:         0,
+		negativeTTL:   cfg.negativeTTL,
+		queueSize:     cfg.eventQueueSize,
+		mx:            sync.RWMutex{},
+		subsMx:        sync.Mutex{},
+		cleanerWG:     sync.WaitGroup{},
+		refreshWG:     sync.WaitGroup{},
+		loadWG:        sync.WaitGroup{},
+		logWG:         sync.WaitGroup{},
+		subsWG:        sync.WaitGroup{},
+		closed:        atomic.Bool{},
+		subsClosed:    false,
+	}
+
+	switch {
+	case cfg.maxEntries > 0:
+		cache.policy = cfg.newPolicy(cfg.maxEntries)
+	case cfg.maxBytes > 0:
+		cache.policy = NewLRU(math.MaxInt)
 	}
 
 	cache.cleanerWG.Add(1)
-	go cache.cleaner(cleanupInterval)
+	go cache.cleaner(cache.clock.NewTicker(cleanupInterval))
+
+	if cache.refreshFn != nil {
+		var refreshCtx context.Context
+
+		refreshCtx, cache.stopRefresh = context.WithCancel(context.Background())
+		cache.refreshCh = make(chan refreshJob, DefaultRefreshQueueSize)
+
+		cache.refreshWG.Add(cfg.refreshWorkers)
+
+		for range cfg.refreshWorkers {
+			go cache.refresher(refreshCtx)
+		}
+	}
 
 	return cache
 }
@@ -97,23 +180,165 @@ func WithDeleteInterval(cleanupInterval time.Duration, log zerolog.Logger) *MemC
 //   - ttl <= 0: does not expire
 //
 // Set is safe for concurrent use. If the key already exists, it is overwritten.
+// If the cache was created with WithMaxEntries and is full, storing a new key
+// evicts the entries chosen by the cache Policy. An admission policy may also
+// reject the new key itself.
+//
+// If the cache has size limits (WithMaxBytes, WithMaxValueBytes), values
+// exceeding them are rejected with a *SizeError wrapping ErrTooLarge, and
+// storing a value may evict entries to stay within the byte budget.
+//
+// For a cache opened with Open, Set also appends the write to the log and
+// returns write log errors; values the Codec cannot encode are rejected with
+// ErrType and not stored.
 func (mc *MemCache) Set(_ context.Context, key string, value any, ttl time.Duration) error {
+	return mc.set(key, newEntry(value, ttl, mc.now()))
+}
+
+func (mc *MemCache) set(key string, val entry) error {
+	_, err := mc.setIf(key, val, nil)
+	return err
+}
+
+// setIf stores val under key like set. If cond is not nil, it is called under
+// the write lock with the live entry of key, if any, and val is only stored
+// if cond returns true. setIf reports whether key holds val afterwards, which
+// is not the case if cond declined or the cache Policy rejected the key.
+func (mc *MemCache) setIf(key string, val entry, cond func(current entry, ok bool) (bool, error)) (bool, error) {
+	defer mc.metrics.SetLatency.ObserveSince(time.Now())
+
+	var (
+		admission Admission
+		record    []byte
+	)
+
+	if err := mc.sizeEntry(key, &val); err != nil {
+		return false, err
+	}
+
+	if mc.wlog != nil {
+		var err error
+
+		record, err = encodeLogSet(key, val, mc.codec)
+		if err != nil {
+			return false, err
+		}
+	}
+
 	mc.mx.Lock()
-	mc.items[key] = newEntry(value, ttl)
+
+	if cond != nil {
+		current, ok := mc.liveLocked(key)
+		if store, err := cond(current, ok); !store || err != nil {
+			mc.mx.Unlock()
+			return false, err
+		}
+	}
+
+	admission = mc.storeLocked(key, val)
+
+	if record != nil {
+		mc.wlog.append(record)
+		mc.logEvicted(admission.Evicted)
+	}
 	mc.mx.Unlock()
 
+	mc.stored(admission)
+
+	if record != nil {
+		return !slices.Contains(admission.Evicted, key), mc.wlog.commit()
+	}
+
+	return !slices.Contains(admission.Evicted, key), nil
+}
+
+// stored records the metrics of a store decided by admission.
+func (mc *MemCache) stored(admission Admission) {
 	mc.metrics.AddSet()
+	mc.metrics.AddCapacityEviction(uint64(len(admission.Evicted))) //nolint:gosec // bounded by capacity
+	mc.metrics.AddAdmission(admission.Decision)
+}
 
-	return nil
+// storeLocked stores val under key, keeping the tag index, the byte count
+// and the cache Policy up to date, and returns the decision of the Policy,
+// including keys evicted to fit in the byte budget. val must have been sized
+// with sizeEntry. The caller must hold the write lock.
+func (mc *MemCache) storeLocked(key string, val entry) Admission {
+	if old, ok := mc.items[key]; ok {
+		mc.untagLocked(key, old.tags)
+		mc.addBytesLocked(-old.size)
+		mc.emitLocked(key, old.value, ReasonReplaced)
+	}
+
+	mc.items[key] = val
+	mc.expiry.schedule(key, val.expiresAt)
+	mc.tagLocked(key, val.tags)
+	mc.addBytesLocked(val.size)
+
+	if mc.policy == nil {
+		return Admission{Evicted: nil, Decision: DecisionNone}
+	}
+
+	admission := mc.policy.Add(key)
+	for _, victim := range admission.Evicted {
+		mc.unlinkLocked(victim, ReasonEvicted)
+	}
+
+	admission.Evicted = append(admission.Evicted, mc.fitLocked(key)...)
+
+	return admission
+}
+
+// unlinkLocked deletes key from the cache and the tag index, but not from
+// the cache Policy, and reports the removal to subscribers. The caller must
+// hold the write lock.
+func (mc *MemCache) unlinkLocked(key string, reason EventReason) {
+	if val, ok := mc.items[key]; ok {
+		mc.untagLocked(key, val.tags)
+		mc.addBytesLocked(-val.size)
+		delete(mc.items, key)
+		mc.expiry.unschedule(key)
+		mc.emitLocked(key, val.value, reason)
+	}
+}
+
+// removeLocked deletes key from the cache, the tag index and the cache
+// Policy, and reports the removal to subscribers. The caller must hold the
+// write lock.
+func (mc *MemCache) removeLocked(key string, reason EventReason) {
+	mc.unlinkLocked(key, reason)
+
+	if mc.policy != nil {
+		mc.policy.Remove(key)
+	}
+}
+
+// liveLocked returns the entry of key if it exists and is not expired. An
+// expired entry is removed and counted as a lazy eviction. The caller must
+// hold the write lock.
+func (mc *MemCache) liveLocked(key string) (entry, bool) {
+	val, ok := mc.items[key]
+	if !ok {
+		return entry{}, false
+	}
+
+	if val.IsExpired(mc.now()) {
+		mc.removeLocked(key, ReasonExpired)
+		mc.metrics.AddLazyEviction()
+
+		return entry{}, false
+	}
+
+	return val, true
 }
 
-// Checks if key can be invalidated.
-func (mc *MemCache) invalidated(key string) bool {
+// Checks if key can be invalidated. reason tells subscribers who evicted it.
+func (mc *MemCache) invalidated(key string, reason EventReason) bool {
 	mc.mx.Lock()
 	defer mc.mx.Unlock()
 
-	if val, ok := mc.items[key]; ok && val.IsExpired() {
-		delete(mc.items, key)
+	if val, ok := mc.items[key]; ok && val.IsExpired(mc.now()) {
+		mc.removeLocked(key, reason)
 		return true
 	}
 
@@ -127,7 +352,7 @@ func (mc *MemCache) get(key string) (entry, error) {
 
 	val, ok := mc.items[key]
 	if !ok {
-		mc.metrics.AddMiss()
+		mc.metrics.addMiss(mc.now())
 		return entry{}, ErrNotFound
 	}
 
@@ -141,17 +366,29 @@ func (mc *MemCache) get(key string) (entry, error) {
 // background cleaner. Get guarantees that it never returns a value that
 // is expired at the time of the final check.
 //
+// If the entry was written with SetWithRefresh and its soft deadline has
+// passed, Get returns the current value and starts a background refresh. If
+// it was written with SetSliding, Get extends its expiry.
+//
 // Get is safe for concurrent use. It uses read locks for fast access and
 // only acquires a write lock when deleting expired entries.
 func (mc *MemCache) Get(_ context.Context, key string) (any, error) {
+	defer mc.metrics.GetLatency.ObserveSince(time.Now())
+
+	if mc.policy != nil {
+		mc.policy.Access(key)
+	}
+
 	val, err := mc.get(key)
 	if err != nil {
 		return nil, err
 	}
+
+	now := mc.now()
 	// lazy invalidation
-	if val.IsExpired() {
-		if mc.invalidated(key) {
-			mc.metrics.AddMiss()
+	if val.IsExpired(now) {
+		if mc.invalidated(key, ReasonExpired) {
+			mc.metrics.addMiss(now)
 			mc.metrics.AddLazyEviction()
 
 			return nil, ErrNotFound
@@ -165,13 +402,21 @@ func (mc *MemCache) Get(_ context.Context, key string) (any, error) {
 
 		// If the refreshed value is already expired, treat as not found.
 		// Background cleaner will eventually evict it; no need to delete here.
-		if val.IsExpired() {
-			mc.metrics.AddMiss()
+		if val.IsExpired(now) {
+			mc.metrics.addMiss(now)
 			return nil, ErrNotFound
 		}
 	}
 
-	mc.metrics.AddHit()
+	if val.sliding {
+		mc.slide(key)
+	}
+
+	if mc.refreshFn != nil && val.NeedsRefresh(now) {
+		mc.startRefresh(key, val)
+	}
+
+	mc.metrics.addHit(now)
 
 	return val.value, nil
 }
@@ -182,13 +427,26 @@ func (mc *MemCache) Get(_ context.Context, key string) (any, error) {
 // The operation can be cancelled via the context.
 // If cancelled, Delete returns ErrAborted.
 // Metrics are still updated for keys deleted before cancellation.
+//
+// For a cache opened with Open, Delete also appends the deletes to the log
+// and returns write log errors.
 func (mc *MemCache) Delete(ctx context.Context, keys ...string) error {
+	_, err := mc.DeleteMany(ctx, keys...)
+	return err
+}
+
+// delete removes keys under a single write lock and returns the number of
+// live entries removed.
+func (mc *MemCache) delete(ctx context.Context, keys []string) (int, error) {
 	mc.mx.Lock()
 	defer mc.mx.Unlock()
 
-	deleted := uint32(0)
+	deleted := uint64(0)
 	defer func() { mc.metrics.AddDelete(deleted) }()
 
+	now := mc.now()
+	live := 0
+
 	for _, key := range keys {
 		select {
 		case <-ctx.Done():
@@ -197,73 +455,57 @@ func (mc *MemCache) Delete(ctx context.Context, keys ...string) error {
 				Str("key", key).
 				Msg("delete key aborted")
 
-			return ErrAborted
+			return live, ErrAborted
 		default:
-			if _, exists := mc.items[key]; exists {
-				delete(mc.items, key)
+			if val, exists := mc.items[key]; exists {
+				if !val.IsExpired(now) {
+					live++
+				}
+
+				mc.removeLocked(key, ReasonDeleted)
+
+				if mc.wlog != nil {
+					mc.wlog.append(encodeLogDelete(key))
+				}
 
 				deleted++
 			}
 		}
 	}
 
-	return nil
+	return live, nil
 }
 
 // cleaner runs periodically and removes expired entries from the cache.
 //
 // It is started automatically by New/WithDeleteInterval in a background
 // goroutine and stops when Close is called. On each tick it:
-//   - scans the map under a read lock and collects up to MaxDeletesPerRun
-//     keys whose entries appear expired
-//   - for each candidate key, calls invalidated (under a write lock) to
-//     re-check expiration and delete the entry if it is still expired
+//   - pops up to the cleanup budget expired keys off the expiry queue,
+//     earliest first, and deletes their entries in batches under the write
+//     lock; live entries are never visited, so a run costs O(log n) per
+//     expired entry however large the cache is
 //   - records metrics about how many items were evicted and how long the
 //     cleanup took
 //
 // The cleaner is best-effort: it may leave some expired entries around
 // between runs, but lazy eviction in Get ensures callers do not observe
 // expired values.
-func (mc *MemCache) cleaner(interval time.Duration) {
+func (mc *MemCache) cleaner(ticker Ticker) {
 	defer mc.cleanerWG.Done()
-
-	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	mc.log.Info().Msg("started cache cleaner")
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-ticker.C():
 			start := time.Now()
-
-			mc.mx.RLock()
-
-			keysToClean := make([]string, 0, MaxDeletesPerRun)
-			for key, val := range mc.items {
-				if len(keysToClean) >= MaxDeletesPerRun {
-					break
-				}
-
-				if val.IsExpired() {
-					keysToClean = append(keysToClean, key)
-				}
-			}
-
-			mc.mx.RUnlock()
-
-			deleted := uint32(0)
-
-			for _, key := range keysToClean {
-				if mc.invalidated(key) {
-					deleted++
-				}
-			}
-
+			deleted := mc.cleanExpired(mc.now())
 			duration := time.Since(start)
 
 			mc.metrics.AddScheduledEviction(deleted)
 			mc.metrics.AddCleanupRun(duration, deleted)
+			mc.loads.prune(mc.now())
 		case <-mc.stopCh:
 			mc.log.Info().Msg("gracefully stopped cache cleaner")
 			return
@@ -274,10 +516,16 @@ func (mc *MemCache) cleaner(interval time.Duration) {
 // Metrics returns a snapshot of internal metrics.
 //
 // The returned snapshot is a point-in-time copy of all metrics, safe for
-// concurrent access. Metrics include hits, misses, sets, deletes, and
-// eviction statistics.
+// concurrent access. Metrics include hits, misses, sets, deletes, eviction
+// statistics, recent hit ratios and operation latencies.
 func (mc *MemCache) Metrics() Metrics {
-	return mc.metrics.Snapshot()
+	return mc.metrics.snapshot(mc.now())
+}
+
+// ResetMetrics zeroes the internal metrics and returns a snapshot of their
+// values before the reset. See Metrics.Reset.
+func (mc *MemCache) ResetMetrics() Metrics {
+	return mc.metrics.reset(mc.now())
 }
 
 // MetricsJSON returns a JSON snapshot of internal metrics as a string.
@@ -300,33 +548,62 @@ func (mc *MemCache) Size() int {
 	return len(mc.items)
 }
 
-// Close stops the background cleaner. It is safe to call Close multiple times.
-func (mc *MemCache) Close(_ context.Context) error {
-	if mc.closed.CompareAndSwap(false, true) {
+// Close stops the background cleaner and refresh workers, cancelling
+// in-flight refreshes and GetOrLoad loads and waiting for them. For a cache
+// opened with Open, it also aborts a running compaction, syncs and closes the
+// write log. Event subscribers receive the remaining entries with
+// ReasonClosed; Close waits for them until ctx is done. It is safe to call
+// Close multiple times.
+func (mc *MemCache) Close(ctx context.Context) error {
+	first := mc.closed.CompareAndSwap(false, true)
+	if first {
 		close(mc.stopCh)
+		mc.stopRefresh()
 	}
 
+	// Taking the load lock once the cache is closed also ensures no load is
+	// added to loadWG while waiting for it.
+	mc.stopLoads()
+
 	mc.cleanerWG.Wait()
+	mc.refreshWG.Wait()
+	mc.loadWG.Wait()
+	mc.logWG.Wait()
+
+	if first {
+		mc.closeSubscribers(ctx)
+	}
+
+	if mc.wlog != nil {
+		return mc.wlog.close()
+	}
 
 	return nil
 }
 
 // Digest returns a fingerprint for the current (non-expired) value of key.
 //
-// If key is missing or expired, Digest returns 0. For MemCache, the digest is
-// defined and stable for primitive types (string, []byte, bool, ints, uints,
-// floats). For other value types, Digest returns 0.
+// If key is missing or expired, Digest returns 0. Digests are computed with
+// the HashFunc set by WithDigestHash; see DigestWith for the value types whose
+// digests are stable. Types may define their own digest by implementing
+// Digester.
 //
 // Digest is safe for concurrent use. It does not remove expired entries; use
 // Get for lazy eviction.
 func (mc *MemCache) Digest(_ context.Context, key string) Digest {
+	return mc.digest(key, true)
+}
+
+// digest returns the digest of the value of key. Unless composite is set,
+// only values of primitive types are fingerprinted and other values yield 0.
+func (mc *MemCache) digest(key string, composite bool) Digest {
 	mc.mx.RLock()
 	defer mc.mx.RUnlock()
 
 	val, ok := mc.items[key]
-	if !ok {
+	if !ok || (!composite && !isPrimitive(val.value)) {
 		return 0
 	}
 
-	return val.Digest()
+	return val.Digest(mc.newHash, mc.now())
 }
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/cachetest"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -105,17 +106,18 @@ func TestMemCacheStress(t *testing.T) {
 
 		mtcs := mcache.Metrics()
 
-		assert.Equal(t, uint32(keys), mtcs.Sets)
-		assert.Equal(t, uint32(keys), mtcs.Hits)
-		assert.Equal(t, uint32(keys), mtcs.Deletes)
-		assert.Equal(t, uint32(keys), mtcs.Misses)
-		assert.Equal(t, uint32(0), mtcs.LazyEvictions, "No TTL used here, so there shouldn't be any evictions.")
+		assert.Equal(t, uint64(keys), mtcs.Sets)
+		assert.Equal(t, uint64(keys), mtcs.Hits)
+		assert.Equal(t, uint64(keys), mtcs.Deletes)
+		assert.Equal(t, uint64(keys), mtcs.Misses)
+		assert.Equal(t, uint64(0), mtcs.LazyEvictions, "No TTL used here, so there shouldn't be any evictions.")
 	})
 
 	t.Run("lazy eviction under concurrent gets", func(t *testing.T) {
 		t.Parallel()
 
-		mcache := cache.WithDeleteInterval(time.Hour, log)
+		clock := cachetest.NewClock(time.Now())
+		mcache := cache.WithDeleteInterval(time.Hour, log, cache.WithClock(clock))
 
 		t.Cleanup(func() {
 			require.NoError(t, mcache.Close(t.Context()))
@@ -136,7 +138,7 @@ func TestMemCacheStress(t *testing.T) {
 
 		wg.Wait()
 
-		time.Sleep(20 * time.Millisecond)
+		clock.Advance(20 * time.Millisecond)
 
 		wg.Add(keys)
 
@@ -155,9 +157,9 @@ func TestMemCacheStress(t *testing.T) {
 
 		m := mcache.Metrics()
 
-		assert.Equal(t, uint32(keys), m.LazyEvictions)
-		assert.Equal(t, uint32(keys), m.Misses)
-		assert.Equal(t, uint32(0), m.Hits)
+		assert.Equal(t, uint64(keys), m.LazyEvictions)
+		assert.Equal(t, uint64(keys), m.Misses)
+		assert.Equal(t, uint64(0), m.Hits)
 	})
 }
 
@@ -165,8 +167,9 @@ func TestMemCacheBackgroundCleaner(t *testing.T) {
 	t.Parallel()
 
 	log := Logger(t)
+	clock := cachetest.NewClock(time.Now())
 
-	mcache := cache.WithDeleteInterval(5*time.Millisecond, log)
+	mcache := cache.WithDeleteInterval(time.Minute, log, cache.WithClock(clock))
 
 	t.Cleanup(func() {
 		require.NoError(t, mcache.Close(t.Context()))
@@ -175,30 +178,33 @@ func TestMemCacheBackgroundCleaner(t *testing.T) {
 	const keys = 100
 
 	for key := range keys {
-		err := mcache.Set(t.Context(), fmt.Sprintf("c-%d", key), key, 10*time.Millisecond)
+		err := mcache.Set(t.Context(), fmt.Sprintf("c-%d", key), key, 90*time.Second)
 		require.NoError(t, err)
 	}
 
-	// Wait for all keys to expire.
-	time.Sleep(30 * time.Millisecond)
-
-	// Eventually the background cleaner should evict the expired keys.
-	require.Eventually(
-		t,
-		func() bool {
-			mt := mcache.Metrics()
-			return mt.ScheduledEvictions >= uint32(keys) && mcache.Size() == 0
-		},
-		time.Second,
-		10*time.Millisecond,
-	)
+	cleanupRuns := func(runs uint64) func() bool {
+		return func() bool { return mcache.Metrics().CleanupRuns == runs }
+	}
+
+	// The first run comes before the keys expire and evicts nothing.
+	clock.Advance(time.Minute)
+	require.Eventually(t, cleanupRuns(1), time.Second, time.Millisecond)
+	assert.Zero(t, mcache.Metrics().ScheduledEvictions)
+	assert.Equal(t, keys, mcache.Size())
+
+	// The second run evicts all of them.
+	clock.Advance(time.Minute)
+	require.Eventually(t, cleanupRuns(2), time.Second, time.Millisecond)
+	assert.Equal(t, uint64(keys), mcache.Metrics().ScheduledEvictions)
+	assert.Zero(t, mcache.Size())
 }
 
 func TestMemCacheDigest(t *testing.T) {
 	t.Parallel()
 
 	log := Logger(t)
-	mcache := cache.New(log)
+	clock := cachetest.NewClock(time.Now())
+	mcache := cache.New(log, cache.WithClock(clock))
 
 	t.Cleanup(func() {
 		require.NoError(t, mcache.Close(t.Context()))
@@ -229,13 +235,13 @@ func TestMemCacheDigest(t *testing.T) {
 		t.Parallel()
 
 		require.NoError(t, mcache.Set(ctx, "exp", "val", 10*time.Millisecond))
-		time.Sleep(20 * time.Millisecond)
+		clock.Advance(20 * time.Millisecond)
 
 		d := mcache.Digest(ctx, "exp")
 		assert.Equal(t, cache.Digest(0), d)
 	})
 
-	t.Run("unsupported type returns zero digest", func(t *testing.T) {
+	t.Run("struct value returns structural digest", func(t *testing.T) {
 		t.Parallel()
 
 		type custom struct{ X int }
@@ -243,6 +249,143 @@ func TestMemCacheDigest(t *testing.T) {
 		require.NoError(t, mcache.Set(ctx, "custom", custom{X: 1}, 0))
 
 		d := mcache.Digest(ctx, "custom")
+		assert.Equal(t, cache.DigestOf(custom{X: 1}), d)
+		assert.NotEqual(t, cache.Digest(0), d)
+	})
+
+	t.Run("unsupported type returns zero digest", func(t *testing.T) {
+		t.Parallel()
+
+		require.NoError(t, mcache.Set(ctx, "func", func() {}, 0))
+
+		d := mcache.Digest(ctx, "func")
 		assert.Equal(t, cache.Digest(0), d)
 	})
 }
+
+func TestMemCacheMaxEntries(t *testing.T) {
+	t.Parallel()
+
+	log := Logger(t)
+
+	t.Run("evicts least recently used key when full", func(t *testing.T) {
+		t.Parallel()
+
+		mcache := cache.New(log, cache.WithMaxEntries(3))
+
+		t.Cleanup(func() {
+			require.NoError(t, mcache.Close(t.Context()))
+		})
+
+		ctx := t.Context()
+
+		require.NoError(t, mcache.Set(ctx, "a", 1, 0))
+		require.NoError(t, mcache.Set(ctx, "b", 2, 0))
+		require.NoError(t, mcache.Set(ctx, "c", 3, 0))
+
+		// Touch "a" so that "b" becomes the least recently used key.
+		_, err := mcache.Get(ctx, "a")
+		require.NoError(t, err)
+
+		require.NoError(t, mcache.Set(ctx, "d", 4, 0))
+
+		_, err = mcache.Get(ctx, "b")
+		require.ErrorIs(t, err, cache.ErrNotFound)
+
+		for _, key := range []string{"a", "c", "d"} {
+			_, err = mcache.Get(ctx, key)
+			require.NoError(t, err, key)
+		}
+
+		assert.Equal(t, 3, mcache.Size())
+		assert.Equal(t, uint64(1), mcache.Metrics().CapacityEvictions)
+		assert.Equal(t, uint64(0), mcache.Metrics().LazyEvictions)
+	})
+
+	t.Run("overwrite does not evict", func(t *testing.T) {
+		t.Parallel()
+
+		mcache := cache.New(log, cache.WithMaxEntries(2))
+
+		t.Cleanup(func() {
+			require.NoError(t, mcache.Close(t.Context()))
+		})
+
+		ctx := t.Context()
+
+		require.NoError(t, mcache.Set(ctx, "a", 1, 0))
+		require.NoError(t, mcache.Set(ctx, "b", 2, 0))
+		require.NoError(t, mcache.Set(ctx, "a", 10, 0))
+
+		assert.Equal(t, 2, mcache.Size())
+		assert.Equal(t, uint64(0), mcache.Metrics().CapacityEvictions)
+
+		// "b" is now the oldest key.
+		require.NoError(t, mcache.Set(ctx, "c", 3, 0))
+
+		_, err := mcache.Get(ctx, "b")
+		require.ErrorIs(t, err, cache.ErrNotFound)
+	})
+
+	t.Run("deleted and expired keys free capacity", func(t *testing.T) {
+		t.Parallel()
+
+		clock := cachetest.NewClock(time.Now())
+		mcache := cache.WithDeleteInterval(time.Hour, log, cache.WithMaxEntries(2), cache.WithClock(clock))
+
+		t.Cleanup(func() {
+			require.NoError(t, mcache.Close(t.Context()))
+		})
+
+		ctx := t.Context()
+
+		require.NoError(t, mcache.Set(ctx, "a", 1, 0))
+		require.NoError(t, mcache.Set(ctx, "b", 2, 10*time.Millisecond))
+		require.NoError(t, mcache.Delete(ctx, "a"))
+
+		clock.Advance(20 * time.Millisecond)
+
+		_, err := mcache.Get(ctx, "b")
+		require.ErrorIs(t, err, cache.ErrNotFound)
+
+		require.NoError(t, mcache.Set(ctx, "c", 3, 0))
+		require.NoError(t, mcache.Set(ctx, "d", 4, 0))
+
+		assert.Equal(t, 2, mcache.Size())
+		assert.Equal(t, uint64(0), mcache.Metrics().CapacityEvictions)
+	})
+
+	t.Run("size never exceeds capacity under concurrent sets", func(t *testing.T) {
+		t.Parallel()
+
+		const capacity = 100
+
+		mcache := cache.New(log, cache.WithMaxEntries(capacity))
+
+		t.Cleanup(func() {
+			require.NoError(t, mcache.Close(t.Context()))
+		})
+
+		var wg sync.WaitGroup
+
+		for worker := range 8 {
+			wg.Add(1)
+
+			go func(w int) {
+				defer wg.Done()
+
+				for i := range 1000 {
+					key := fmt.Sprintf("w%d-%d", w, i)
+					assert.NoError(t, mcache.Set(t.Context(), key, i, 0))
+
+					_, _ = mcache.Get(t.Context(), key)
+				}
+			}(worker)
+		}
+
+		wg.Wait()
+
+		assert.Equal(t, capacity, mcache.Size())
+		assert.Equal(t, uint64(8*1000-capacity), mcache.Metrics().CapacityEvictions)
+	})
+}
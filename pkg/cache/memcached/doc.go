@@ -0,0 +1,44 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memcached implements cache.Cache on top of one or more memcached
+// servers using the meta text protocol (mg, ms, md).
+//
+// Keys are spread over servers with rendezvous hashing, so adding or removing
+// a server only remaps the keys that belonged to it. Values are stored as
+// bytes (see cache.EncodeValue), so Get always returns []byte; use cache.Typed
+// with a Codec to store other types. Keys containing spaces or control
+// characters are sent base64-encoded; empty keys and keys longer than 250
+// bytes as sent are rejected with ErrInvalidKey before anything is sent.
+//
+// TTLs map to memcached exptime: up to 30 days they are sent as relative
+// seconds, longer TTLs as an absolute Unix timestamp, following memcached's
+// rule that larger exptime values are absolute. TTL reads the time a key has
+// left with the t flag of mg.
+//
+// Batch operations (GetMany, SetMany, Delete and DeleteMany) pipeline their
+// commands per server, so each server is visited once per batch. SetMany
+// reports stores a server declined with ErrNotStored, as Set does.
+//
+// Example usage:
+//
+//	client := memcached.New([]string{"10.0.0.1:11211", "10.0.0.2:11211"}, logger)
+//	defer client.Close(context.Background())
+//
+//	client.Set(ctx, "key", "value", time.Minute)
+//	value, err := client.Get(ctx, "key") // []byte("value")
+//
+// The memcachedtest package provides a local fake memcached listener for
+// tests.
+package memcached
@@ -0,0 +1,631 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memcached
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/rs/zerolog"
+)
+
+// Client is a cache.Cache backed by one or more memcached servers.
+//
+// Client is safe for concurrent use. Connections are dialed lazily and reused
+// through a bounded pool per server. Close must be called to release them.
+type Client struct {
+	servers []*server
+	log     zerolog.Logger
+}
+
+// New returns a Client spreading keys over the servers at addrs (host:port).
+// No connection is made until the first command. New panics if addrs is
+// empty.
+func New(addrs []string, log zerolog.Logger, opts ...Option) *Client {
+	if len(addrs) == 0 {
+		panic("memcached: no server addresses")
+	}
+
+	cfg := newOptions(opts)
+	servers := make([]*server, len(addrs))
+
+	for i, addr := range addrs {
+		servers[i] = newServer(addr, cfg)
+	}
+
+	return &Client{servers: servers, log: log}
+}
+
+// pick returns the server owning key using rendezvous hashing: every server
+// is scored by hashing it together with the key and the highest score wins.
+func (c *Client) pick(key string) *server {
+	if len(c.servers) == 1 {
+		return c.servers[0]
+	}
+
+	var (
+		best      *server
+		bestScore uint64
+	)
+
+	for _, srv := range c.servers {
+		score := rendezvousScore(srv.addr, key)
+		if best == nil || score > bestScore {
+			best, bestScore = srv, score
+		}
+	}
+
+	return best
+}
+
+// rendezvousScore is FNV-64a over addr, a separator and key, finished with a
+// 64-bit mixer so that similar addresses still produce independent scores.
+func rendezvousScore(addr, key string) uint64 {
+	const (
+		offset = 14695981039346656037
+		prime  = 1099511628211
+	)
+
+	hash := uint64(offset)
+
+	for i := range len(addr) {
+		hash ^= uint64(addr[i])
+		hash *= prime
+	}
+
+	hash *= prime // zero separator byte
+
+	for i := range len(key) {
+		hash ^= uint64(key[i])
+		hash *= prime
+	}
+
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+
+	return hash
+}
+
+// exchange runs fn on a pooled connection of srv and flushes its writes
+// before fn reads replies, mapping context and timeout errors to
+// cache.ErrAborted.
+func (c *Client) exchange(ctx context.Context, srv *server, fn func(cn *conn) error) error {
+	cn, err := srv.get(ctx)
+	if err != nil {
+		return wrapErr(ctx, err)
+	}
+
+	stop, err := cn.arm(ctx)
+	if err != nil {
+		srv.put(cn, true)
+		return wrapErr(ctx, err)
+	}
+
+	err = fn(cn)
+	stop()
+
+	var serverErr Error
+
+	// Server errors leave the connection in a known state; anything else
+	// (I/O, parse errors) may have desynchronized it.
+	srv.put(cn, err != nil && !errors.As(err, &serverErr) && !errors.Is(err, ErrNotStored))
+
+	if err != nil {
+		return wrapErr(ctx, err)
+	}
+
+	return nil
+}
+
+func wrapErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w: %w", cache.ErrAborted, ctx.Err())
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("%w: %w", cache.ErrAborted, err)
+	}
+
+	return err
+}
+
+// Set stores key/value with the provided TTL using ms.
+//
+// TTL semantics match cache.Cache; TTLs are rounded up to whole seconds and
+// TTLs over 30 days are sent as absolute Unix time. Values are converted with
+// cache.EncodeValue, so non-primitive values are rejected with cache.ErrType.
+func (c *Client) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	if err := checkKeys(key); err != nil {
+		return err
+	}
+
+	data, err := cache.EncodeValue(value)
+	if err != nil {
+		return err
+	}
+
+	flag := ttlFlag(ttl, time.Now())
+
+	return c.exchange(ctx, c.pick(key), func(cn *conn) error {
+		if err := writeMeta(cn.writer, "ms", key, data, flag); err != nil {
+			return err
+		}
+
+		if err := cn.writer.Flush(); err != nil {
+			return err
+		}
+
+		rep, err := readReply(cn.reader)
+		if err != nil {
+			return err
+		}
+
+		switch rep.code {
+		case "HD":
+			return nil
+		case "NS":
+			return ErrNotStored
+		default:
+			return fmt.Errorf("%w: ms replied %s", ErrProtocol, rep.code)
+		}
+	})
+}
+
+// SetMany stores items with one ms command each. Commands are pipelined per
+// server in quiet mode, so each server is visited once. Keys and values are
+// checked as by Set; if one is rejected, nothing is sent.
+//
+// If a server declines to store items, SetMany returns ErrNotStored once the
+// batch was sent; the other items are stored. If a server fails or the
+// context is cancelled, SetMany returns the error; items on servers
+// processed before stay stored.
+func (c *Client) SetMany(ctx context.Context, items ...cache.Item) error {
+	now := time.Now()
+	data := make([][]byte, len(items))
+	groups := make(map[*server][]int)
+
+	var notStored error
+
+	for i, item := range items {
+		if err := checkKeys(item.Key); err != nil {
+			return err
+		}
+
+		var err error
+		if data[i], err = cache.EncodeValue(item.Value); err != nil {
+			return err
+		}
+
+		srv := c.pick(item.Key)
+		groups[srv] = append(groups[srv], i)
+	}
+
+	for srv, indexes := range groups {
+		err := c.exchange(ctx, srv, func(cn *conn) error {
+			for _, i := range indexes {
+				if err := writeMeta(cn.writer, "ms", items[i].Key, data[i], ttlFlag(items[i].TTL, now), "q"); err != nil {
+					return err
+				}
+			}
+
+			if _, err := cn.writer.WriteString("mn\r\n"); err != nil {
+				return err
+			}
+
+			if err := cn.writer.Flush(); err != nil {
+				return err
+			}
+
+			return drainQuiet(cn, "ms")
+		})
+
+		switch {
+		case errors.Is(err, ErrNotStored):
+			if notStored == nil {
+				notStored = err
+			}
+		case err != nil:
+			return err
+		}
+	}
+
+	return notStored
+}
+
+// ttlFlag returns the ms flag setting the expiry of an item stored at now with
+// ttl.
+func ttlFlag(ttl time.Duration, now time.Time) string {
+	return "T" + strconv.FormatInt(exptime(ttl, now), 10)
+}
+
+// Get returns the value stored under key as []byte, or cache.ErrNotFound if
+// the key is missing or expired.
+func (c *Client) Get(ctx context.Context, key string) (any, error) {
+	if err := checkKeys(key); err != nil {
+		return nil, err
+	}
+
+	var value []byte
+
+	err := c.exchange(ctx, c.pick(key), func(cn *conn) error {
+		if err := writeMeta(cn.writer, "mg", key, nil, "v"); err != nil {
+			return err
+		}
+
+		if err := cn.writer.Flush(); err != nil {
+			return err
+		}
+
+		rep, err := readReply(cn.reader)
+		if err != nil {
+			return err
+		}
+
+		switch rep.code {
+		case "VA":
+			value = rep.value
+			return nil
+		case "EN":
+			return cache.ErrNotFound
+		default:
+			return fmt.Errorf("%w: mg replied %s", ErrProtocol, rep.code)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// TTL returns the time left until key expires, rounded up to whole seconds by
+// the server, 0 if it does not expire, or cache.ErrNotFound if it is missing.
+// It reads the t flag of mg, so the value does not travel over the network.
+func (c *Client) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if err := checkKeys(key); err != nil {
+		return 0, err
+	}
+
+	var ttl time.Duration
+
+	err := c.exchange(ctx, c.pick(key), func(cn *conn) error {
+		if err := writeMeta(cn.writer, "mg", key, nil, "t"); err != nil {
+			return err
+		}
+
+		if err := cn.writer.Flush(); err != nil {
+			return err
+		}
+
+		rep, err := readReply(cn.reader)
+		if err != nil {
+			return err
+		}
+
+		switch rep.code {
+		case "HD":
+			ttl, err = replyTTL(rep)
+			return err
+		case "EN":
+			return cache.ErrNotFound
+		default:
+			return fmt.Errorf("%w: mg replied %s", ErrProtocol, rep.code)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return ttl, nil
+}
+
+// replyTTL parses the t return flag of rep: the seconds left, or -1 for
+// items that do not expire.
+func replyTTL(rep reply) (time.Duration, error) {
+	value, ok := rep.flag('t')
+	if !ok {
+		return 0, fmt.Errorf("%w: mg replied without t flag", ErrProtocol)
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid ttl %q", ErrProtocol, value)
+	}
+
+	if seconds < 0 {
+		return 0, nil
+	}
+
+	return max(time.Duration(seconds)*time.Second, time.Nanosecond), nil
+}
+
+// GetMany returns the values stored under keys as []byte, and the keys that
+// are missing or expired. Reads are pipelined per server, so each server is
+// visited once.
+//
+// If a server fails or the context is cancelled, GetMany returns the error.
+func (c *Client) GetMany(ctx context.Context, keys ...string) (map[string]any, []string, error) {
+	if err := checkKeys(keys...); err != nil {
+		return nil, nil, err
+	}
+
+	groups := make(map[*server][]string)
+	for _, key := range keys {
+		srv := c.pick(key)
+		groups[srv] = append(groups[srv], key)
+	}
+
+	found := make(map[string]any, len(keys))
+
+	for srv, srvKeys := range groups {
+		err := c.exchange(ctx, srv, func(cn *conn) error {
+			for _, key := range srvKeys {
+				if err := writeMeta(cn.writer, "mg", key, nil, "v"); err != nil {
+					return err
+				}
+			}
+
+			if err := cn.writer.Flush(); err != nil {
+				return err
+			}
+
+			return readValues(cn, srvKeys, found)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var missing []string
+
+	for _, key := range keys {
+		if _, ok := found[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	return found, missing, nil
+}
+
+// readValues reads the replies of pipelined mg commands for keys, storing
+// the values found. It returns the first server error after all replies were
+// consumed.
+func readValues(cn *conn, keys []string, found map[string]any) error {
+	var firstErr error
+
+	for _, key := range keys {
+		rep, err := readReply(cn.reader)
+		if err != nil {
+			var serverErr Error
+			if !errors.As(err, &serverErr) {
+				return err
+			}
+
+			if firstErr == nil {
+				firstErr = err
+			}
+
+			continue
+		}
+
+		switch rep.code {
+		case "VA":
+			found[key] = rep.value
+		case "EN":
+			// Missing or expired; reported by GetMany.
+		default:
+			return fmt.Errorf("%w: mg replied %s", ErrProtocol, rep.code)
+		}
+	}
+
+	return firstErr
+}
+
+// Delete removes keys. Deletes are pipelined per server in quiet mode, so
+// each server is visited once. Missing keys are ignored.
+//
+// If the context is cancelled, Delete returns an error wrapping
+// cache.ErrAborted; keys on servers processed before the cancellation stay
+// deleted.
+func (c *Client) Delete(ctx context.Context, keys ...string) error {
+	if err := checkKeys(keys...); err != nil {
+		return err
+	}
+
+	groups := make(map[*server][]string)
+	for _, key := range keys {
+		srv := c.pick(key)
+		groups[srv] = append(groups[srv], key)
+	}
+
+	for srv, srvKeys := range groups {
+		err := c.exchange(ctx, srv, func(cn *conn) error {
+			for _, key := range srvKeys {
+				if err := writeMeta(cn.writer, "md", key, nil, "q"); err != nil {
+					return err
+				}
+			}
+
+			if _, err := cn.writer.WriteString("mn\r\n"); err != nil {
+				return err
+			}
+
+			if err := cn.writer.Flush(); err != nil {
+				return err
+			}
+
+			return drainQuiet(cn, "md")
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteMany removes keys as Delete does and returns the number of keys that
+// were present. Deletes are pipelined per server, but not in quiet mode, so
+// that every server reports which keys it held.
+//
+// If the context is cancelled, DeleteMany returns an error wrapping
+// cache.ErrAborted along with the number of keys removed so far.
+func (c *Client) DeleteMany(ctx context.Context, keys ...string) (int, error) {
+	if err := checkKeys(keys...); err != nil {
+		return 0, err
+	}
+
+	groups := make(map[*server][]string)
+	for _, key := range keys {
+		srv := c.pick(key)
+		groups[srv] = append(groups[srv], key)
+	}
+
+	total := 0
+
+	for srv, srvKeys := range groups {
+		err := c.exchange(ctx, srv, func(cn *conn) error {
+			for _, key := range srvKeys {
+				if err := writeMeta(cn.writer, "md", key, nil); err != nil {
+					return err
+				}
+			}
+
+			if err := cn.writer.Flush(); err != nil {
+				return err
+			}
+
+			deleted, err := countDeleted(cn, len(srvKeys))
+			total += deleted
+
+			return err
+		})
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// countDeleted reads the replies of n md commands and returns the number of
+// HD replies, returning the first server error after every reply was read.
+func countDeleted(cn *conn, n int) (int, error) {
+	var firstErr error
+
+	deleted := 0
+
+	for range n {
+		rep, err := readReply(cn.reader)
+		if err != nil {
+			var serverErr Error
+			if !errors.As(err, &serverErr) {
+				return deleted, err
+			}
+
+			if firstErr == nil {
+				firstErr = err
+			}
+
+			continue
+		}
+
+		switch rep.code {
+		case "HD":
+			deleted++
+		case "NF":
+		default:
+			return deleted, fmt.Errorf("%w: md replied %s", ErrProtocol, rep.code)
+		}
+	}
+
+	return deleted, firstErr
+}
+
+// drainQuiet reads the replies of quiet-mode cmd commands up to the closing
+// MN. Quiet commands only reply when they fail: NS, a store the server
+// declined, is reported as ErrNotStored and, for md, NF is ignored. Server
+// errors and ErrNotStored are returned after the whole batch was consumed;
+// any other reply is an ErrProtocol.
+func drainQuiet(cn *conn, cmd string) error {
+	var (
+		firstErr  error
+		notStored int
+	)
+
+	for {
+		rep, err := readReply(cn.reader)
+		if err != nil {
+			var serverErr Error
+			if !errors.As(err, &serverErr) {
+				return err
+			}
+
+			if firstErr == nil {
+				firstErr = err
+			}
+
+			continue
+		}
+
+		switch {
+		case rep.code == "MN":
+			if firstErr == nil && notStored > 0 {
+				firstErr = fmt.Errorf("%w: %d items", ErrNotStored, notStored)
+			}
+
+			return firstErr
+		case rep.code == "NS" && cmd == "ms":
+			notStored++
+		case rep.code == "NF" && cmd == "md":
+		default:
+			return fmt.Errorf("%w: %s replied %s", ErrProtocol, cmd, rep.code)
+		}
+	}
+}
+
+// Digest returns the FNV-64a fingerprint of the bytes stored under key, or 0
+// if the key is missing or cannot be read. memcached has no server-side
+// hashing, so the value is fetched and hashed locally; the result matches
+// cache.DigestOf for the same bytes.
+func (c *Client) Digest(ctx context.Context, key string) cache.Digest {
+	value, err := c.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, cache.ErrNotFound) {
+			c.log.Error().Err(err).Str("key", key).Msg("memcached digest failed")
+		}
+
+		return 0
+	}
+
+	return cache.DigestOf(value)
+}
+
+// Close closes idle connections of all servers; connections in use are
+// closed when released. It is safe to call Close multiple times.
+func (c *Client) Close(_ context.Context) error {
+	for _, srv := range c.servers {
+		srv.close()
+	}
+
+	return nil
+}
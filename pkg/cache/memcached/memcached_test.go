@@ -0,0 +1,401 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memcached_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/memcached"
+	"github.com/patraden/toolkit/pkg/cache/memcached/memcachedtest"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ cache.Cache = (*memcached.Client)(nil)
+
+func Logger(t *testing.T) zerolog.Logger {
+	t.Helper()
+
+	return zerolog.New(os.Stdout).With().Timestamp().Caller().Logger().Level(zerolog.DebugLevel)
+}
+
+func newServers(t *testing.T, n int) []*memcachedtest.Server {
+	t.Helper()
+
+	servers := make([]*memcachedtest.Server, n)
+	for i := range servers {
+		servers[i] = memcachedtest.NewServer()
+		t.Cleanup(servers[i].Close)
+	}
+
+	return servers
+}
+
+func newClient(t *testing.T, servers []*memcachedtest.Server, opts ...memcached.Option) *memcached.Client {
+	t.Helper()
+
+	addrs := make([]string, len(servers))
+	for i, srv := range servers {
+		addrs[i] = srv.Addr()
+	}
+
+	client := memcached.New(addrs, Logger(t), opts...)
+
+	t.Cleanup(func() {
+		require.NoError(t, client.Close(t.Context()))
+	})
+
+	return client
+}
+
+func TestClientCommands(t *testing.T) {
+	t.Parallel()
+
+	client := newClient(t, newServers(t, 1))
+	ctx := t.Context()
+
+	require.NoError(t, client.Set(ctx, "str", "value", 0))
+	require.NoError(t, client.Set(ctx, "int", 42, 0))
+	require.NoError(t, client.Set(ctx, "bytes", []byte{0, '\r', '\n'}, 0))
+	require.NoError(t, client.Set(ctx, "key with spaces", "encoded", 0))
+
+	v, err := client.Get(ctx, "str")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), v)
+
+	v, err = client.Get(ctx, "int")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("42"), v)
+
+	v, err = client.Get(ctx, "bytes")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, '\r', '\n'}, v)
+
+	v, err = client.Get(ctx, "key with spaces")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("encoded"), v)
+
+	_, err = client.Get(ctx, "missing")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	require.NoError(t, client.Delete(ctx, "str", "int", "missing", "key with spaces"))
+	require.NoError(t, client.Delete(ctx))
+
+	for _, key := range []string{"str", "int", "key with spaces"} {
+		_, err = client.Get(ctx, key)
+		require.ErrorIs(t, err, cache.ErrNotFound)
+	}
+
+	_, err = client.Get(ctx, "bytes")
+	require.NoError(t, err)
+}
+
+func TestClientTTL(t *testing.T) {
+	t.Parallel()
+
+	servers := newServers(t, 1)
+	client := newClient(t, servers)
+	ctx := t.Context()
+
+	require.NoError(t, client.Set(ctx, "short", "v", 1500*time.Millisecond))
+	require.NoError(t, client.Set(ctx, "long", "v", 60*24*time.Hour))
+	require.NoError(t, client.Set(ctx, "forever", "v", 0))
+
+	expiry, ok := servers[0].Expiry("long")
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(60*24*time.Hour), expiry, 2*time.Second,
+		"TTLs over 30 days are sent as absolute time")
+
+	ttl, err := client.TTL(ctx, "short")
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, ttl, "the server rounds up to whole seconds")
+
+	ttl, err = client.TTL(ctx, "forever")
+	require.NoError(t, err)
+	assert.Zero(t, ttl)
+
+	servers[0].FastForward(2 * time.Second)
+
+	_, err = client.Get(ctx, "short")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	_, err = client.TTL(ctx, "short")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	_, err = client.Get(ctx, "long")
+	require.NoError(t, err)
+
+	servers[0].FastForward(60 * 24 * time.Hour)
+
+	_, err = client.Get(ctx, "long")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	_, err = client.Get(ctx, "forever")
+	require.NoError(t, err)
+}
+
+func TestClientSpreadsKeys(t *testing.T) {
+	t.Parallel()
+
+	servers := newServers(t, 3)
+	client := newClient(t, servers)
+	ctx := t.Context()
+
+	const keys = 300
+
+	for i := range keys {
+		require.NoError(t, client.Set(ctx, fmt.Sprintf("k-%d", i), i, 0))
+	}
+
+	total := 0
+
+	for _, srv := range servers {
+		assert.Greater(t, srv.Keys(), keys/10, "every server owns a share of the keys")
+
+		total += srv.Keys()
+	}
+
+	assert.Equal(t, keys, total, "each key lives on exactly one server")
+
+	// A client over a subset of the servers still finds the keys those
+	// servers own.
+	subset := newClient(t, servers[:2])
+	found := 0
+
+	for i := range keys {
+		if _, err := subset.Get(ctx, fmt.Sprintf("k-%d", i)); err == nil {
+			found++
+		}
+	}
+
+	assert.Equal(t, servers[0].Keys()+servers[1].Keys(), found)
+
+	keysToDelete := make([]string, keys)
+	for i := range keysToDelete {
+		keysToDelete[i] = fmt.Sprintf("k-%d", i)
+	}
+
+	before := 0
+	for _, srv := range servers {
+		before += srv.Commands()
+	}
+
+	require.NoError(t, client.Delete(ctx, keysToDelete...))
+
+	after := 0
+
+	for _, srv := range servers {
+		assert.Equal(t, 0, srv.Keys())
+
+		after += srv.Commands()
+	}
+
+	assert.Equal(t, keys+len(servers), after-before, "one md per key plus one mn per server")
+}
+
+func TestClientBatch(t *testing.T) {
+	t.Parallel()
+
+	servers := newServers(t, 3)
+	client := newClient(t, servers)
+	ctx := t.Context()
+
+	const keys = 100
+
+	items := make([]cache.Item, keys)
+	names := make([]string, 0, keys+1)
+
+	for i := range items {
+		items[i] = cache.Item{Key: fmt.Sprintf("k-%d", i), Value: i, TTL: time.Duration(i%2) * time.Hour}
+		names = append(names, items[i].Key)
+	}
+
+	names = append(names, "key with spaces")
+
+	require.NoError(t, client.SetMany(ctx, items...))
+
+	commands := 0
+	for _, srv := range servers {
+		commands += srv.Commands()
+	}
+
+	assert.Equal(t, keys+len(servers), commands, "one ms per item plus one mn per server")
+
+	var expiry time.Time
+
+	for _, srv := range servers {
+		if at, ok := srv.Expiry(items[1].Key); ok {
+			expiry = at
+		}
+	}
+
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiry, 2*time.Second, "items keep their own TTL")
+
+	found, missing, err := client.GetMany(ctx, names...)
+	require.NoError(t, err)
+	assert.Len(t, found, keys)
+	assert.Equal(t, []byte("42"), found["k-42"])
+	assert.Equal(t, []string{"key with spaces"}, missing)
+
+	after := 0
+	for _, srv := range servers {
+		after += srv.Commands()
+	}
+
+	assert.Equal(t, keys+1, after-commands, "one mg per key")
+
+	err = client.SetMany(ctx,
+		cache.Item{Key: "ok", Value: "v", TTL: 0},
+		cache.Item{Key: "bad", Value: struct{}{}, TTL: 0},
+	)
+	require.ErrorIs(t, err, cache.ErrType)
+
+	_, err = client.Get(ctx, "ok")
+	require.ErrorIs(t, err, cache.ErrNotFound, "rejected batches send nothing")
+
+	err = client.SetMany(ctx,
+		cache.Item{Key: "ok", Value: "v", TTL: 0},
+		cache.Item{Key: "", Value: "v", TTL: 0},
+	)
+	require.ErrorIs(t, err, memcached.ErrInvalidKey)
+
+	_, err = client.Get(ctx, "ok")
+	require.ErrorIs(t, err, cache.ErrNotFound, "invalid keys reject the batch")
+
+	for _, srv := range servers {
+		srv.Reject("declined")
+	}
+
+	err = client.SetMany(ctx,
+		cache.Item{Key: "ok", Value: "v", TTL: 0},
+		cache.Item{Key: "declined", Value: "v", TTL: 0},
+	)
+	require.ErrorIs(t, err, memcached.ErrNotStored, "quiet NS replies are reported")
+
+	_, err = client.Get(ctx, "ok")
+	require.NoError(t, err, "the other items are stored")
+	require.ErrorIs(t, client.Set(ctx, "declined", "v", 0), memcached.ErrNotStored)
+
+	deleted, err := client.DeleteMany(ctx, names...)
+	require.NoError(t, err)
+	assert.Equal(t, keys, deleted, "missing keys are not counted")
+
+	found, _, err = client.GetMany(ctx, names...)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+func TestClientDigest(t *testing.T) {
+	t.Parallel()
+
+	client := newClient(t, newServers(t, 2))
+	ctx := t.Context()
+
+	require.NoError(t, client.Set(ctx, "str", "value", 0))
+	require.NoError(t, client.Set(ctx, "num", 12345, 0))
+
+	assert.Equal(t, cache.DigestOf("value"), client.Digest(ctx, "str"))
+	assert.Equal(t, cache.DigestOf(12345), client.Digest(ctx, "num"))
+	assert.Equal(t, cache.Digest(0), client.Digest(ctx, "missing"))
+}
+
+func TestClientPool(t *testing.T) {
+	t.Parallel()
+
+	client := newClient(t, newServers(t, 2), memcached.WithPoolSize(2))
+
+	var wg sync.WaitGroup
+
+	for i := range 50 {
+		wg.Add(1)
+
+		go func(n int) {
+			defer wg.Done()
+
+			key := fmt.Sprintf("k-%d", n)
+			assert.NoError(t, client.Set(t.Context(), key, n, time.Minute))
+
+			v, err := client.Get(t.Context(), key)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte(fmt.Sprint(n)), v)
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func TestClientErrors(t *testing.T) {
+	t.Parallel()
+
+	servers := newServers(t, 1)
+	client := memcached.New([]string{servers[0].Addr()}, Logger(t))
+	ctx := t.Context()
+
+	err := client.Set(ctx, "struct", struct{}{}, 0)
+	require.ErrorIs(t, err, cache.ErrType)
+
+	require.ErrorIs(t, client.Set(ctx, "", "v", 0), memcached.ErrInvalidKey)
+
+	_, err = client.Get(ctx, strings.Repeat("k", 251))
+	require.ErrorIs(t, err, memcached.ErrInvalidKey)
+
+	require.NoError(t, client.Set(ctx, "empty", []byte(nil), 0))
+
+	value, err := client.Get(ctx, "empty")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{}, value, "empty values are sent with their length")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err = client.Get(cancelled, "key")
+	require.ErrorIs(t, err, cache.ErrAborted)
+
+	err = client.Delete(cancelled, "key")
+	require.ErrorIs(t, err, cache.ErrAborted)
+
+	require.NoError(t, client.Close(ctx))
+	require.NoError(t, client.Close(ctx))
+
+	_, err = client.Get(ctx, "key")
+	require.ErrorIs(t, err, memcached.ErrClosed)
+
+	assert.Panics(t, func() { memcached.New(nil, Logger(t)) })
+}
+
+func TestClientTyped(t *testing.T) {
+	t.Parallel()
+
+	type point struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+
+	points := cache.NewTypedWithCodec[point](newClient(t, newServers(t, 2)), cache.JSONCodec{})
+
+	require.NoError(t, points.Set(t.Context(), "p", point{X: 1, Y: 2}, 0))
+
+	got, err := points.Get(t.Context(), "p")
+	require.NoError(t, err)
+	assert.Equal(t, point{X: 1, Y: 2}, got)
+}
@@ -0,0 +1,445 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memcachedtest provides a local fake memcached server for tests.
+//
+// The server speaks the subset of the meta text protocol used by the
+// memcached package: mg (flags v, k, t, b, q), ms (flags T, b, q), md (flags b,
+// q), mn and version. Expiry follows memcached: exptime values up to 30 days
+// are relative seconds, larger values are absolute Unix timestamps, and
+// negative values expire the item immediately.
+package memcachedtest
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRelativeExptime is the largest exptime treated as relative seconds.
+const maxRelativeExptime = 60 * 60 * 24 * 30
+
+type item struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// Server is a fake memcached server listening on a loopback address.
+type Server struct {
+	listener net.Listener
+	data     map[string]item
+	rejected map[string]struct{}
+	conns    map[net.Conn]struct{}
+	offset   time.Duration
+	commands int
+	wg       sync.WaitGroup
+	mx       sync.Mutex
+}
+
+// NewServer starts a Server on a random loopback port. It panics if it cannot
+// listen, like httptest.NewServer.
+func NewServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("memcachedtest: failed to listen: %v", err))
+	}
+
+	srv := &Server{
+		listener: listener,
+		data:     make(map[string]item),
+		rejected: make(map[string]struct{}),
+		conns:    make(map[net.Conn]struct{}),
+		offset:   0,
+		commands: 0,
+		wg:       sync.WaitGroup{},
+		mx:       sync.Mutex{},
+	}
+
+	srv.wg.Add(1)
+
+	go srv.serve()
+
+	return srv
+}
+
+// Addr returns the host:port the server listens on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server and closes all client connections.
+func (s *Server) Close() {
+	_ = s.listener.Close()
+
+	s.mx.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mx.Unlock()
+
+	s.wg.Wait()
+}
+
+// FastForward moves the server clock forward by d, expiring items whose
+// exptime passes.
+func (s *Server) FastForward(d time.Duration) {
+	s.mx.Lock()
+	s.offset += d
+	s.mx.Unlock()
+}
+
+// Reject makes the server decline to store keys, replying NS to ms even in
+// quiet mode, as memcached does for stores it cannot perform.
+func (s *Server) Reject(keys ...string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for _, key := range keys {
+		s.rejected[key] = struct{}{}
+	}
+}
+
+// Commands returns the number of commands the server has processed.
+func (s *Server) Commands() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	return s.commands
+}
+
+// Keys returns the number of live items stored on the server.
+func (s *Server) Keys() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	now := s.now()
+	count := 0
+
+	for _, it := range s.data {
+		if !it.expired(now) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Expiry returns the expiry time of key on the server clock and whether key
+// is stored. A zero time means the item does not expire.
+func (s *Server) Expiry(key string) (time.Time, bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	it, ok := s.data[key]
+	if !ok || it.expired(s.now()) {
+		return time.Time{}, false
+	}
+
+	return it.expiresAt, true
+}
+
+// now returns the server clock. The caller must hold mx.
+func (s *Server) now() time.Time {
+	return time.Now().Add(s.offset)
+}
+
+// remaining returns the seconds it has left at now, rounded up, or -1 if it
+// does not expire, as reported by the mg t flag.
+func remaining(it item, now time.Time) int64 {
+	if it.expiresAt.IsZero() {
+		return -1
+	}
+
+	return int64(math.Ceil(it.expiresAt.Sub(now).Seconds()))
+}
+
+func (it item) expired(now time.Time) bool {
+	return !it.expiresAt.IsZero() && !now.Before(it.expiresAt)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mx.Lock()
+		s.conns[conn] = struct{}{}
+		s.mx.Unlock()
+
+		s.wg.Add(1)
+
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mx.Lock()
+		delete(s.conns, conn)
+		s.mx.Unlock()
+
+		_ = conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				_, _ = writer.WriteString("CLIENT_ERROR " + err.Error() + "\r\n")
+				_ = writer.Flush()
+			}
+
+			return
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		quit, err := s.dispatch(reader, writer, fields)
+		if err != nil {
+			return
+		}
+
+		// Like memcached, replies are flushed once the pipelined input is
+		// consumed.
+		if reader.Buffered() == 0 || quit {
+			if err := writer.Flush(); err != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+// request is a parsed meta command.
+type request struct {
+	key   string
+	flags map[byte]string
+	quiet bool
+}
+
+func parseRequest(args []string) (request, error) {
+	req := request{key: "", flags: make(map[byte]string, len(args)), quiet: false}
+
+	if len(args) == 0 {
+		return req, errors.New("missing key")
+	}
+
+	for _, flag := range args[1:] {
+		req.flags[flag[0]] = flag[1:]
+	}
+
+	_, req.quiet = req.flags['q']
+	req.key = args[0]
+
+	if _, ok := req.flags['b']; ok {
+		key, err := base64.StdEncoding.DecodeString(args[0])
+		if err != nil {
+			return req, errors.New("bad base64 key")
+		}
+
+		req.key = string(key)
+	}
+
+	return req, nil
+}
+
+// dispatch runs one command and reports whether the connection must close.
+// A returned error means the connection is unusable.
+func (s *Server) dispatch(reader *bufio.Reader, writer *bufio.Writer, fields []string) (bool, error) {
+	s.mx.Lock()
+	s.commands++
+	s.mx.Unlock()
+
+	var reply string
+
+	switch fields[0] {
+	case "mg":
+		reply = s.metaGet(fields[1:])
+	case "ms":
+		var err error
+
+		reply, err = s.metaSet(reader, fields[1:])
+		if err != nil {
+			return true, err
+		}
+	case "md":
+		reply = s.metaDelete(fields[1:])
+	case "mn":
+		reply = "MN\r\n"
+	case "version":
+		reply = "VERSION 1.6.0-memcachedtest\r\n"
+	case "quit":
+		return true, nil
+	default:
+		reply = "ERROR\r\n"
+	}
+
+	_, err := writer.WriteString(reply)
+
+	return false, err
+}
+
+func (s *Server) metaGet(args []string) string {
+	req, err := parseRequest(args)
+	if err != nil {
+		return "CLIENT_ERROR " + err.Error() + "\r\n"
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	it, ok := s.data[req.key]
+	if ok && it.expired(s.now()) {
+		delete(s.data, req.key)
+
+		ok = false
+	}
+
+	if !ok {
+		if req.quiet {
+			return ""
+		}
+
+		return "EN\r\n"
+	}
+
+	var ret strings.Builder
+
+	if _, ok := req.flags['k']; ok {
+		ret.WriteString(" k" + args[0])
+
+		if _, ok := req.flags['b']; ok {
+			ret.WriteString(" b")
+		}
+	}
+
+	if _, ok := req.flags['t']; ok {
+		ret.WriteString(" t" + strconv.FormatInt(remaining(it, s.now()), 10))
+	}
+
+	if _, ok := req.flags['v']; !ok {
+		return "HD" + ret.String() + "\r\n"
+	}
+
+	return "VA " + strconv.Itoa(len(it.value)) + ret.String() + "\r\n" + string(it.value) + "\r\n"
+}
+
+func (s *Server) metaSet(reader *bufio.Reader, args []string) (string, error) {
+	if len(args) < 2 { //nolint:mnd // ms <key> <datalen>
+		return "CLIENT_ERROR bad command line format\r\n", nil
+	}
+
+	size, err := strconv.Atoi(args[1])
+	if err != nil || size < 0 {
+		return "CLIENT_ERROR bad data chunk\r\n", nil
+	}
+
+	data := make([]byte, size+2) //nolint:mnd // CRLF
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return "", err
+	}
+
+	if data[size] != '\r' || data[size+1] != '\n' {
+		return "CLIENT_ERROR bad data chunk\r\n", nil
+	}
+
+	req, err := parseRequest(append(args[:1:1], args[2:]...))
+	if err != nil {
+		return "CLIENT_ERROR " + err.Error() + "\r\n", nil
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	it := item{value: data[:size], expiresAt: time.Time{}}
+
+	if ttl, ok := req.flags['T']; ok {
+		exptime, err := strconv.ParseInt(ttl, 10, 64)
+		if err != nil {
+			return "CLIENT_ERROR bad token in command line format\r\n", nil
+		}
+
+		it.expiresAt = s.expiresAt(exptime)
+	}
+
+	if _, ok := s.rejected[req.key]; ok {
+		return "NS\r\n", nil
+	}
+
+	s.data[req.key] = it
+
+	if req.quiet {
+		return "", nil
+	}
+
+	return "HD\r\n", nil
+}
+
+// expiresAt converts exptime to an expiry time. The caller must hold mx.
+func (s *Server) expiresAt(exptime int64) time.Time {
+	now := s.now()
+
+	switch {
+	case exptime == 0:
+		return time.Time{}
+	case exptime < 0:
+		return now
+	case exptime <= maxRelativeExptime:
+		return now.Add(time.Duration(exptime) * time.Second)
+	default:
+		return time.Unix(exptime, 0)
+	}
+}
+
+func (s *Server) metaDelete(args []string) string {
+	req, err := parseRequest(args)
+	if err != nil {
+		return "CLIENT_ERROR " + err.Error() + "\r\n"
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	it, ok := s.data[req.key]
+	if ok {
+		delete(s.data, req.key)
+	}
+
+	switch {
+	case req.quiet:
+		return ""
+	case ok && !it.expired(s.now()):
+		return "HD\r\n"
+	default:
+		return "NF\r\n"
+	}
+}
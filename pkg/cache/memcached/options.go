@@ -0,0 +1,67 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memcached
+
+import "time"
+
+const (
+	// DefaultPoolSize is the default maximum number of open connections per
+	// server.
+	DefaultPoolSize = 4
+	// DefaultDialTimeout bounds connection establishment when the caller's
+	// context has no earlier deadline.
+	DefaultDialTimeout = 5 * time.Second
+)
+
+// Option configures a Client.
+type Option func(*options)
+
+type options struct {
+	poolSize    int
+	dialTimeout time.Duration
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		poolSize:    DefaultPoolSize,
+		dialTimeout: DefaultDialTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithPoolSize sets the maximum number of open connections per server.
+// Values <= 0 keep DefaultPoolSize.
+func WithPoolSize(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.poolSize = n
+		}
+	}
+}
+
+// WithDialTimeout sets the connection establishment timeout. Values <= 0 keep
+// DefaultDialTimeout.
+func WithDialTimeout(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.dialTimeout = d
+		}
+	}
+}
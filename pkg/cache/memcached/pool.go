@@ -0,0 +1,157 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memcached
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// conn is a single connection to a server.
+type conn struct {
+	netConn net.Conn
+	reader  *bufio.Reader
+	writer  *bufio.Writer
+}
+
+// arm applies the deadline of ctx to the connection and interrupts pending
+// I/O when ctx is cancelled. The returned func must be called when the
+// exchange is over.
+func (c *conn) arm(ctx context.Context) (func() bool, error) {
+	deadline, _ := ctx.Deadline()
+	if err := c.netConn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	return context.AfterFunc(ctx, func() {
+		// Unblock pending I/O; the connection is discarded afterwards.
+		_ = c.netConn.SetDeadline(time.Now())
+	}), nil
+}
+
+// server is a bounded pool of connections to one memcached server.
+type server struct {
+	idle   chan *conn
+	slots  chan struct{}
+	done   chan struct{}
+	addr   string
+	opts   options
+	mx     sync.Mutex
+	closed bool
+}
+
+func newServer(addr string, opts options) *server {
+	return &server{
+		idle:   make(chan *conn, opts.poolSize),
+		slots:  make(chan struct{}, opts.poolSize),
+		done:   make(chan struct{}),
+		addr:   addr,
+		opts:   opts,
+		mx:     sync.Mutex{},
+		closed: false,
+	}
+}
+
+// get returns an idle connection or dials a new one while the pool has free
+// slots, otherwise it waits for a connection to be released.
+func (s *server) get(ctx context.Context) (*conn, error) {
+	select {
+	case <-s.done:
+		return nil, ErrClosed
+	case cn := <-s.idle:
+		return cn, nil
+	default:
+	}
+
+	select {
+	case cn := <-s.idle:
+		return cn, nil
+	case s.slots <- struct{}{}:
+		cn, err := s.dial(ctx)
+		if err != nil {
+			<-s.slots
+			return nil, err
+		}
+
+		return cn, nil
+	case <-s.done:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// put returns cn to the pool. Broken connections are closed and free their
+// slot.
+func (s *server) put(cn *conn, broken bool) {
+	s.mx.Lock()
+	if !broken && !s.closed {
+		select {
+		case s.idle <- cn:
+			s.mx.Unlock()
+			return
+		default:
+		}
+	}
+	s.mx.Unlock()
+
+	_ = cn.netConn.Close()
+	<-s.slots
+}
+
+func (s *server) dial(ctx context.Context) (*conn, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, s.opts.dialTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+
+	netConn, err := dialer.DialContext(dialCtx, "tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn{
+		netConn: netConn,
+		reader:  bufio.NewReader(netConn),
+		writer:  bufio.NewWriter(netConn),
+	}, nil
+}
+
+// close closes idle connections and makes the pool reject new requests.
+// Connections in use are closed when they are released.
+func (s *server) close() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	s.closed = true
+	close(s.done)
+
+	for {
+		select {
+		case cn := <-s.idle:
+			_ = cn.netConn.Close()
+			<-s.slots
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memcached
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxKeyLength is the longest key memcached accepts verbatim.
+	maxKeyLength = 250
+	// maxRelativeExptime is the largest exptime memcached treats as relative
+	// seconds; larger values are absolute Unix timestamps.
+	maxRelativeExptime = 60 * 60 * 24 * 30
+)
+
+var (
+	// ErrProtocol indicates a malformed or unexpected server reply.
+	ErrProtocol = errors.New("memcached protocol error")
+	// ErrClosed indicates the client was closed.
+	ErrClosed = errors.New("memcached client closed")
+	// ErrNotStored indicates the server declined to store a value.
+	ErrNotStored = errors.New("memcached value not stored")
+	// ErrInvalidKey indicates a key memcached cannot carry: an empty key, or
+	// one longer than 250 bytes as sent on the wire.
+	ErrInvalidKey = errors.New("memcached invalid key")
+)
+
+// Error is an error line sent by the server, such as "CLIENT_ERROR bad data
+// chunk" or "SERVER_ERROR out of memory".
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// wireKey returns key as sent on the wire and whether it is base64-encoded.
+// Keys containing spaces or control characters are base64-encoded. Empty
+// keys and keys longer than maxKeyLength on the wire are rejected with
+// ErrInvalidKey.
+func wireKey(key string) (string, bool, error) {
+	if len(key) == 0 {
+		return "", false, fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+
+	wk, encoded := key, false
+
+	for i := range len(key) {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			wk, encoded = base64.StdEncoding.EncodeToString([]byte(key)), true
+			break
+		}
+	}
+
+	if len(wk) > maxKeyLength {
+		return "", false, fmt.Errorf("%w: %d bytes on the wire, at most %d", ErrInvalidKey, len(wk), maxKeyLength)
+	}
+
+	return wk, encoded, nil
+}
+
+// checkKeys returns the error of the first of keys wireKey rejects. Commands
+// check their keys before taking a connection, so that nothing is sent for a
+// batch holding an invalid key.
+func checkKeys(keys ...string) error {
+	for _, key := range keys {
+		if _, _, err := wireKey(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exptime converts a TTL to memcached exptime relative to now.
+func exptime(ttl time.Duration, now time.Time) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+
+	seconds := int64(math.Ceil(ttl.Seconds()))
+	if seconds <= maxRelativeExptime {
+		return seconds
+	}
+
+	return now.Add(ttl).Unix()
+}
+
+// writeMeta writes a meta command: cmd, the key, optional flags and, for ms,
+// the data length and block, even if data is empty.
+func writeMeta(w *bufio.Writer, cmd, key string, data []byte, flags ...string) error {
+	wk, encoded, err := wireKey(key)
+	if err != nil {
+		return err
+	}
+
+	store := cmd == "ms"
+
+	var sb strings.Builder
+
+	sb.WriteString(cmd)
+	sb.WriteByte(' ')
+	sb.WriteString(wk)
+
+	if store {
+		sb.WriteByte(' ')
+		sb.WriteString(strconv.Itoa(len(data)))
+	}
+
+	if encoded {
+		sb.WriteString(" b")
+	}
+
+	for _, flag := range flags {
+		sb.WriteByte(' ')
+		sb.WriteString(flag)
+	}
+
+	sb.WriteString("\r\n")
+
+	if _, err := w.WriteString(sb.String()); err != nil {
+		return err
+	}
+
+	if !store {
+		return nil
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	_, err = w.WriteString("\r\n")
+
+	return err
+}
+
+// reply is a parsed meta response line, its return flags and its optional
+// value block.
+type reply struct {
+	code  string
+	flags []string
+	value []byte
+}
+
+// flag returns the value of the return flag named name, and whether the
+// reply carries it.
+func (r reply) flag(name byte) (string, bool) {
+	for _, flag := range r.flags {
+		if flag != "" && flag[0] == name {
+			return flag[1:], true
+		}
+	}
+
+	return "", false
+}
+
+// readReply reads one meta response. Server error lines are returned as an
+// Error.
+func readReply(r *bufio.Reader) (reply, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return reply{}, err
+	}
+
+	if !strings.HasSuffix(line, "\r\n") {
+		return reply{}, fmt.Errorf("%w: line not terminated by CRLF", ErrProtocol)
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return reply{}, fmt.Errorf("%w: empty response", ErrProtocol)
+	}
+
+	switch fields[0] {
+	case "HD", "NF", "NS", "EX", "EN", "MN":
+		return reply{code: fields[0], flags: returnFlags(fields[1:]), value: nil}, nil
+	case "VA":
+		return readValue(r, fields)
+	case "ERROR", "CLIENT_ERROR", "SERVER_ERROR":
+		return reply{}, Error(strings.TrimSuffix(line, "\r\n"))
+	default:
+		return reply{}, fmt.Errorf("%w: unexpected response %q", ErrProtocol, fields[0])
+	}
+}
+
+// returnFlags returns the return flags of a response line, or nil if it has
+// none.
+func returnFlags(fields []string) []string {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return fields
+}
+
+func readValue(r *bufio.Reader, fields []string) (reply, error) {
+	if len(fields) < 2 { //nolint:mnd // VA <size>
+		return reply{}, fmt.Errorf("%w: VA without size", ErrProtocol)
+	}
+
+	size, err := strconv.Atoi(fields[1])
+	if err != nil || size < 0 {
+		return reply{}, fmt.Errorf("%w: invalid value size %q", ErrProtocol, fields[1])
+	}
+
+	data := make([]byte, size+2) //nolint:mnd // CRLF
+	if _, err := io.ReadFull(r, data); err != nil {
+		return reply{}, err
+	}
+
+	if data[size] != '\r' || data[size+1] != '\n' {
+		return reply{}, fmt.Errorf("%w: value not terminated by CRLF", ErrProtocol)
+	}
+
+	return reply{code: "VA", flags: returnFlags(fields[2:]), value: data[:size]}, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//nolint:testpackage // white-box tests require access to the meta protocol codec
+package memcached
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWireKey(t *testing.T) {
+	t.Parallel()
+
+	key, encoded, err := wireKey("user:42")
+	require.NoError(t, err)
+	assert.Equal(t, "user:42", key)
+	assert.False(t, encoded)
+
+	key, encoded, err = wireKey("with space")
+	require.NoError(t, err)
+	assert.Equal(t, "d2l0aCBzcGFjZQ==", key)
+	assert.True(t, encoded)
+
+	_, _, err = wireKey(strings.Repeat("k", maxKeyLength))
+	require.NoError(t, err)
+
+	for _, key := range []string{"", strings.Repeat("k", maxKeyLength+1), strings.Repeat(" ", 200)} {
+		_, _, err = wireKey(key)
+		require.ErrorIs(t, err, ErrInvalidKey, "%d bytes", len(key))
+	}
+}
+
+func TestExptime(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_700_000_000, 0)
+	month := 30 * 24 * time.Hour
+
+	assert.Equal(t, int64(0), exptime(0, now))
+	assert.Equal(t, int64(0), exptime(-time.Second, now))
+	assert.Equal(t, int64(1), exptime(time.Millisecond, now), "sub-second TTLs round up")
+	assert.Equal(t, int64(2), exptime(1500*time.Millisecond, now))
+	assert.Equal(t, int64(maxRelativeExptime), exptime(month, now), "30 days is still relative")
+	assert.Equal(t, now.Unix()+maxRelativeExptime+1, exptime(month+time.Second, now), "over 30 days is absolute")
+}
+
+func TestWriteMeta(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	w := bufio.NewWriter(&buf)
+	require.NoError(t, writeMeta(w, "ms", "key", []byte("a\r\nb"), "T10"))
+	require.NoError(t, writeMeta(w, "mg", "a b", nil, "v"))
+	require.NoError(t, writeMeta(w, "ms", "empty", nil))
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, "ms key 4 T10\r\na\r\nb\r\nmg YSBi b v\r\nms empty 0\r\n\r\n", buf.String())
+	require.ErrorIs(t, writeMeta(w, "mg", "", nil), ErrInvalidKey)
+}
+
+func TestReadReply(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  reply
+	}{
+		{name: "stored", input: "HD\r\n", want: reply{code: "HD", flags: nil, value: nil}},
+		{name: "miss", input: "EN\r\n", want: reply{code: "EN", flags: nil, value: nil}},
+		{name: "not found", input: "NF\r\n", want: reply{code: "NF", flags: nil, value: nil}},
+		{name: "noop", input: "MN\r\n", want: reply{code: "MN", flags: nil, value: nil}},
+		{name: "ttl", input: "HD t30\r\n", want: reply{code: "HD", flags: []string{"t30"}, value: nil}},
+		{
+			name:  "value",
+			input: "VA 4 t\r\na\r\nb\r\n",
+			want:  reply{code: "VA", flags: []string{"t"}, value: []byte("a\r\nb")},
+		},
+		{name: "empty value", input: "VA 0\r\n\r\n", want: reply{code: "VA", flags: nil, value: []byte{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := readReply(bufio.NewReader(strings.NewReader(tt.input)))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReadReplyErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := readReply(bufio.NewReader(strings.NewReader("SERVER_ERROR out of memory\r\n")))
+	assert.Equal(t, Error("SERVER_ERROR out of memory"), err)
+
+	for _, input := range []string{"HD\n", "\r\n", "XX\r\n", "VA\r\n", "VA x\r\n", "VA 2\r\nabcd"} {
+		_, err := readReply(bufio.NewReader(strings.NewReader(input)))
+		require.ErrorIs(t, err, ErrProtocol, input)
+	}
+
+	_, err = readReply(bufio.NewReader(strings.NewReader("VA 5\r\nab")))
+	require.Error(t, err)
+}
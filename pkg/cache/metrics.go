@@ -23,65 +23,227 @@ import (
 
 // Metrics tracks cache operations and evictions.
 //
-// All fields are updated atomically and are safe to read concurrently.
+// All fields are updated atomically and are safe to read concurrently. The
+// Recent fields count hits and misses over sliding windows of the last one and
+// five minutes; they are only filled in by Snapshot and Reset.
 type Metrics struct {
-	Hits                  uint32 `json:"hits"`
-	Misses                uint32 `json:"misses"`
-	Sets                  uint32 `json:"sets"`
-	Deletes               uint32 `json:"deletes"`
-	LazyEvictions         uint32 `json:"lazy_evictions"`           // Expired items found during Get
-	ScheduledEvictions    uint32 `json:"scheduled_evictions"`      // Expired items removed by cleaner
-	CleanupRuns           uint32 `json:"cleanup_runs"`             // Number of scheduled cleanup runs
-	LastCleanupDurationMs uint64 `json:"last_cleanup_duration_ms"` // Duration of last cleanup in milliseconds
-	LastCleanupItems      uint32 `json:"last_cleanup_items"`       // Items cleaned in last run
+	Hits                  uint64    `json:"hits"`
+	Misses                uint64    `json:"misses"`
+	Sets                  uint64    `json:"sets"`
+	Deletes               uint64    `json:"deletes"`
+	LazyEvictions         uint64    `json:"lazy_evictions"`           // Expired items found during Get
+	ScheduledEvictions    uint64    `json:"scheduled_evictions"`      // Expired items removed by cleaner
+	CapacityEvictions     uint64    `json:"capacity_evictions"`       // Live items evicted to respect max entries
+	PolicyAdmits          uint64    `json:"policy_admits"`            // Candidates admitted by the policy over a victim
+	PolicyRejects         uint64    `json:"policy_rejects"`           // Candidates rejected by the policy
+	Loads                 uint64    `json:"loads"`                    // Loader calls started by GetOrLoad
+	LoadDedups            uint64    `json:"load_dedups"`              // GetOrLoad misses served by an in-flight load
+	LoadErrors            uint64    `json:"load_errors"`              // Loader calls that returned an error
+	Refreshes             uint64    `json:"refreshes"`                // Background refreshes started
+	RefreshErrors         uint64    `json:"refresh_errors"`           // Background refreshes that failed
+	TagInvalidations      uint64    `json:"tag_invalidations"`        // Entries removed by InvalidateTags
+	EventDrops            uint64    `json:"event_drops"`              // Events dropped because a subscriber queue was full
+	Bytes                 uint64    `json:"bytes"`                    // Estimated size of stored entries
+	CleanupRuns           uint64    `json:"cleanup_runs"`             // Number of scheduled cleanup runs
+	LastCleanupDurationMs uint64    `json:"last_cleanup_duration_ms"` // Duration of last cleanup in milliseconds
+	LastCleanupItems      uint64    `json:"last_cleanup_items"`       // Items cleaned in last run
+	RecentHits1m          uint64    `json:"recent_hits_1m"`           // Hits in the last minute
+	RecentMisses1m        uint64    `json:"recent_misses_1m"`         // Misses in the last minute
+	RecentHits5m          uint64    `json:"recent_hits_5m"`           // Hits in the last five minutes
+	RecentMisses5m        uint64    `json:"recent_misses_5m"`         // Misses in the last five minutes
+	GetLatency            Histogram `json:"get_latency"`              // Latency of Get calls
+	SetLatency            Histogram `json:"set_latency"`              // Latency of writes
+	DeleteLatency         Histogram `json:"delete_latency"`           // Latency of Delete calls
+	CleanupLatency        Histogram `json:"cleanup_latency"`          // Duration of scheduled cleanup runs
+
+	window hitWindow
 }
 
 // Snapshot returns an atomic-load copy of all metrics fields.
 func (m *Metrics) Snapshot() Metrics {
+	return m.snapshot(time.Now())
+}
+
+// snapshot is Snapshot with the hit windows read at now.
+func (m *Metrics) snapshot(now time.Time) Metrics {
+	hits1m, misses1m := m.window.sum(now, time.Minute)
+	hits5m, misses5m := m.window.sum(now, windowSpan)
+
 	return Metrics{
-		Hits:                  atomic.LoadUint32(&m.Hits),
-		Misses:                atomic.LoadUint32(&m.Misses),
-		Sets:                  atomic.LoadUint32(&m.Sets),
-		Deletes:               atomic.LoadUint32(&m.Deletes),
-		LazyEvictions:         atomic.LoadUint32(&m.LazyEvictions),
-		ScheduledEvictions:    atomic.LoadUint32(&m.ScheduledEvictions),
-		CleanupRuns:           atomic.LoadUint32(&m.CleanupRuns),
+		Hits:                  atomic.LoadUint64(&m.Hits),
+		Misses:                atomic.LoadUint64(&m.Misses),
+		Sets:                  atomic.LoadUint64(&m.Sets),
+		Deletes:               atomic.LoadUint64(&m.Deletes),
+		LazyEvictions:         atomic.LoadUint64(&m.LazyEvictions),
+		ScheduledEvictions:    atomic.LoadUint64(&m.ScheduledEvictions),
+		CapacityEvictions:     atomic.LoadUint64(&m.CapacityEvictions),
+		PolicyAdmits:          atomic.LoadUint64(&m.PolicyAdmits),
+		PolicyRejects:         atomic.LoadUint64(&m.PolicyRejects),
+		Loads:                 atomic.LoadUint64(&m.Loads),
+		LoadDedups:            atomic.LoadUint64(&m.LoadDedups),
+		LoadErrors:            atomic.LoadUint64(&m.LoadErrors),
+		Refreshes:             atomic.LoadUint64(&m.Refreshes),
+		RefreshErrors:         atomic.LoadUint64(&m.RefreshErrors),
+		TagInvalidations:      atomic.LoadUint64(&m.TagInvalidations),
+		EventDrops:            atomic.LoadUint64(&m.EventDrops),
+		Bytes:                 atomic.LoadUint64(&m.Bytes),
+		CleanupRuns:           atomic.LoadUint64(&m.CleanupRuns),
 		LastCleanupDurationMs: atomic.LoadUint64(&m.LastCleanupDurationMs),
-		LastCleanupItems:      atomic.LoadUint32(&m.LastCleanupItems),
+		LastCleanupItems:      atomic.LoadUint64(&m.LastCleanupItems),
+		RecentHits1m:          hits1m,
+		RecentMisses1m:        misses1m,
+		RecentHits5m:          hits5m,
+		RecentMisses5m:        misses5m,
+		GetLatency:            m.GetLatency.Snapshot(),
+		SetLatency:            m.SetLatency.Snapshot(),
+		DeleteLatency:         m.DeleteLatency.Snapshot(),
+		CleanupLatency:        m.CleanupLatency.Snapshot(),
+		window:                hitWindow{},
+	}
+}
+
+// Reset zeroes all metrics but Bytes and returns a snapshot of their values
+// before the reset. Each field is swapped atomically; operations racing with
+// Reset are counted either before or after it.
+func (m *Metrics) Reset() Metrics {
+	return m.reset(time.Now())
+}
+
+// reset is Reset with the hit windows read at now.
+func (m *Metrics) reset(now time.Time) Metrics {
+	hits1m, misses1m := m.window.sum(now, time.Minute)
+	hits5m, misses5m := m.window.sum(now, windowSpan)
+
+	m.window.reset()
+
+	return Metrics{
+		Hits:                  atomic.SwapUint64(&m.Hits, 0),
+		Misses:                atomic.SwapUint64(&m.Misses, 0),
+		Sets:                  atomic.SwapUint64(&m.Sets, 0),
+		Deletes:               atomic.SwapUint64(&m.Deletes, 0),
+		LazyEvictions:         atomic.SwapUint64(&m.LazyEvictions, 0),
+		ScheduledEvictions:    atomic.SwapUint64(&m.ScheduledEvictions, 0),
+		CapacityEvictions:     atomic.SwapUint64(&m.CapacityEvictions, 0),
+		PolicyAdmits:          atomic.SwapUint64(&m.PolicyAdmits, 0),
+		PolicyRejects:         atomic.SwapUint64(&m.PolicyRejects, 0),
+		Loads:                 atomic.SwapUint64(&m.Loads, 0),
+		LoadDedups:            atomic.SwapUint64(&m.LoadDedups, 0),
+		LoadErrors:            atomic.SwapUint64(&m.LoadErrors, 0),
+		Refreshes:             atomic.SwapUint64(&m.Refreshes, 0),
+		RefreshErrors:         atomic.SwapUint64(&m.RefreshErrors, 0),
+		TagInvalidations:      atomic.SwapUint64(&m.TagInvalidations, 0),
+		EventDrops:            atomic.SwapUint64(&m.EventDrops, 0),
+		Bytes:                 atomic.LoadUint64(&m.Bytes),
+		CleanupRuns:           atomic.SwapUint64(&m.CleanupRuns, 0),
+		LastCleanupDurationMs: atomic.SwapUint64(&m.LastCleanupDurationMs, 0),
+		LastCleanupItems:      atomic.SwapUint64(&m.LastCleanupItems, 0),
+		RecentHits1m:          hits1m,
+		RecentMisses1m:        misses1m,
+		RecentHits5m:          hits5m,
+		RecentMisses5m:        misses5m,
+		GetLatency:            m.GetLatency.reset(),
+		SetLatency:            m.SetLatency.reset(),
+		DeleteLatency:         m.DeleteLatency.reset(),
+		CleanupLatency:        m.CleanupLatency.reset(),
+		window:                hitWindow{},
 	}
 }
 
 func (m *Metrics) AddHit() {
-	atomic.AddUint32(&m.Hits, 1)
+	m.addHit(time.Now())
 }
 
 func (m *Metrics) AddMiss() {
-	atomic.AddUint32(&m.Misses, 1)
+	m.addMiss(time.Now())
+}
+
+// addHit counts a hit in the window slot of now.
+func (m *Metrics) addHit(now time.Time) {
+	atomic.AddUint64(&m.Hits, 1)
+	m.window.add(now, true)
+}
+
+// addMiss counts a miss in the window slot of now.
+func (m *Metrics) addMiss(now time.Time) {
+	atomic.AddUint64(&m.Misses, 1)
+	m.window.add(now, false)
 }
 
 func (m *Metrics) AddSet() {
-	atomic.AddUint32(&m.Sets, 1)
+	atomic.AddUint64(&m.Sets, 1)
 }
 
-func (m *Metrics) AddDelete(count uint32) {
+func (m *Metrics) AddDelete(count uint64) {
 	if count > 0 {
-		atomic.AddUint32(&m.Deletes, count)
+		atomic.AddUint64(&m.Deletes, count)
 	}
 }
 
 func (m *Metrics) AddLazyEviction() {
-	atomic.AddUint32(&m.LazyEvictions, 1)
+	atomic.AddUint64(&m.LazyEvictions, 1)
 }
 
-func (m *Metrics) AddScheduledEviction(count uint32) {
+func (m *Metrics) AddScheduledEviction(count uint64) {
 	if count > 0 {
-		atomic.AddUint32(&m.ScheduledEvictions, count)
+		atomic.AddUint64(&m.ScheduledEvictions, count)
 	}
 }
 
-func (m *Metrics) AddCleanupRun(duration time.Duration, itemsCleaned uint32) {
-	atomic.AddUint32(&m.CleanupRuns, 1)
-	atomic.StoreUint32(&m.LastCleanupItems, itemsCleaned)
+func (m *Metrics) AddCapacityEviction(count uint64) {
+	if count > 0 {
+		atomic.AddUint64(&m.CapacityEvictions, count)
+	}
+}
+
+func (m *Metrics) AddAdmission(decision Decision) {
+	switch decision {
+	case DecisionAdmit:
+		atomic.AddUint64(&m.PolicyAdmits, 1)
+	case DecisionReject:
+		atomic.AddUint64(&m.PolicyRejects, 1)
+	case DecisionNone:
+	}
+}
+
+func (m *Metrics) AddLoad() {
+	atomic.AddUint64(&m.Loads, 1)
+}
+
+func (m *Metrics) AddLoadDedup() {
+	atomic.AddUint64(&m.LoadDedups, 1)
+}
+
+func (m *Metrics) AddLoadError() {
+	atomic.AddUint64(&m.LoadErrors, 1)
+}
+
+func (m *Metrics) AddRefresh() {
+	atomic.AddUint64(&m.Refreshes, 1)
+}
+
+func (m *Metrics) AddRefreshError() {
+	atomic.AddUint64(&m.RefreshErrors, 1)
+}
+
+func (m *Metrics) AddTagInvalidation(count uint64) {
+	if count > 0 {
+		atomic.AddUint64(&m.TagInvalidations, count)
+	}
+}
+
+func (m *Metrics) AddEventDrop() {
+	atomic.AddUint64(&m.EventDrops, 1)
+}
+
+// SetBytes records the estimated size of the stored entries.
+func (m *Metrics) SetBytes(n uint64) {
+	atomic.StoreUint64(&m.Bytes, n)
+}
+
+func (m *Metrics) AddCleanupRun(duration time.Duration, itemsCleaned uint64) {
+	atomic.AddUint64(&m.CleanupRuns, 1)
+	atomic.StoreUint64(&m.LastCleanupItems, itemsCleaned)
+	m.CleanupLatency.Observe(duration)
 
 	ms := duration.Milliseconds()
 
@@ -95,12 +257,154 @@ func (m *Metrics) AddCleanupRun(duration time.Duration, itemsCleaned uint32) {
 	atomic.StoreUint64(&m.LastCleanupDurationMs, ums)
 }
 
-// JSONStr returns a JSON snapshot of the current metrics.
+// HitRatio returns the share of Get calls that were hits, or 0 if there were
+// none. Like the other ratios, it is meant to be called on a snapshot.
+func (m *Metrics) HitRatio() float64 {
+	return ratio(m.Hits, m.Misses)
+}
+
+// HitRatio1m returns the hit ratio over the last minute.
+func (m *Metrics) HitRatio1m() float64 {
+	return ratio(m.RecentHits1m, m.RecentMisses1m)
+}
+
+// HitRatio5m returns the hit ratio over the last five minutes.
+func (m *Metrics) HitRatio5m() float64 {
+	return ratio(m.RecentHits5m, m.RecentMisses5m)
+}
+
+func ratio(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+
+	return float64(hits) / float64(hits+misses)
+}
+
+// merge accumulates a snapshot of another cache into m. Counters are summed,
+// the last cleanup duration keeps the slowest run. m must not be shared.
+func (m *Metrics) merge(other Metrics) {
+	m.Hits += other.Hits
+	m.Misses += other.Misses
+	m.Sets += other.Sets
+	m.Deletes += other.Deletes
+	m.LazyEvictions += other.LazyEvictions
+	m.ScheduledEvictions += other.ScheduledEvictions
+	m.CapacityEvictions += other.CapacityEvictions
+	m.PolicyAdmits += other.PolicyAdmits
+	m.PolicyRejects += other.PolicyRejects
+	m.Loads += other.Loads
+	m.LoadDedups += other.LoadDedups
+	m.LoadErrors += other.LoadErrors
+	m.Refreshes += other.Refreshes
+	m.RefreshErrors += other.RefreshErrors
+	m.TagInvalidations += other.TagInvalidations
+	m.EventDrops += other.EventDrops
+	m.Bytes += other.Bytes
+	m.CleanupRuns += other.CleanupRuns
+	m.LastCleanupDurationMs = max(m.LastCleanupDurationMs, other.LastCleanupDurationMs)
+	m.LastCleanupItems += other.LastCleanupItems
+	m.RecentHits1m += other.RecentHits1m
+	m.RecentMisses1m += other.RecentMisses1m
+	m.RecentHits5m += other.RecentHits5m
+	m.RecentMisses5m += other.RecentMisses5m
+	m.GetLatency.merge(other.GetLatency)
+	m.SetLatency.merge(other.SetLatency)
+	m.DeleteLatency.merge(other.DeleteLatency)
+	m.CleanupLatency.merge(other.CleanupLatency)
+}
+
+// metricsJSON adds derived fields to the JSON form of Metrics.
+type metricsJSON struct {
+	Metrics
+
+	HitRatio   float64 `json:"hit_ratio"`
+	HitRatio1m float64 `json:"hit_ratio_1m"`
+	HitRatio5m float64 `json:"hit_ratio_5m"`
+}
+
+// JSONStr returns a JSON snapshot of the current metrics, including the hit
+// ratios.
 func (m *Metrics) JSONStr() string {
-	b, err := json.Marshal(m.Snapshot())
+	snapshot := m.Snapshot()
+
+	b, err := json.Marshal(metricsJSON{
+		Metrics:    snapshot,
+		HitRatio:   snapshot.HitRatio(),
+		HitRatio1m: snapshot.HitRatio1m(),
+		HitRatio5m: snapshot.HitRatio5m(),
+	})
 	if err != nil {
 		return fmt.Sprintf(`{"error":%q}`, err.Error())
 	}
 
 	return string(b)
 }
+
+const (
+	// windowSlot is the time covered by one hitWindow slot.
+	windowSlot = 5 * time.Second
+	// windowSpan is the time covered by a hitWindow.
+	windowSpan = 5 * time.Minute
+	// windowSlots is the number of slots of a hitWindow.
+	windowSlots = int(windowSpan / windowSlot)
+)
+
+// hitWindow counts hits and misses in a ring of time slots to report them
+// over the last minutes. The slot being filled is included in every sum, so
+// windows are accurate to one slot.
+type hitWindow struct {
+	slots [windowSlots]hitSlot
+}
+
+type hitSlot struct {
+	epoch  int64 // index of the slot since the Unix epoch, plus one
+	hits   uint64
+	misses uint64
+}
+
+func slotEpoch(now time.Time) int64 {
+	return now.UnixNano()/int64(windowSlot) + 1
+}
+
+func (w *hitWindow) add(now time.Time, hit bool) {
+	epoch := slotEpoch(now)
+	slot := &w.slots[epoch%int64(windowSlots)]
+
+	// The first writer of a new period recycles the slot. Counts racing with
+	// the recycling may be lost, which is fine for a rate.
+	if old := atomic.LoadInt64(&slot.epoch); old != epoch && atomic.CompareAndSwapInt64(&slot.epoch, old, epoch) {
+		atomic.StoreUint64(&slot.hits, 0)
+		atomic.StoreUint64(&slot.misses, 0)
+	}
+
+	if hit {
+		atomic.AddUint64(&slot.hits, 1)
+	} else {
+		atomic.AddUint64(&slot.misses, 1)
+	}
+}
+
+// sum returns the hits and misses counted over the last span.
+func (w *hitWindow) sum(now time.Time, span time.Duration) (uint64, uint64) {
+	current := slotEpoch(now)
+	oldest := current - int64(span/windowSlot) + 1
+
+	var hits, misses uint64
+
+	for i := range w.slots {
+		slot := &w.slots[i]
+		if epoch := atomic.LoadInt64(&slot.epoch); epoch >= oldest && epoch <= current {
+			hits += atomic.LoadUint64(&slot.hits)
+			misses += atomic.LoadUint64(&slot.misses)
+		}
+	}
+
+	return hits, misses
+}
+
+func (w *hitWindow) reset() {
+	for i := range w.slots {
+		atomic.StoreInt64(&w.slots[i].epoch, 0)
+	}
+}
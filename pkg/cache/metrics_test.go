@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/cachetest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -40,15 +41,41 @@ func TestMetricsCounters(t *testing.T) {
 	mtrcs.AddLazyEviction()
 	mtrcs.AddScheduledEviction(0) // no-op
 	mtrcs.AddScheduledEviction(3)
+	mtrcs.AddCapacityEviction(0) // no-op
+	mtrcs.AddCapacityEviction(1)
+	mtrcs.AddAdmission(cache.DecisionNone) // no-op
+	mtrcs.AddAdmission(cache.DecisionAdmit)
+	mtrcs.AddAdmission(cache.DecisionReject)
+	mtrcs.AddAdmission(cache.DecisionReject)
+	mtrcs.AddLoad()
+	mtrcs.AddLoadDedup()
+	mtrcs.AddLoadDedup()
+	mtrcs.AddLoadError()
+	mtrcs.AddRefresh()
+	mtrcs.AddRefresh()
+	mtrcs.AddRefreshError()
+	mtrcs.AddTagInvalidation(0) // no-op
+	mtrcs.AddTagInvalidation(4)
+	mtrcs.AddEventDrop()
 
 	snps := mtrcs.Snapshot()
 
-	assert.Equal(t, uint32(2), snps.Hits)
-	assert.Equal(t, uint32(1), snps.Misses)
-	assert.Equal(t, uint32(3), snps.Sets)
-	assert.Equal(t, uint32(2), snps.Deletes)
-	assert.Equal(t, uint32(1), snps.LazyEvictions)
-	assert.Equal(t, uint32(3), snps.ScheduledEvictions)
+	assert.Equal(t, uint64(2), snps.Hits)
+	assert.Equal(t, uint64(1), snps.Misses)
+	assert.Equal(t, uint64(3), snps.Sets)
+	assert.Equal(t, uint64(2), snps.Deletes)
+	assert.Equal(t, uint64(1), snps.LazyEvictions)
+	assert.Equal(t, uint64(3), snps.ScheduledEvictions)
+	assert.Equal(t, uint64(1), snps.CapacityEvictions)
+	assert.Equal(t, uint64(1), snps.PolicyAdmits)
+	assert.Equal(t, uint64(2), snps.PolicyRejects)
+	assert.Equal(t, uint64(1), snps.Loads)
+	assert.Equal(t, uint64(2), snps.LoadDedups)
+	assert.Equal(t, uint64(1), snps.LoadErrors)
+	assert.Equal(t, uint64(2), snps.Refreshes)
+	assert.Equal(t, uint64(1), snps.RefreshErrors)
+	assert.Equal(t, uint64(4), snps.TagInvalidations)
+	assert.Equal(t, uint64(1), snps.EventDrops)
 }
 
 func TestMetricsCleanupRun(t *testing.T) {
@@ -59,15 +86,15 @@ func TestMetricsCleanupRun(t *testing.T) {
 	mtrcs.AddCleanupRun(0, 0)
 
 	snps := mtrcs.Snapshot()
-	assert.Equal(t, uint32(1), snps.CleanupRuns)
-	assert.Equal(t, uint32(0), snps.LastCleanupItems)
+	assert.Equal(t, uint64(1), snps.CleanupRuns)
+	assert.Equal(t, uint64(0), snps.LastCleanupItems)
 	assert.Equal(t, uint64(0), snps.LastCleanupDurationMs)
 
 	mtrcs.AddCleanupRun(10*time.Millisecond, 5)
 
 	snps = mtrcs.Snapshot()
-	assert.Equal(t, uint32(2), snps.CleanupRuns)
-	assert.Equal(t, uint32(5), snps.LastCleanupItems)
+	assert.Equal(t, uint64(2), snps.CleanupRuns)
+	assert.Equal(t, uint64(5), snps.LastCleanupItems)
 	assert.Equal(t, uint64(10), snps.LastCleanupDurationMs)
 }
 
@@ -94,7 +121,141 @@ func TestMetricsJSONStr(t *testing.T) {
 	assert.Equal(t, snps.Deletes, decoded.Deletes)
 	assert.Equal(t, snps.LazyEvictions, decoded.LazyEvictions)
 	assert.Equal(t, snps.ScheduledEvictions, decoded.ScheduledEvictions)
+	assert.Equal(t, snps.CapacityEvictions, decoded.CapacityEvictions)
+	assert.Equal(t, snps.PolicyAdmits, decoded.PolicyAdmits)
+	assert.Equal(t, snps.PolicyRejects, decoded.PolicyRejects)
+	assert.Equal(t, snps.Loads, decoded.Loads)
+	assert.Equal(t, snps.LoadDedups, decoded.LoadDedups)
+	assert.Equal(t, snps.LoadErrors, decoded.LoadErrors)
+	assert.Equal(t, snps.Refreshes, decoded.Refreshes)
+	assert.Equal(t, snps.RefreshErrors, decoded.RefreshErrors)
+	assert.Equal(t, snps.TagInvalidations, decoded.TagInvalidations)
+	assert.Equal(t, snps.EventDrops, decoded.EventDrops)
 	assert.Equal(t, snps.CleanupRuns, decoded.CleanupRuns)
 	assert.Equal(t, snps.LastCleanupDurationMs, decoded.LastCleanupDurationMs)
 	assert.Equal(t, snps.LastCleanupItems, decoded.LastCleanupItems)
+	assert.Equal(t, snps.RecentHits1m, decoded.RecentHits1m)
+	assert.Equal(t, snps.RecentMisses5m, decoded.RecentMisses5m)
+	assert.Equal(t, snps.GetLatency, decoded.GetLatency)
+
+	var derived struct {
+		HitRatio   float64 `json:"hit_ratio"`
+		HitRatio1m float64 `json:"hit_ratio_1m"`
+		HitRatio5m float64 `json:"hit_ratio_5m"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(js), &derived))
+	assert.InDelta(t, 0.5, derived.HitRatio, 0)
+	assert.InDelta(t, 0.5, derived.HitRatio1m, 0)
+	assert.InDelta(t, 0.5, derived.HitRatio5m, 0)
+}
+
+func TestMetricsHitRatio(t *testing.T) {
+	t.Parallel()
+
+	var mtrcs cache.Metrics
+
+	snps := mtrcs.Snapshot()
+	assert.Zero(t, snps.HitRatio(), "no reads, no ratio")
+
+	for range 3 {
+		mtrcs.AddHit()
+	}
+
+	mtrcs.AddMiss()
+
+	snps = mtrcs.Snapshot()
+	assert.Equal(t, uint64(3), snps.RecentHits1m)
+	assert.Equal(t, uint64(1), snps.RecentMisses1m)
+	assert.Equal(t, uint64(3), snps.RecentHits5m)
+	assert.Equal(t, uint64(1), snps.RecentMisses5m)
+	assert.InDelta(t, 0.75, snps.HitRatio(), 0)
+	assert.InDelta(t, 0.75, snps.HitRatio1m(), 0)
+	assert.InDelta(t, 0.75, snps.HitRatio5m(), 0)
+}
+
+func TestMemCacheMetricsClock(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	mcache := newMemCache(t, cache.WithClock(clock))
+
+	require.NoError(t, mcache.Set(ctx, "key", "value", 0))
+
+	_, err := mcache.Get(ctx, "key")
+	require.NoError(t, err)
+
+	_, err = mcache.Get(ctx, "missing")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	clock.Advance(2 * time.Minute)
+
+	snps := mcache.Metrics()
+	assert.Zero(t, snps.RecentHits1m, "the hit window follows the cache clock")
+	assert.Zero(t, snps.RecentMisses1m)
+	assert.Equal(t, uint64(1), snps.RecentHits5m)
+	assert.Equal(t, uint64(1), snps.RecentMisses5m)
+
+	clock.Advance(5 * time.Minute)
+	assert.Zero(t, mcache.ResetMetrics().RecentHits5m)
+}
+
+func TestMetricsReset(t *testing.T) {
+	t.Parallel()
+
+	var mtrcs cache.Metrics
+
+	mtrcs.AddHit()
+	mtrcs.AddSet()
+	mtrcs.GetLatency.Observe(time.Millisecond)
+	mtrcs.AddCleanupRun(2*time.Millisecond, 3)
+
+	before := mtrcs.Reset()
+	assert.Equal(t, uint64(1), before.Hits)
+	assert.Equal(t, uint64(1), before.Sets)
+	assert.Equal(t, uint64(1), before.RecentHits1m)
+	assert.Equal(t, uint64(1), before.GetLatency.Count)
+	assert.Equal(t, uint64(1), before.CleanupLatency.Count)
+	assert.Equal(t, uint64(3), before.LastCleanupItems)
+
+	assert.Equal(t, cache.Metrics{}, mtrcs.Snapshot())
+	assert.Equal(t, cache.Metrics{}, mtrcs.Reset())
+
+	mtrcs.AddMiss()
+	assert.Equal(t, uint64(1), mtrcs.Snapshot().RecentMisses1m)
+}
+
+func TestMemCacheLatencies(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := cache.WithDeleteInterval(5*time.Millisecond, Logger(t))
+
+	t.Cleanup(func() {
+		require.NoError(t, mcache.Close(ctx))
+	})
+
+	require.NoError(t, mcache.Set(ctx, "key", 1, 0))
+	require.NoError(t, mcache.SetWithTags(ctx, "tagged", 1, 0, "tag"))
+
+	_, err := mcache.Get(ctx, "key")
+	require.NoError(t, err)
+
+	_, err = mcache.Get(ctx, "missing")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	require.NoError(t, mcache.Delete(ctx, "key", "tagged"))
+
+	require.Eventually(t, func() bool {
+		return mcache.Metrics().CleanupLatency.Count > 0
+	}, time.Second, 5*time.Millisecond)
+
+	snps := mcache.ResetMetrics()
+	assert.Equal(t, uint64(2), snps.SetLatency.Count)
+	assert.Equal(t, uint64(2), snps.GetLatency.Count)
+	assert.Equal(t, uint64(1), snps.DeleteLatency.Count)
+	assert.InDelta(t, 0.5, snps.HitRatio(), 0)
+
+	assert.Zero(t, mcache.Metrics().Hits)
 }
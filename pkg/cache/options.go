@@ -0,0 +1,222 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import "time"
+
+// Option configures optional MemCache behavior.
+type Option func(*options)
+
+type options struct {
+	newPolicy           NewPolicyFunc
+	maxEntries          int
+	maxBytes            int64
+	maxValueBytes       int64
+	sizer               Sizer
+	newHash             HashFunc
+	cleanupBudget       int
+	negativeTTL         time.Duration
+	refreshFn           RefreshFunc
+	refreshWorkers      int
+	codec               Codec
+	eventQueueSize      int
+	fsync               FsyncPolicy
+	compactionThreshold int64
+	clock               Clock
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		newPolicy:           NewLRU,
+		maxEntries:          0,
+		maxBytes:            0,
+		maxValueBytes:       0,
+		sizer:               nil,
+		newHash:             NewFNV64a,
+		cleanupBudget:       MaxDeletesPerRun,
+		negativeTTL:         0,
+		refreshFn:           nil,
+		refreshWorkers:      0,
+		codec:               GobCodec{},
+		eventQueueSize:      DefaultEventQueueSize,
+		fsync:               FsyncEverySecond,
+		compactionThreshold: DefaultCompactionThreshold,
+		clock:               systemClock{},
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithMaxEntries bounds the number of entries held by MemCache.
+//
+// When the cache is full, Set evicts an entry chosen by the configured Policy
+// to make room for a new key; by default that is the least-recently-used
+// entry. Overwriting an existing key never evicts. A value of n <= 0 means the
+// cache is unbounded (the default).
+func WithMaxEntries(n int) Option {
+	return func(o *options) {
+		o.maxEntries = n
+	}
+}
+
+// WithMaxBytes bounds the estimated size in bytes of the entries held by
+// MemCache.
+//
+// The size of an entry is the length of its key plus the size of its value:
+// the length of a []byte or string, and for other types the estimate of the
+// Sizer set with WithSizer, or else the shallow size of the value type. When
+// a write pushes the cache over budget, entries are evicted as chosen by the
+// Policy (see Evictor) until it fits again; on its own, the byte budget
+// evicts the least recently used entries. Entries larger than the whole
+// budget are rejected with a *SizeError. A value of n <= 0 means no byte
+// budget (the default).
+func WithMaxBytes(n int64) Option {
+	return func(o *options) {
+		o.maxBytes = n
+	}
+}
+
+// WithMaxValueBytes rejects values whose estimated size exceeds n bytes with
+// a *SizeError wrapping ErrTooLarge. See WithMaxBytes for how values are
+// sized. A value of n <= 0 means no limit (the default).
+func WithMaxValueBytes(n int64) Option {
+	return func(o *options) {
+		o.maxValueBytes = n
+	}
+}
+
+// WithSizer sets the Sizer estimating the size of values that are neither a
+// []byte nor a string.
+func WithSizer(sizer Sizer) Option {
+	return func(o *options) {
+		o.sizer = sizer
+	}
+}
+
+// WithDigestHash sets the HashFunc used by Digest, for example NewXXHash.
+// The default is NewFNV64a, which byte-oriented backends such as the redis
+// and memcached clients use too: caches whose digests are compared with
+// theirs, like the L1 of a Tiered cache, should keep it.
+func WithDigestHash(newHash HashFunc) Option {
+	return func(o *options) {
+		if newHash != nil {
+			o.newHash = newHash
+		}
+	}
+}
+
+// WithCleanupBudget sets how many expired entries the background cleaner
+// removes at most per run. Values <= 0 keep the default MaxDeletesPerRun.
+func WithCleanupBudget(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.cleanupBudget = n
+		}
+	}
+}
+
+// WithNegativeTTL makes GetOrLoad remember loader errors for ttl, returning
+// the cached error instead of calling the loader again. Values <= 0 disable
+// negative caching (the default).
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.negativeTTL = ttl
+	}
+}
+
+// DefaultRefreshWorkers is the number of refresh workers used when WithRefresh
+// is given a non-positive worker count.
+const DefaultRefreshWorkers = 4
+
+// WithRefresh registers the RefreshFunc used to refresh entries written with
+// SetWithRefresh once their soft deadline passes, and the number of
+// background workers running refreshes. If workers is <= 0,
+// DefaultRefreshWorkers is used.
+func WithRefresh(refreshFn RefreshFunc, workers int) Option {
+	return func(o *options) {
+		if workers <= 0 {
+			workers = DefaultRefreshWorkers
+		}
+
+		o.refreshFn = refreshFn
+		o.refreshWorkers = workers
+	}
+}
+
+// WithPolicy sets the eviction/admission policy used once the cache is bounded
+// with WithMaxEntries, for example NewTinyLFU. It has no effect on an
+// unbounded cache.
+func WithPolicy(newPolicy NewPolicyFunc) Option {
+	return func(o *options) {
+		if newPolicy != nil {
+			o.newPolicy = newPolicy
+		}
+	}
+}
+
+// WithValueCodec sets the Codec used to persist values of non-primitive types
+// in snapshots and write logs. The codec must be able to decode into a *any;
+// the default is GobCodec.
+func WithValueCodec(codec Codec) Option {
+	return func(o *options) {
+		if codec != nil {
+			o.codec = codec
+		}
+	}
+}
+
+// WithFsync sets when a cache opened with Open syncs its write log. The
+// default is FsyncEverySecond.
+func WithFsync(policy FsyncPolicy) Option {
+	return func(o *options) {
+		o.fsync = policy
+	}
+}
+
+// WithCompactionThreshold sets the write log size in bytes from which a cache
+// opened with Open compacts its log in the background, once the log has also
+// doubled since the last compaction. A size <= 0 disables automatic
+// compaction; Compact can still be called explicitly. The default is
+// DefaultCompactionThreshold.
+func WithCompactionThreshold(size int64) Option {
+	return func(o *options) {
+		o.compactionThreshold = size
+	}
+}
+
+// WithEventQueueSize sets how many events are buffered per subscriber before
+// further events are dropped. Values <= 0 keep DefaultEventQueueSize.
+func WithEventQueueSize(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.eventQueueSize = n
+		}
+	}
+}
+
+// WithClock sets the Clock the cache reads the time from and drives its
+// background cleaner with, for example a cachetest.Clock in tests. A nil
+// clock keeps the system clock.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		if clock != nil {
+			o.clock = clock
+		}
+	}
+}
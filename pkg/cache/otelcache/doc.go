@@ -0,0 +1,38 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otelcache instruments a cache.Cache with OpenTelemetry traces and
+// metrics.
+//
+// Wrap returns a cache.Cache that starts a span for every Set, Get, GetMany,
+// SetMany, Delete and Digest call, parented by the span in the caller's
+// context, and passes the span context on to the wrapped cache. Spans carry a
+// hash of the key rather than the key itself, the TTL of writes and the hit
+// or miss outcome of reads; batch spans carry the number of keys and the
+// hashes of the first keys.
+//
+// The decorator also records metric instruments mirroring cache.Metrics:
+// hits, misses, sets, deletes and operation durations. If the wrapped cache
+// reports cache.Metrics, as cache.MemCache and cache.Sharded do, evictions,
+// cleanup runs, loads and refreshes are exported as observable counters.
+//
+// Example usage:
+//
+//	instrumented, err := otelcache.Wrap(mcache, otelcache.WithName("users"))
+//	if err != nil {
+//		return err
+//	}
+//
+//	value, err := instrumented.Get(ctx, "user:42")
+package otelcache
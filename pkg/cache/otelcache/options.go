@@ -0,0 +1,83 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelcache
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultName is the value of the cache.name attribute when no name is given.
+const DefaultName = "default"
+
+// Option configures a Cache.
+type Option func(*options)
+
+type options struct {
+	name           string
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		name:           DefaultName,
+		tracerProvider: nil,
+		meterProvider:  nil,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// Resolve the global providers late, so that providers installed after
+	// the options were built are still honored.
+	if o.tracerProvider == nil {
+		o.tracerProvider = otel.GetTracerProvider()
+	}
+
+	if o.meterProvider == nil {
+		o.meterProvider = otel.GetMeterProvider()
+	}
+
+	return o
+}
+
+// WithName sets the cache.name attribute of spans and measurements. An empty
+// name keeps DefaultName.
+func WithName(name string) Option {
+	return func(o *options) {
+		if name != "" {
+			o.name = name
+		}
+	}
+}
+
+// WithTracerProvider sets the TracerProvider used to create spans. The
+// default is the global provider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *options) {
+		o.tracerProvider = provider
+	}
+}
+
+// WithMeterProvider sets the MeterProvider used to create instruments. The
+// default is the global provider.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(o *options) {
+		o.meterProvider = provider
+	}
+}
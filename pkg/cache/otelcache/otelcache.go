@@ -0,0 +1,408 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelcache
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// instrumentationName identifies this package as the instrumentation
+	// scope of its tracer and meter.
+	instrumentationName = "github.com/patraden/toolkit/pkg/cache/otelcache"
+	// maxKeyHashes bounds the number of key hashes recorded on a batch span.
+	maxKeyHashes = 16
+)
+
+// Attribute keys set on spans and measurements.
+const (
+	KeyName      = attribute.Key("cache.name")
+	KeyOperation = attribute.Key("cache.operation")
+	KeyKeyHash   = attribute.Key("cache.key_hash")
+	KeyKeyHashes = attribute.Key("cache.key_hashes")
+	KeyKeyCount  = attribute.Key("cache.key_count")
+	KeyHit       = attribute.Key("cache.hit")
+	KeyHitCount  = attribute.Key("cache.hit_count")
+	KeyTTL       = attribute.Key("cache.ttl_ms")
+	KeyReason    = attribute.Key("cache.eviction_reason")
+)
+
+// metricsSource is implemented by caches reporting cache.Metrics.
+type metricsSource interface {
+	Metrics() cache.Metrics
+}
+
+// Cache is a cache.Cache that traces and measures the calls to the cache it
+// wraps.
+type Cache struct {
+	next         cache.Cache
+	tracer       trace.Tracer
+	name         attribute.KeyValue
+	hits         metric.Int64Counter
+	misses       metric.Int64Counter
+	sets         metric.Int64Counter
+	deletes      metric.Int64Counter
+	errors       metric.Int64Counter
+	duration     metric.Float64Histogram
+	registration metric.Registration
+}
+
+var _ cache.Cache = (*Cache)(nil)
+
+// Wrap returns a Cache instrumenting next. It returns an error if the
+// metric instruments cannot be created.
+func Wrap(next cache.Cache, opts ...Option) (*Cache, error) {
+	cfg := newOptions(opts)
+	meter := cfg.meterProvider.Meter(instrumentationName)
+
+	var errs [7]error
+
+	c := &Cache{
+		next:         next,
+		tracer:       cfg.tracerProvider.Tracer(instrumentationName),
+		name:         KeyName.String(cfg.name),
+		hits:         nil,
+		misses:       nil,
+		sets:         nil,
+		deletes:      nil,
+		errors:       nil,
+		duration:     nil,
+		registration: nil,
+	}
+
+	c.hits, errs[0] = meter.Int64Counter("cache.hits",
+		metric.WithDescription("Number of Get calls that found a live entry."), metric.WithUnit("{hit}"))
+	c.misses, errs[1] = meter.Int64Counter("cache.misses",
+		metric.WithDescription("Number of Get calls that found no live entry."), metric.WithUnit("{miss}"))
+	c.sets, errs[2] = meter.Int64Counter("cache.sets",
+		metric.WithDescription("Number of Set calls."), metric.WithUnit("{set}"))
+	c.deletes, errs[3] = meter.Int64Counter("cache.deletes",
+		metric.WithDescription("Number of keys passed to Delete."), metric.WithUnit("{key}"))
+	c.errors, errs[4] = meter.Int64Counter("cache.errors",
+		metric.WithDescription("Number of failed cache calls."), metric.WithUnit("{error}"))
+	c.duration, errs[5] = meter.Float64Histogram("cache.operation.duration",
+		metric.WithDescription("Duration of cache calls."), metric.WithUnit("s"))
+
+	if src, ok := next.(metricsSource); ok {
+		c.registration, errs[6] = observe(meter, src, c.name)
+	}
+
+	if err := errors.Join(errs[:]...); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// observed describes a cache.Metrics counter exported as an observable
+// counter.
+type observed struct {
+	name  string
+	desc  string
+	unit  string
+	attrs []attribute.KeyValue
+	value func(m *cache.Metrics) uint64
+}
+
+// observedMetrics lists the cache.Metrics counters that the decorator cannot
+// count itself.
+var observedMetrics = []observed{
+	{
+		name:  "cache.evictions",
+		desc:  "Number of entries evicted from the cache.",
+		unit:  "{entry}",
+		attrs: []attribute.KeyValue{KeyReason.String("lazy")},
+		value: func(m *cache.Metrics) uint64 { return m.LazyEvictions },
+	},
+	{
+		name:  "cache.evictions",
+		desc:  "Number of entries evicted from the cache.",
+		unit:  "{entry}",
+		attrs: []attribute.KeyValue{KeyReason.String("scheduled")},
+		value: func(m *cache.Metrics) uint64 { return m.ScheduledEvictions },
+	},
+	{
+		name:  "cache.evictions",
+		desc:  "Number of entries evicted from the cache.",
+		unit:  "{entry}",
+		attrs: []attribute.KeyValue{KeyReason.String("capacity")},
+		value: func(m *cache.Metrics) uint64 { return m.CapacityEvictions },
+	},
+	{
+		name:  "cache.cleanup.runs",
+		desc:  "Number of background cleanup runs.",
+		unit:  "{run}",
+		attrs: nil,
+		value: func(m *cache.Metrics) uint64 { return m.CleanupRuns },
+	},
+	{
+		name:  "cache.loads",
+		desc:  "Number of loader calls started by GetOrLoad.",
+		unit:  "{load}",
+		attrs: nil,
+		value: func(m *cache.Metrics) uint64 { return m.Loads },
+	},
+	{
+		name:  "cache.load.errors",
+		desc:  "Number of loader calls that returned an error.",
+		unit:  "{error}",
+		attrs: nil,
+		value: func(m *cache.Metrics) uint64 { return m.LoadErrors },
+	},
+	{
+		name:  "cache.refreshes",
+		desc:  "Number of background refreshes started.",
+		unit:  "{refresh}",
+		attrs: nil,
+		value: func(m *cache.Metrics) uint64 { return m.Refreshes },
+	},
+	{
+		name:  "cache.refresh.errors",
+		desc:  "Number of background refreshes that failed.",
+		unit:  "{error}",
+		attrs: nil,
+		value: func(m *cache.Metrics) uint64 { return m.RefreshErrors },
+	},
+}
+
+// observe registers observable counters reading the metrics of src.
+func observe(meter metric.Meter, src metricsSource, name attribute.KeyValue) (metric.Registration, error) {
+	counters := make([]metric.Int64ObservableCounter, len(observedMetrics))
+	instruments := make([]metric.Observable, 0, len(observedMetrics))
+	byName := make(map[string]metric.Int64ObservableCounter)
+
+	for i, o := range observedMetrics {
+		counter, ok := byName[o.name]
+		if !ok {
+			var err error
+
+			counter, err = meter.Int64ObservableCounter(o.name, metric.WithDescription(o.desc), metric.WithUnit(o.unit))
+			if err != nil {
+				return nil, err
+			}
+
+			byName[o.name] = counter
+			instruments = append(instruments, counter)
+		}
+
+		counters[i] = counter
+	}
+
+	return meter.RegisterCallback(func(_ context.Context, obs metric.Observer) error {
+		snapshot := src.Metrics()
+
+		for i, o := range observedMetrics {
+			attrs := append([]attribute.KeyValue{name}, o.attrs...)
+			obs.ObserveInt64(counters[i], clampInt64(o.value(&snapshot)), metric.WithAttributes(attrs...))
+		}
+
+		return nil
+	}, instruments...)
+}
+
+// Set stores key/value in the wrapped cache within a span.
+func (c *Cache) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	ctx, span, start := c.start(ctx, "set", KeyKeyHash.String(keyHash(key)), KeyTTL.Int64(max(ttl, 0).Milliseconds()))
+
+	err := c.next.Set(ctx, key, value, ttl)
+	if err == nil {
+		c.sets.Add(ctx, 1, metric.WithAttributes(c.name))
+	}
+
+	c.end(ctx, span, "set", start, err)
+
+	return err
+}
+
+// Get returns the value of key from the wrapped cache within a span. The
+// span records whether the call was a hit; a miss is not an error.
+func (c *Cache) Get(ctx context.Context, key string) (any, error) {
+	ctx, span, start := c.start(ctx, "get", KeyKeyHash.String(keyHash(key)))
+
+	value, err := c.next.Get(ctx, key)
+
+	switch {
+	case err == nil:
+		span.SetAttributes(KeyHit.Bool(true))
+		c.hits.Add(ctx, 1, metric.WithAttributes(c.name))
+	case errors.Is(err, cache.ErrNotFound):
+		span.SetAttributes(KeyHit.Bool(false))
+		c.misses.Add(ctx, 1, metric.WithAttributes(c.name))
+	}
+
+	c.end(ctx, span, "get", start, err)
+
+	return value, err
+}
+
+// GetMany returns the values of keys from the wrapped cache within a span.
+// The span records the number of keys, the hashes of the first keys and the
+// number of hits; every key counts as a hit or a miss.
+func (c *Cache) GetMany(ctx context.Context, keys ...string) (map[string]any, []string, error) {
+	ctx, span, start := c.start(ctx, "get_many", KeyKeyCount.Int(len(keys)), KeyKeyHashes.StringSlice(keyHashes(keys)))
+
+	found, missing, err := c.next.GetMany(ctx, keys...)
+	if err == nil {
+		hits := len(keys) - len(missing)
+
+		span.SetAttributes(KeyHitCount.Int(hits))
+		c.hits.Add(ctx, int64(hits), metric.WithAttributes(c.name))
+		c.misses.Add(ctx, int64(len(missing)), metric.WithAttributes(c.name))
+	}
+
+	c.end(ctx, span, "get_many", start, err)
+
+	return found, missing, err
+}
+
+// SetMany stores items in the wrapped cache within a span. The span records
+// the number of items and the hashes of the first keys.
+func (c *Cache) SetMany(ctx context.Context, items ...cache.Item) error {
+	keys := make([]string, 0, min(len(items), maxKeyHashes))
+	for _, item := range items[:min(len(items), maxKeyHashes)] {
+		keys = append(keys, item.Key)
+	}
+
+	ctx, span, start := c.start(ctx, "set_many", KeyKeyCount.Int(len(items)), KeyKeyHashes.StringSlice(keyHashes(keys)))
+
+	err := c.next.SetMany(ctx, items...)
+	if err == nil {
+		c.sets.Add(ctx, int64(len(items)), metric.WithAttributes(c.name))
+	}
+
+	c.end(ctx, span, "set_many", start, err)
+
+	return err
+}
+
+// Delete removes keys from the wrapped cache within a span. The span records
+// the number of keys and the hashes of the first keys.
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	ctx, span, start := c.start(ctx, "delete", KeyKeyCount.Int(len(keys)), KeyKeyHashes.StringSlice(keyHashes(keys)))
+
+	err := c.next.Delete(ctx, keys...)
+	if err == nil {
+		c.deletes.Add(ctx, int64(len(keys)), metric.WithAttributes(c.name))
+	}
+
+	c.end(ctx, span, "delete", start, err)
+
+	return err
+}
+
+// DeleteMany removes keys from the wrapped cache within a span. The span
+// records the number of keys and the hashes of the first keys; the deletes
+// counter is increased by the number of keys that were present.
+func (c *Cache) DeleteMany(ctx context.Context, keys ...string) (int, error) {
+	ctx, span, start := c.start(ctx, "delete_many", KeyKeyCount.Int(len(keys)), KeyKeyHashes.StringSlice(keyHashes(keys)))
+
+	deleted, err := c.next.DeleteMany(ctx, keys...)
+	c.deletes.Add(ctx, int64(deleted), metric.WithAttributes(c.name))
+
+	c.end(ctx, span, "delete_many", start, err)
+
+	return deleted, err
+}
+
+// Digest returns the digest of key from the wrapped cache within a span. The
+// span records a hit when the digest is not zero.
+func (c *Cache) Digest(ctx context.Context, key string) cache.Digest {
+	ctx, span, start := c.start(ctx, "digest", KeyKeyHash.String(keyHash(key)))
+
+	digest := c.next.Digest(ctx, key)
+	span.SetAttributes(KeyHit.Bool(digest != 0))
+
+	c.end(ctx, span, "digest", start, nil)
+
+	return digest
+}
+
+// Close stops observing the wrapped cache and closes it.
+func (c *Cache) Close(ctx context.Context) error {
+	var errs [2]error
+
+	if c.registration != nil {
+		errs[0] = c.registration.Unregister()
+	}
+
+	errs[1] = c.next.Close(ctx)
+
+	return errors.Join(errs[:]...)
+}
+
+// Unwrap returns the wrapped cache.
+func (c *Cache) Unwrap() cache.Cache {
+	return c.next
+}
+
+func (c *Cache) start(
+	ctx context.Context,
+	op string,
+	attrs ...attribute.KeyValue,
+) (context.Context, trace.Span, time.Time) {
+	ctx, span := c.tracer.Start(ctx, "cache."+op,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(c.name),
+		trace.WithAttributes(attrs...),
+	)
+
+	return ctx, span, time.Now()
+}
+
+func (c *Cache) end(ctx context.Context, span trace.Span, op string, start time.Time, err error) {
+	c.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(c.name, KeyOperation.String(op)))
+
+	if err != nil && !errors.Is(err, cache.ErrNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		c.errors.Add(ctx, 1, metric.WithAttributes(c.name, KeyOperation.String(op)))
+	}
+
+	span.End()
+}
+
+// keyHash returns the hex FNV-1a hash of key, so spans can be correlated by
+// key without exposing it.
+func keyHash(key string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// keyHashes returns the hashes of the first maxKeyHashes keys.
+func keyHashes(keys []string) []string {
+	hashes := make([]string, 0, min(len(keys), maxKeyHashes))
+	for _, key := range keys[:min(len(keys), maxKeyHashes)] {
+		hashes = append(hashes, keyHash(key))
+	}
+
+	return hashes
+}
+
+func clampInt64(v uint64) int64 {
+	return int64(min(v, math.MaxInt64)) //nolint:gosec // clamped to the int64 range
+}
@@ -0,0 +1,289 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otelcache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/cachetest"
+	"github.com/patraden/toolkit/pkg/cache/otelcache"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var errBackend = errors.New("backend down")
+
+// brokenCache fails every write and read.
+type brokenCache struct{}
+
+func (brokenCache) Set(context.Context, string, any, time.Duration) error { return errBackend }
+func (brokenCache) Get(context.Context, string) (any, error)              { return nil, errBackend }
+func (brokenCache) GetMany(context.Context, ...string) (map[string]any, []string, error) {
+	return nil, nil, errBackend
+}
+func (brokenCache) SetMany(context.Context, ...cache.Item) error       { return errBackend }
+func (brokenCache) Delete(context.Context, ...string) error            { return errBackend }
+func (brokenCache) DeleteMany(context.Context, ...string) (int, error) { return 0, errBackend }
+func (brokenCache) Digest(context.Context, string) cache.Digest        { return 0 }
+func (brokenCache) Close(context.Context) error                        { return nil }
+
+type telemetry struct {
+	spans   *tracetest.SpanRecorder
+	tracer  *sdktrace.TracerProvider
+	reader  *sdkmetric.ManualReader
+	options []otelcache.Option
+}
+
+func newTelemetry() *telemetry {
+	spans := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	reader := sdkmetric.NewManualReader()
+
+	return &telemetry{
+		spans:  spans,
+		tracer: tracer,
+		reader: reader,
+		options: []otelcache.Option{
+			otelcache.WithTracerProvider(tracer),
+			otelcache.WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+		},
+	}
+}
+
+// sum returns the value of the int64 sum name for the data point carrying
+// attr, or -1 if there is none.
+func (tm *telemetry) sum(t *testing.T, name string, attr attribute.KeyValue) int64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, tm.reader.Collect(t.Context(), &rm))
+
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if m.Name != name {
+				continue
+			}
+
+			for _, point := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				if value, ok := point.Attributes.Value(attr.Key); ok && value == attr.Value {
+					return point.Value
+				}
+			}
+		}
+	}
+
+	return -1
+}
+
+func spanAttrs(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+
+	return attrs
+}
+
+func TestCacheSpans(t *testing.T) {
+	t.Parallel()
+
+	tm := newTelemetry()
+	mcache := cache.New(zerolog.New(os.Stdout))
+
+	instrumented, err := otelcache.Wrap(mcache, append(tm.options, otelcache.WithName("users"))...)
+	require.NoError(t, err)
+
+	ctx, parent := tm.tracer.Tracer("test").Start(t.Context(), "request")
+
+	require.NoError(t, instrumented.Set(ctx, "user:42", "alice", time.Minute))
+
+	value, err := instrumented.Get(ctx, "user:42")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", value)
+
+	_, err = instrumented.Get(ctx, "user:43")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	assert.Equal(t, cache.DigestOf("alice"), instrumented.Digest(ctx, "user:42"))
+	require.NoError(t, instrumented.Delete(ctx, "user:42", "user:43"))
+
+	parent.End()
+
+	spans := tm.spans.Ended()
+	require.Len(t, spans, 6)
+
+	names := make([]string, 0, len(spans))
+	for _, span := range spans[:5] {
+		names = append(names, span.Name())
+
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID(), "spans are parented by the caller")
+		assert.Equal(t, codes.Unset, span.Status().Code, "a miss is not an error")
+
+		for _, kv := range span.Attributes() {
+			assert.NotContains(t, kv.Value.Emit(), "user:", "raw keys are not recorded")
+		}
+	}
+
+	assert.Equal(t, []string{"cache.set", "cache.get", "cache.get", "cache.digest", "cache.delete"}, names)
+
+	set := spanAttrs(spans[0])
+	assert.Equal(t, "users", set[otelcache.KeyName].AsString())
+	assert.Equal(t, int64(60000), set[otelcache.KeyTTL].AsInt64())
+	assert.NotEmpty(t, set[otelcache.KeyKeyHash].AsString())
+
+	hit, miss := spanAttrs(spans[1]), spanAttrs(spans[2])
+	assert.True(t, hit[otelcache.KeyHit].AsBool())
+	assert.False(t, miss[otelcache.KeyHit].AsBool())
+	assert.Equal(t, set[otelcache.KeyKeyHash], hit[otelcache.KeyKeyHash])
+	assert.NotEqual(t, hit[otelcache.KeyKeyHash], miss[otelcache.KeyKeyHash])
+
+	assert.True(t, spanAttrs(spans[3])[otelcache.KeyHit].AsBool())
+
+	del := spanAttrs(spans[4])
+	assert.Equal(t, int64(2), del[otelcache.KeyKeyCount].AsInt64())
+	assert.Len(t, del[otelcache.KeyKeyHashes].AsStringSlice(), 2)
+
+	require.NoError(t, instrumented.Close(t.Context()))
+}
+
+func TestCacheBatchSpans(t *testing.T) {
+	t.Parallel()
+
+	tm := newTelemetry()
+	mcache := cache.New(zerolog.New(os.Stdout))
+
+	instrumented, err := otelcache.Wrap(mcache, tm.options...)
+	require.NoError(t, err)
+
+	ctx := t.Context()
+	name := otelcache.KeyName.String(otelcache.DefaultName)
+
+	items := make([]cache.Item, 20)
+	for i := range items {
+		items[i] = cache.Item{Key: fmt.Sprintf("user:%d", i), Value: i, TTL: 0}
+	}
+
+	require.NoError(t, instrumented.SetMany(ctx, items...))
+
+	found, missing, err := instrumented.GetMany(ctx, "user:1", "user:2", "user:99")
+	require.NoError(t, err)
+	assert.Len(t, found, 2)
+	assert.Equal(t, []string{"user:99"}, missing)
+
+	deleted, err := instrumented.DeleteMany(ctx, "user:1", "user:99")
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	spans := tm.spans.Ended()
+	require.Len(t, spans, 3)
+	assert.Equal(t, "cache.set_many", spans[0].Name())
+	assert.Equal(t, "cache.get_many", spans[1].Name())
+	assert.Equal(t, "cache.delete_many", spans[2].Name())
+
+	set := spanAttrs(spans[0])
+	assert.Equal(t, int64(20), set[otelcache.KeyKeyCount].AsInt64())
+	assert.Len(t, set[otelcache.KeyKeyHashes].AsStringSlice(), 16, "key hashes are bounded")
+
+	get := spanAttrs(spans[1])
+	assert.Equal(t, int64(3), get[otelcache.KeyKeyCount].AsInt64())
+	assert.Equal(t, int64(2), get[otelcache.KeyHitCount].AsInt64())
+
+	assert.Equal(t, int64(20), tm.sum(t, "cache.sets", name))
+	assert.Equal(t, int64(2), tm.sum(t, "cache.hits", name))
+	assert.Equal(t, int64(1), tm.sum(t, "cache.misses", name))
+	assert.Equal(t, int64(1), tm.sum(t, "cache.deletes", name), "only present keys count as deleted")
+
+	require.NoError(t, instrumented.Close(ctx))
+}
+
+func TestCacheMetrics(t *testing.T) {
+	t.Parallel()
+
+	tm := newTelemetry()
+	clock := cachetest.NewClock(time.Now())
+	mcache := cache.New(zerolog.New(os.Stdout), cache.WithClock(clock))
+
+	instrumented, err := otelcache.Wrap(mcache, tm.options...)
+	require.NoError(t, err)
+
+	ctx := t.Context()
+	name := otelcache.KeyName.String(otelcache.DefaultName)
+
+	require.NoError(t, instrumented.Set(ctx, "a", 1, 0))
+	require.NoError(t, instrumented.Set(ctx, "b", 1, time.Nanosecond))
+	clock.Advance(time.Millisecond)
+
+	_, err = instrumented.Get(ctx, "a")
+	require.NoError(t, err)
+
+	_, err = instrumented.Get(ctx, "b")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	require.NoError(t, instrumented.Delete(ctx, "a", "b", "c"))
+
+	assert.Equal(t, int64(1), tm.sum(t, "cache.hits", name))
+	assert.Equal(t, int64(1), tm.sum(t, "cache.misses", name))
+	assert.Equal(t, int64(2), tm.sum(t, "cache.sets", name))
+	assert.Equal(t, int64(3), tm.sum(t, "cache.deletes", name))
+	assert.Equal(t, int64(1), tm.sum(t, "cache.evictions", otelcache.KeyReason.String("lazy")),
+		"counters of the wrapped cache are observed")
+	assert.Equal(t, int64(0), tm.sum(t, "cache.evictions", otelcache.KeyReason.String("capacity")))
+
+	require.NoError(t, instrumented.Close(ctx))
+	assert.Equal(t, int64(-1), tm.sum(t, "cache.evictions", otelcache.KeyReason.String("lazy")),
+		"Close stops observing the wrapped cache")
+}
+
+func TestCacheErrors(t *testing.T) {
+	t.Parallel()
+
+	tm := newTelemetry()
+
+	instrumented, err := otelcache.Wrap(brokenCache{}, tm.options...)
+	require.NoError(t, err)
+
+	ctx := t.Context()
+
+	require.ErrorIs(t, instrumented.Set(ctx, "key", 1, 0), errBackend)
+
+	_, err = instrumented.Get(ctx, "key")
+	require.ErrorIs(t, err, errBackend)
+
+	spans := tm.spans.Ended()
+	require.Len(t, spans, 2)
+
+	for _, span := range spans {
+		assert.Equal(t, codes.Error, span.Status().Code)
+		assert.Equal(t, errBackend.Error(), span.Status().Description)
+		assert.NotContains(t, spanAttrs(span), otelcache.KeyHit, "a failed Get is neither hit nor miss")
+	}
+
+	assert.Equal(t, int64(1), tm.sum(t, "cache.errors", otelcache.KeyOperation.String("get")))
+	assert.Equal(t, int64(-1), tm.sum(t, "cache.sets", otelcache.KeyName.String(otelcache.DefaultName)))
+	assert.Equal(t, cache.Cache(brokenCache{}), instrumented.Unwrap())
+}
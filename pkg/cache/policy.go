@@ -0,0 +1,69 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+// Policy decides which keys a capacity-bounded MemCache keeps.
+//
+// MemCache calls Add and Remove while holding its write lock and Access while
+// holding at most its read lock, so implementations must be safe for
+// concurrent use. A Policy instance belongs to a single cache.
+type Policy interface {
+	// Add records that key was stored and reports which keys, if any, the
+	// cache must evict to stay within capacity. Keys returned in Evicted are
+	// already forgotten by the policy. Adding a key the policy already tracks
+	// counts as an access.
+	Add(key string) Admission
+	// Access records a read of key. It is called for hits and misses alike,
+	// so implementations must ignore keys they do not track.
+	Access(key string)
+	// Remove forgets key after it was deleted or expired.
+	Remove(key string)
+}
+
+// Evictor is implemented by policies that can name a victim on demand.
+//
+// A MemCache bounded with WithMaxBytes asks its Policy for victims through
+// Evict until its entries fit in the budget again. Policies that do not
+// implement Evictor get arbitrary victims. NewLRU and NewTinyLFU implement
+// Evictor.
+type Evictor interface {
+	// Evict forgets and returns the key the policy would evict next. It
+	// returns false if the policy tracks no keys.
+	Evict() (string, bool)
+}
+
+// NewPolicyFunc builds a Policy bounded to capacity entries.
+type NewPolicyFunc func(capacity int) Policy
+
+// Decision is the verdict of an admission filter.
+type Decision uint8
+
+const (
+	// DecisionNone means no admission filter was consulted.
+	DecisionNone Decision = iota
+	// DecisionAdmit means a new candidate replaced an existing entry.
+	DecisionAdmit
+	// DecisionReject means a new candidate was dropped in favor of an
+	// existing entry.
+	DecisionReject
+)
+
+// Admission is the outcome of Policy.Add.
+type Admission struct {
+	// Evicted lists keys the cache must remove.
+	Evicted []string
+	// Decision reports the admission filter verdict, if any.
+	Decision Decision
+}
@@ -0,0 +1,218 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy := cache.NewLRU(2)
+
+	assert.Empty(t, policy.Add("a").Evicted)
+	assert.Empty(t, policy.Add("b").Evicted)
+
+	policy.Access("a")
+	policy.Access("missing") // ignored
+
+	adm := policy.Add("c")
+	assert.Equal(t, []string{"b"}, adm.Evicted)
+	assert.Equal(t, cache.DecisionNone, adm.Decision)
+
+	policy.Remove("a")
+	assert.Empty(t, policy.Add("d").Evicted)
+}
+
+func TestTinyLFUPolicy(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fills main space without admission contest", func(t *testing.T) {
+		t.Parallel()
+
+		policy := cache.NewTinyLFU(10)
+
+		for i := range 10 {
+			adm := policy.Add(fmt.Sprintf("k-%d", i))
+			assert.Empty(t, adm.Evicted)
+			assert.Equal(t, cache.DecisionNone, adm.Decision)
+		}
+	})
+
+	t.Run("rejects cold candidates and admits hot ones", func(t *testing.T) {
+		t.Parallel()
+
+		policy := cache.NewTinyLFU(10)
+
+		for i := range 10 {
+			key := fmt.Sprintf("hot-%d", i)
+			policy.Add(key)
+
+			for range 3 {
+				policy.Access(key)
+			}
+		}
+
+		// A cold key pushes a window key into the contest and loses.
+		adm := policy.Add("cold-1")
+		assert.Equal(t, cache.DecisionReject, adm.Decision)
+		require.Len(t, adm.Evicted, 1)
+
+		// A key seen many times before insertion wins the contest.
+		for range 10 {
+			policy.Access("popular")
+		}
+
+		policy.Add("popular")
+
+		adm = policy.Add("cold-2")
+		assert.Equal(t, cache.DecisionAdmit, adm.Decision)
+		require.Len(t, adm.Evicted, 1)
+		assert.NotEqual(t, "popular", adm.Evicted[0])
+	})
+
+	t.Run("removed keys free capacity", func(t *testing.T) {
+		t.Parallel()
+
+		policy := cache.NewTinyLFU(2)
+
+		policy.Add("a")
+		policy.Add("b")
+		policy.Remove("a")
+		policy.Remove("b")
+		policy.Remove("missing")
+
+		assert.Empty(t, policy.Add("c").Evicted)
+		assert.Empty(t, policy.Add("d").Evicted)
+	})
+}
+
+// hitRatioUnderScan replays a workload where a small hot set is read
+// repeatedly while a long scan of one-off keys streams through the cache.
+func hitRatioUnderScan(t *testing.T, newPolicy cache.NewPolicyFunc) float64 {
+	t.Helper()
+
+	const (
+		capacity = 100
+		hotKeys  = 50
+		rounds   = 200
+		scanSize = 200
+	)
+
+	mcache := cache.New(Logger(t), cache.WithMaxEntries(capacity), cache.WithPolicy(newPolicy))
+
+	t.Cleanup(func() {
+		require.NoError(t, mcache.Close(t.Context()))
+	})
+
+	ctx := t.Context()
+	scanned := 0
+
+	for range rounds {
+		for i := range hotKeys {
+			key := fmt.Sprintf("hot-%d", i)
+			if _, err := mcache.Get(ctx, key); err != nil {
+				require.NoError(t, mcache.Set(ctx, key, i, 0))
+			}
+		}
+
+		for range scanSize {
+			key := fmt.Sprintf("scan-%d", scanned)
+			scanned++
+
+			if _, err := mcache.Get(ctx, key); err != nil {
+				require.NoError(t, mcache.Set(ctx, key, scanned, 0))
+			}
+		}
+	}
+
+	mtrcs := mcache.Metrics()
+
+	return float64(mtrcs.Hits) / float64(mtrcs.Hits+mtrcs.Misses)
+}
+
+func TestTinyLFUResistsScans(t *testing.T) {
+	t.Parallel()
+
+	lruRatio := hitRatioUnderScan(t, cache.NewLRU)
+	tinyLFURatio := hitRatioUnderScan(t, cache.NewTinyLFU)
+
+	t.Logf("hit ratio: lru=%.3f tinylfu=%.3f", lruRatio, tinyLFURatio)
+
+	assert.Greater(t, tinyLFURatio, lruRatio)
+}
+
+func TestMemCacheTinyLFUMetrics(t *testing.T) {
+	t.Parallel()
+
+	mcache := cache.New(Logger(t), cache.WithMaxEntries(10), cache.WithPolicy(cache.NewTinyLFU))
+
+	t.Cleanup(func() {
+		require.NoError(t, mcache.Close(t.Context()))
+	})
+
+	ctx := t.Context()
+
+	for i := range 100 {
+		require.NoError(t, mcache.Set(ctx, fmt.Sprintf("k-%d", i), i, 0))
+	}
+
+	mtrcs := mcache.Metrics()
+
+	assert.Equal(t, 10, mcache.Size())
+	assert.Equal(t, uint64(90), mtrcs.CapacityEvictions)
+	assert.Equal(t, mtrcs.CapacityEvictions, mtrcs.PolicyAdmits+mtrcs.PolicyRejects)
+}
+
+func TestPolicyEvict(t *testing.T) {
+	t.Parallel()
+
+	lru, ok := cache.NewLRU(10).(cache.Evictor)
+	require.True(t, ok)
+
+	_, found := lru.Evict()
+	assert.False(t, found)
+
+	policy, _ := lru.(cache.Policy)
+	policy.Add("a")
+	policy.Add("b")
+	policy.Access("a")
+
+	victim, found := lru.Evict()
+	require.True(t, found)
+	assert.Equal(t, "b", victim, "least recently used first")
+
+	tinyLFU, ok := cache.NewTinyLFU(100).(cache.Evictor)
+	require.True(t, ok)
+
+	policy, _ = tinyLFU.(cache.Policy)
+	policy.Add("old")
+	policy.Add("new") // pushes "old" from the window to probation
+	policy.Add("newer")
+	policy.Access("new") // promotes "new" to protected
+
+	evicted := make([]string, 0, 3)
+	for victim, found := tinyLFU.Evict(); found; victim, found = tinyLFU.Evict() {
+		evicted = append(evicted, victim)
+	}
+
+	assert.Equal(t, []string{"old", "newer", "new"}, evicted, "probation, then window, then protected")
+}
@@ -0,0 +1,97 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom
+
+import (
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/promtext"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Source reports cache metrics. cache.MemCache and cache.Sharded implement it.
+type Source = promtext.Source
+
+// Collector is a prometheus.Collector exporting the metrics of one cache.
+//
+// It exports the families listed by promtext.Families, so a scrape returns
+// the same series as promtext.Handler. Metrics are read from the source on
+// every scrape, so a Collector holds no state of its own.
+type Collector struct {
+	exporter *promtext.Exporter
+	families []promtext.Family
+	descs    []*prometheus.Desc
+	bounds   []float64
+}
+
+// NewCollector returns a Collector exporting the metrics of src.
+func NewCollector(src Source, opts ...Option) *Collector {
+	exporter := promtext.NewExporter(src, opts...)
+	families := promtext.Families()
+
+	c := &Collector{
+		exporter: exporter,
+		families: families,
+		descs:    make([]*prometheus.Desc, len(families)),
+		bounds:   promtext.BucketBounds(),
+	}
+
+	labels := prometheus.Labels{promtext.NameLabel: exporter.Name()}
+	for i, f := range families {
+		c.descs[i] = prometheus.NewDesc(exporter.FQName(f), f.Help, nil, labels)
+	}
+
+	return c
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range c.descs {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	snapshot := c.exporter.Source().Metrics()
+
+	for i, f := range c.families {
+		switch f.Kind {
+		case promtext.Counter:
+			ch <- prometheus.MustNewConstMetric(c.descs[i], prometheus.CounterValue, f.Value(&snapshot))
+		case promtext.Gauge:
+			ch <- prometheus.MustNewConstMetric(c.descs[i], prometheus.GaugeValue, f.Value(&snapshot))
+		case promtext.Histogram:
+			ch <- c.histogram(c.descs[i], f.Histogram(&snapshot))
+		}
+	}
+}
+
+func (c *Collector) histogram(desc *prometheus.Desc, hist *cache.Histogram) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(c.bounds))
+	for i, count := range promtext.Cumulative(hist) {
+		buckets[c.bounds[i]] = count
+	}
+
+	sum := time.Duration(hist.SumNs).Seconds() //nolint:gosec // sums stay far below 1<<63 ns
+
+	return prometheus.MustNewConstHistogram(desc, hist.Count, sum, buckets)
+}
+
+// Register registers a Collector for src with reg.
+func Register(reg prometheus.Registerer, src Source, opts ...Option) error {
+	return reg.Register(NewCollector(src, opts...))
+}
@@ -0,0 +1,31 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package prom exports cache.Metrics in the Prometheus format.
+//
+// A Collector adapts anything that reports cache.Metrics, such as a
+// cache.MemCache or cache.Sharded, to a prometheus.Collector. Every exported
+// series carries a "cache" label naming the cache, so several caches can be
+// registered with the same registry:
+//
+//	reg := prometheus.NewRegistry()
+//	reg.MustRegister(prom.NewCollector(users, prom.WithName("users")))
+//	reg.MustRegister(prom.NewCollector(sessions, prom.WithName("sessions")))
+//
+// Collectors export the families listed by promtext.Families: the counters
+// and gauges of cache.Metrics, the hit ratio over the last one and five
+// minutes, and the Get, Set, Delete and cleanup latency histograms. Services
+// that do not use client_golang can serve the same series with package
+// promtext, which does not import it.
+package prom
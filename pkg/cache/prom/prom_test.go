@@ -0,0 +1,203 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prom_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/prom"
+	"github.com/patraden/toolkit/pkg/cache/promtext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixed is a Source reporting constant metrics.
+type fixed cache.Metrics
+
+func (f fixed) Metrics() cache.Metrics {
+	return cache.Metrics(f)
+}
+
+var _ prometheus.Collector = (*prom.Collector)(nil)
+
+const exposition = `# HELP cache_hits_total Number of Get calls that found a live entry.
+# TYPE cache_hits_total counter
+cache_hits_total{cache="users"} 10
+cache_hits_total{cache="se\"ss\\ions"} 1
+# HELP cache_misses_total Number of Get calls that found no live entry.
+# TYPE cache_misses_total counter
+cache_misses_total{cache="users"} 2
+cache_misses_total{cache="se\"ss\\ions"} 0
+# HELP cache_sets_total Number of stored entries.
+# TYPE cache_sets_total counter
+cache_sets_total{cache="users"} 3
+cache_sets_total{cache="se\"ss\\ions"} 0
+# HELP cache_deletes_total Number of entries removed by Delete.
+# TYPE cache_deletes_total counter
+cache_deletes_total{cache="users"} 4
+cache_deletes_total{cache="se\"ss\\ions"} 0
+# HELP cache_lazy_evictions_total Number of expired entries removed on access.
+# TYPE cache_lazy_evictions_total counter
+cache_lazy_evictions_total{cache="users"} 5
+cache_lazy_evictions_total{cache="se\"ss\\ions"} 0
+# HELP cache_scheduled_evictions_total Number of expired entries removed by the background cleaner.
+# TYPE cache_scheduled_evictions_total counter
+cache_scheduled_evictions_total{cache="users"} 6
+cache_scheduled_evictions_total{cache="se\"ss\\ions"} 0
+# HELP cache_capacity_evictions_total Number of live entries evicted to respect the capacity bound.
+# TYPE cache_capacity_evictions_total counter
+cache_capacity_evictions_total{cache="users"} 7
+cache_capacity_evictions_total{cache="se\"ss\\ions"} 0
+# HELP cache_cleanup_runs_total Number of background cleanup runs.
+# TYPE cache_cleanup_runs_total counter
+cache_cleanup_runs_total{cache="users"} 8
+cache_cleanup_runs_total{cache="se\"ss\\ions"} 0
+# HELP cache_last_cleanup_duration_seconds Duration of the last background cleanup run.
+# TYPE cache_last_cleanup_duration_seconds gauge
+cache_last_cleanup_duration_seconds{cache="users"} 1.5
+cache_last_cleanup_duration_seconds{cache="se\"ss\\ions"} 0
+# HELP cache_last_cleanup_items Number of entries removed by the last background cleanup run.
+# TYPE cache_last_cleanup_items gauge
+cache_last_cleanup_items{cache="users"} 9
+cache_last_cleanup_items{cache="se\"ss\\ions"} 0
+`
+
+// sources are the caches exported by the tests, by name.
+func sources() map[string]fixed {
+	users := fixed{
+		Hits:                  10,
+		Misses:                2,
+		Sets:                  3,
+		Deletes:               4,
+		LazyEvictions:         5,
+		ScheduledEvictions:    6,
+		CapacityEvictions:     7,
+		CleanupRuns:           8,
+		LastCleanupDurationMs: 1500,
+		LastCleanupItems:      9,
+		RecentHits5m:          1,
+		RecentMisses5m:        3,
+	}
+	users.SetLatency.Count = 2
+	users.SetLatency.SumNs = uint64(3 * time.Second)
+	users.SetLatency.Buckets[cache.LatencyBuckets-1] = 2
+
+	return map[string]fixed{"users": users, `se"ss\ions`: {Hits: 1}}
+}
+
+// registry returns a registry with a Collector for every source.
+func registry(t *testing.T) *prometheus.Registry {
+	t.Helper()
+
+	reg := prometheus.NewPedanticRegistry()
+	for name, src := range sources() {
+		require.NoError(t, reg.Register(prom.NewCollector(src, prom.WithName(name))))
+	}
+
+	return reg
+}
+
+// scalars are the families listed in exposition.
+var scalars = []string{
+	"cache_hits_total", "cache_misses_total", "cache_sets_total", "cache_deletes_total",
+	"cache_lazy_evictions_total", "cache_scheduled_evictions_total", "cache_capacity_evictions_total",
+	"cache_cleanup_runs_total", "cache_last_cleanup_duration_seconds", "cache_last_cleanup_items",
+}
+
+func TestCollector(t *testing.T) {
+	t.Parallel()
+
+	reg := registry(t)
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(exposition), scalars...))
+
+	err := prom.Register(reg, fixed{}, prom.WithName("users"))
+	require.Error(t, err, "a cache name is registered only once")
+}
+
+func TestCollectorMatchesHandler(t *testing.T) {
+	t.Parallel()
+
+	exporters := make([]*promtext.Exporter, 0, len(sources()))
+	for name, src := range sources() {
+		exporters = append(exporters, promtext.NewExporter(src, promtext.WithName(name)))
+	}
+
+	rec := httptest.NewRecorder()
+	promtext.Handler(exporters...).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	require.NoError(t, testutil.GatherAndCompare(registry(t), rec.Body),
+		"the Collector and promtext.Handler export the same series")
+
+	hist := `# HELP cache_set_latency_seconds Latency of writes.
+# TYPE cache_set_latency_seconds histogram
+`
+	for _, bound := range promtext.BucketBounds() {
+		hist += `cache_set_latency_seconds_bucket{cache="users",le="` + strconv.FormatFloat(bound, 'g', -1, 64) + `"} 0
+`
+	}
+
+	hist += `cache_set_latency_seconds_bucket{cache="users",le="+Inf"} 2
+cache_set_latency_seconds_sum{cache="users"} 3
+cache_set_latency_seconds_count{cache="users"} 2
+# HELP cache_hit_ratio_5m Share of Get calls that found a live entry over the last five minutes.
+# TYPE cache_hit_ratio_5m gauge
+cache_hit_ratio_5m{cache="users"} 0.25
+`
+
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(prom.NewCollector(sources()["users"], prom.WithName("users"))))
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(hist),
+		"cache_set_latency_seconds", "cache_hit_ratio_5m"))
+}
+
+func TestMemCacheCollector(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := cache.WithDeleteInterval(time.Hour, zerolog.New(os.Stdout))
+
+	t.Cleanup(func() {
+		require.NoError(t, mcache.Close(ctx))
+	})
+
+	require.NoError(t, mcache.Set(ctx, "key", "value", 0))
+
+	_, err := mcache.Get(ctx, "key")
+	require.NoError(t, err)
+
+	collector := prom.NewCollector(mcache, prom.WithNamespace("svc_cache"), prom.WithName("hot"))
+
+	expected := `# HELP svc_cache_hits_total Number of Get calls that found a live entry.
+# TYPE svc_cache_hits_total counter
+svc_cache_hits_total{cache="hot"} 1
+# HELP svc_cache_sets_total Number of stored entries.
+# TYPE svc_cache_sets_total counter
+svc_cache_sets_total{cache="hot"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"svc_cache_hits_total", "svc_cache_sets_total"))
+	assert.Equal(t, len(promtext.Families()), testutil.CollectAndCount(collector))
+}
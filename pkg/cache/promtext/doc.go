@@ -0,0 +1,32 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package promtext serves cache.Metrics in the Prometheus text exposition
+// format without depending on the Prometheus client library.
+//
+// An Exporter names the series of one cache, such as a cache.MemCache or
+// cache.Sharded. Every series carries a "cache" label with that name, so
+// several caches can be served by the same Handler:
+//
+//	http.Handle("/metrics", promtext.Handler(
+//		promtext.NewExporter(users, promtext.WithName("users")),
+//		promtext.NewExporter(sessions, promtext.WithName("sessions")),
+//	))
+//
+// Families lists the exported series. Besides the counters and gauges of
+// cache.Metrics, it includes the hit ratio over the last one and five minutes
+// and the Get, Set, Delete and cleanup latency histograms. Services using
+// client_golang should register a prom.Collector instead, which exports the
+// same families.
+package promtext
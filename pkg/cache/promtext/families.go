@@ -0,0 +1,175 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promtext
+
+import (
+	"slices"
+
+	"github.com/patraden/toolkit/pkg/cache"
+)
+
+// Source reports cache metrics. cache.MemCache and cache.Sharded implement it.
+type Source interface {
+	Metrics() cache.Metrics
+}
+
+// Kind is the Prometheus type of a metric family.
+type Kind int
+
+// Kinds of exported families.
+const (
+	Counter Kind = iota
+	Gauge
+	Histogram
+)
+
+// String returns the name of k used in TYPE lines.
+func (k Kind) String() string {
+	switch k {
+	case Counter:
+		return "counter"
+	case Gauge:
+		return "gauge"
+	case Histogram:
+		return "histogram"
+	default:
+		return "untyped"
+	}
+}
+
+// Family describes one exported metric family derived from cache.Metrics.
+//
+// Counters and gauges are read with Value, histograms with Histogram; the
+// other function is nil. Histogram buckets are bounded by
+// cache.LatencyBounds and exported in seconds.
+type Family struct {
+	Name      string // Name without the namespace, e.g. "hits_total"
+	Help      string
+	Kind      Kind
+	Value     func(m *cache.Metrics) float64
+	Histogram func(m *cache.Metrics) *cache.Histogram
+}
+
+// msPerSecond converts LastCleanupDurationMs to seconds.
+const msPerSecond = 1000
+
+// families lists the exported families in exposition order.
+var families = []Family{
+	counter("hits_total", "Number of Get calls that found a live entry.",
+		func(m *cache.Metrics) uint64 { return m.Hits }),
+	counter("misses_total", "Number of Get calls that found no live entry.",
+		func(m *cache.Metrics) uint64 { return m.Misses }),
+	counter("sets_total", "Number of stored entries.",
+		func(m *cache.Metrics) uint64 { return m.Sets }),
+	counter("deletes_total", "Number of entries removed by Delete.",
+		func(m *cache.Metrics) uint64 { return m.Deletes }),
+	counter("lazy_evictions_total", "Number of expired entries removed on access.",
+		func(m *cache.Metrics) uint64 { return m.LazyEvictions }),
+	counter("scheduled_evictions_total", "Number of expired entries removed by the background cleaner.",
+		func(m *cache.Metrics) uint64 { return m.ScheduledEvictions }),
+	counter("capacity_evictions_total", "Number of live entries evicted to respect the capacity bound.",
+		func(m *cache.Metrics) uint64 { return m.CapacityEvictions }),
+	counter("cleanup_runs_total", "Number of background cleanup runs.",
+		func(m *cache.Metrics) uint64 { return m.CleanupRuns }),
+	{
+		Name:      "last_cleanup_duration_seconds",
+		Help:      "Duration of the last background cleanup run.",
+		Kind:      Gauge,
+		Value:     func(m *cache.Metrics) float64 { return float64(m.LastCleanupDurationMs) / msPerSecond },
+		Histogram: nil,
+	},
+	{
+		Name:      "last_cleanup_items",
+		Help:      "Number of entries removed by the last background cleanup run.",
+		Kind:      Gauge,
+		Value:     func(m *cache.Metrics) float64 { return float64(m.LastCleanupItems) },
+		Histogram: nil,
+	},
+	{
+		Name:      "hit_ratio_1m",
+		Help:      "Share of Get calls that found a live entry over the last minute.",
+		Kind:      Gauge,
+		Value:     (*cache.Metrics).HitRatio1m,
+		Histogram: nil,
+	},
+	{
+		Name:      "hit_ratio_5m",
+		Help:      "Share of Get calls that found a live entry over the last five minutes.",
+		Kind:      Gauge,
+		Value:     (*cache.Metrics).HitRatio5m,
+		Histogram: nil,
+	},
+	histogram("get_latency_seconds", "Latency of Get calls.",
+		func(m *cache.Metrics) *cache.Histogram { return &m.GetLatency }),
+	histogram("set_latency_seconds", "Latency of writes.",
+		func(m *cache.Metrics) *cache.Histogram { return &m.SetLatency }),
+	histogram("delete_latency_seconds", "Latency of Delete calls.",
+		func(m *cache.Metrics) *cache.Histogram { return &m.DeleteLatency }),
+	histogram("cleanup_duration_seconds", "Duration of background cleanup runs.",
+		func(m *cache.Metrics) *cache.Histogram { return &m.CleanupLatency }),
+}
+
+func counter(name, help string, value func(m *cache.Metrics) uint64) Family {
+	return Family{
+		Name:      name,
+		Help:      help,
+		Kind:      Counter,
+		Value:     func(m *cache.Metrics) float64 { return float64(value(m)) },
+		Histogram: nil,
+	}
+}
+
+func histogram(name, help string, hist func(m *cache.Metrics) *cache.Histogram) Family {
+	return Family{
+		Name:      name,
+		Help:      help,
+		Kind:      Histogram,
+		Value:     nil,
+		Histogram: hist,
+	}
+}
+
+// Families returns the exported metric families in exposition order.
+func Families() []Family {
+	return slices.Clone(families)
+}
+
+// BucketBounds returns the upper bounds of the histogram buckets in seconds,
+// without the implicit +Inf bucket.
+func BucketBounds() []float64 {
+	bounds := cache.LatencyBounds()
+	seconds := make([]float64, len(bounds))
+
+	for i, bound := range bounds {
+		seconds[i] = bound.Seconds()
+	}
+
+	return seconds
+}
+
+// Cumulative returns the cumulative counts of h for each bound returned by
+// BucketBounds, as the exposition format expects them.
+func Cumulative(h *cache.Histogram) []uint64 {
+	counts := make([]uint64, len(h.Buckets)-1)
+
+	var total uint64
+
+	for i := range counts {
+		total += h.Buckets[i]
+		counts[i] = total
+	}
+
+	return counts
+}
@@ -0,0 +1,156 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promtext
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+)
+
+const (
+	// contentType is the media type of the text exposition format.
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+	// NameLabel is the label carrying the cache name on every series.
+	NameLabel = "cache"
+)
+
+// Exporter names the series of one cache.
+//
+// Metrics are read from the source on every scrape, so an Exporter holds no
+// state of its own.
+type Exporter struct {
+	src       Source
+	namespace string
+	name      string
+}
+
+// NewExporter returns an Exporter for the metrics of src.
+func NewExporter(src Source, opts ...Option) *Exporter {
+	cfg := newOptions(opts)
+
+	return &Exporter{
+		src:       src,
+		namespace: cfg.namespace,
+		name:      cfg.name,
+	}
+}
+
+// Source returns the source the Exporter reads.
+func (e *Exporter) Source() Source {
+	return e.src
+}
+
+// Name returns the value of the "cache" label.
+func (e *Exporter) Name() string {
+	return e.name
+}
+
+// FQName returns the fully qualified name of family f, e.g.
+// cache_hits_total.
+func (e *Exporter) FQName(f Family) string {
+	return e.namespace + "_" + f.Name
+}
+
+// Handler returns an http.Handler serving the metrics of exporters in the
+// Prometheus text exposition format.
+//
+// Series of exporters sharing a namespace are grouped under one HELP and
+// TYPE header; exporters should have distinct names.
+func Handler(exporters ...*Exporter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+
+		var buf bytes.Buffer
+
+		writeText(&buf, exporters)
+		_, _ = w.Write(buf.Bytes())
+	})
+}
+
+// writeText writes one family per metric and namespace, in the order the
+// series are first seen.
+func writeText(w *bytes.Buffer, exporters []*Exporter) {
+	snapshots := make([]cache.Metrics, len(exporters))
+	for i, e := range exporters {
+		snapshots[i] = e.src.Metrics()
+	}
+
+	bounds := BucketBounds()
+	written := make(map[string]bool)
+
+	for _, f := range families {
+		for j, e := range exporters {
+			name := e.FQName(f)
+			if written[name] {
+				continue
+			}
+
+			written[name] = true
+
+			w.WriteString("# HELP " + name + " " + escapeHelp(f.Help) + "\n")
+			w.WriteString("# TYPE " + name + " " + f.Kind.String() + "\n")
+
+			for k := j; k < len(exporters); k++ {
+				if exporters[k].FQName(f) != name {
+					continue
+				}
+
+				label := NameLabel + `="` + escapeLabel(exporters[k].name) + `"`
+
+				if f.Kind == Histogram {
+					writeHistogram(w, name, label, bounds, f.Histogram(&snapshots[k]))
+				} else {
+					writeSample(w, name, label, f.Value(&snapshots[k]))
+				}
+			}
+		}
+	}
+}
+
+func writeHistogram(w *bytes.Buffer, name, label string, bounds []float64, hist *cache.Histogram) {
+	for i, count := range Cumulative(hist) {
+		writeSample(w, name+"_bucket", label+`,le="`+formatFloat(bounds[i])+`"`, float64(count))
+	}
+
+	writeSample(w, name+"_bucket", label+`,le="+Inf"`, float64(hist.Count))
+	writeSample(w, name+"_sum", label, float64(hist.SumNs)/float64(time.Second))
+	writeSample(w, name+"_count", label, float64(hist.Count))
+}
+
+func writeSample(w *bytes.Buffer, name, labels string, value float64) {
+	w.WriteString(name + "{" + labels + "} " + formatFloat(value) + "\n")
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
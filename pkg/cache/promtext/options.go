@@ -0,0 +1,64 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promtext
+
+const (
+	// DefaultNamespace prefixes the names of all exported series.
+	DefaultNamespace = "cache"
+	// DefaultName is the value of the "cache" label when no name is given.
+	DefaultName = "default"
+)
+
+// Option configures an Exporter.
+type Option func(*options)
+
+type options struct {
+	namespace string
+	name      string
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		namespace: DefaultNamespace,
+		name:      DefaultName,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithName sets the value of the "cache" label. An empty name keeps
+// DefaultName.
+func WithName(name string) Option {
+	return func(o *options) {
+		if name != "" {
+			o.name = name
+		}
+	}
+}
+
+// WithNamespace sets the prefix of the exported series names, for example
+// "myservice_cache" for myservice_cache_hits_total. An empty namespace keeps
+// DefaultNamespace.
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		if namespace != "" {
+			o.namespace = namespace
+		}
+	}
+}
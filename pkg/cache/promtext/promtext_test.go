@@ -0,0 +1,142 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promtext_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/promtext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixed is a Source reporting constant metrics.
+type fixed cache.Metrics
+
+func (f fixed) Metrics() cache.Metrics {
+	return cache.Metrics(f)
+}
+
+var (
+	_ promtext.Source = (*cache.MemCache)(nil)
+	_ promtext.Source = (*cache.Sharded)(nil)
+)
+
+func serve(t *testing.T, exporters ...*promtext.Exporter) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	promtext.Handler(exporters...).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	return rec.Body.String()
+}
+
+// family returns the lines of body describing the family name.
+func family(body, name string) string {
+	var out strings.Builder
+
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(strings.TrimPrefix(line, "# "))
+
+		switch {
+		case len(fields) > 1 && (fields[0] == "HELP" || fields[0] == "TYPE") && fields[1] == name,
+			strings.HasPrefix(line, name+"{"),
+			strings.HasPrefix(line, name+"_bucket{"),
+			strings.HasPrefix(line, name+"_sum{"),
+			strings.HasPrefix(line, name+"_count{"):
+			out.WriteString(line + "\n")
+		}
+	}
+
+	return out.String()
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	users := fixed{
+		Hits:                  10,
+		LastCleanupDurationMs: 1500,
+		RecentHits1m:          3,
+		RecentMisses1m:        1,
+	}
+	users.GetLatency.Count = 3
+	users.GetLatency.SumNs = uint64(500*time.Nanosecond + 2*time.Millisecond)
+	users.GetLatency.Buckets[0] = 1
+	users.GetLatency.Buckets[6] = 2
+
+	exporter := promtext.NewExporter(users, promtext.WithName("users"))
+	body := serve(t,
+		exporter,
+		promtext.NewExporter(fixed{Hits: 1}, promtext.WithName(`se"ss\ions`)),
+		promtext.NewExporter(fixed{Hits: 2}, promtext.WithNamespace("svc_cache")),
+	)
+
+	assert.Equal(t, `# HELP cache_hits_total Number of Get calls that found a live entry.
+# TYPE cache_hits_total counter
+cache_hits_total{cache="users"} 10
+cache_hits_total{cache="se\"ss\\ions"} 1
+`, family(body, "cache_hits_total"))
+
+	assert.Equal(t, `# HELP svc_cache_hits_total Number of Get calls that found a live entry.
+# TYPE svc_cache_hits_total counter
+svc_cache_hits_total{cache="default"} 2
+`, family(body, "svc_cache_hits_total"))
+
+	assert.Equal(t, `# HELP cache_last_cleanup_duration_seconds Duration of the last background cleanup run.
+# TYPE cache_last_cleanup_duration_seconds gauge
+cache_last_cleanup_duration_seconds{cache="users"} 1.5
+cache_last_cleanup_duration_seconds{cache="se\"ss\\ions"} 0
+`, family(body, "cache_last_cleanup_duration_seconds"))
+
+	assert.Equal(t, `# HELP cache_hit_ratio_1m Share of Get calls that found a live entry over the last minute.
+# TYPE cache_hit_ratio_1m gauge
+cache_hit_ratio_1m{cache="users"} 0.75
+cache_hit_ratio_1m{cache="se\"ss\\ions"} 0
+`, family(body, "cache_hit_ratio_1m"))
+
+	assert.Equal(t, 2*len(promtext.Families()), strings.Count(body, "# TYPE "), "one family per metric and namespace")
+
+	assert.Equal(t, `# HELP cache_get_latency_seconds Latency of Get calls.
+# TYPE cache_get_latency_seconds histogram
+cache_get_latency_seconds_bucket{cache="users",le="1e-06"} 1
+cache_get_latency_seconds_bucket{cache="users",le="5e-06"} 1
+cache_get_latency_seconds_bucket{cache="users",le="1e-05"} 1
+cache_get_latency_seconds_bucket{cache="users",le="5e-05"} 1
+cache_get_latency_seconds_bucket{cache="users",le="0.0001"} 1
+cache_get_latency_seconds_bucket{cache="users",le="0.0005"} 1
+cache_get_latency_seconds_bucket{cache="users",le="0.001"} 3
+cache_get_latency_seconds_bucket{cache="users",le="0.005"} 3
+cache_get_latency_seconds_bucket{cache="users",le="0.01"} 3
+cache_get_latency_seconds_bucket{cache="users",le="0.05"} 3
+cache_get_latency_seconds_bucket{cache="users",le="0.1"} 3
+cache_get_latency_seconds_bucket{cache="users",le="0.5"} 3
+cache_get_latency_seconds_bucket{cache="users",le="1"} 3
+cache_get_latency_seconds_bucket{cache="users",le="+Inf"} 3
+cache_get_latency_seconds_sum{cache="users"} 0.0020005
+cache_get_latency_seconds_count{cache="users"} 3
+`, family(serve(t, exporter), "cache_get_latency_seconds"))
+}
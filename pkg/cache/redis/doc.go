@@ -0,0 +1,36 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redis implements cache.Cache on top of a Redis server.
+//
+// The client speaks RESP2 and, when configured with WithProtocol(3), RESP3
+// over a small connection pool. It needs no third-party driver.
+//
+// Values are stored as bytes (see cache.EncodeValue), so Get always returns
+// []byte. Use cache.Typed with a Codec to store other types. TTLs map to the
+// PX option of SET and are read back with PTTL, and Digest is computed server-side with a Lua script when
+// scripting is available, falling back to hashing the value client-side.
+// GetMany is a single MGET; SetMany pipelines one SET per item; Delete and
+// DeleteMany are a single DEL.
+//
+// Example usage:
+//
+//	client := redis.New("localhost:6379", logger, redis.WithPoolSize(16))
+//	defer client.Close(context.Background())
+//
+//	client.Set(ctx, "key", "value", time.Minute)
+//	value, err := client.Get(ctx, "key") // []byte("value")
+//
+// The redistest package provides an in-process stand-in server for tests.
+package redis
@@ -0,0 +1,153 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis_test
+
+import (
+	"errors"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// runLua is a redistest.ScriptFunc running scripts in a Lua 5.1 interpreter
+// with the base, table, string and math libraries, the bit library functions
+// band, bor and bxor, and redis.call. Results are converted as Redis converts
+// them.
+//
+// It lives in a test file so that the interpreter is a test-only dependency.
+func runLua(source string, keys, argv []string, call func(args ...string) (any, error)) (any, error) {
+	state := lua.NewState(lua.Options{SkipOpenLibs: true})
+	defer state.Close()
+
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{name: lua.BaseLibName, open: lua.OpenBase},
+		{name: lua.TabLibName, open: lua.OpenTable},
+		{name: lua.StringLibName, open: lua.OpenString},
+		{name: lua.MathLibName, open: lua.OpenMath},
+	} {
+		state.Push(state.NewFunction(lib.open))
+		state.Push(lua.LString(lib.name))
+		state.Call(1, 0)
+	}
+
+	state.SetGlobal("KEYS", stringTable(state, keys))
+	state.SetGlobal("ARGV", stringTable(state, argv))
+	state.SetGlobal("redis", state.SetFuncs(state.NewTable(), map[string]lua.LGFunction{
+		"call": luaCall(call),
+	}))
+	state.SetGlobal("bit", state.SetFuncs(state.NewTable(), map[string]lua.LGFunction{
+		"band": bitOp(func(a, b uint32) uint32 { return a & b }),
+		"bor":  bitOp(func(a, b uint32) uint32 { return a | b }),
+		"bxor": bitOp(func(a, b uint32) uint32 { return a ^ b }),
+	}))
+
+	if err := state.DoString(source); err != nil {
+		var apiErr *lua.ApiError
+		if errors.As(err, &apiErr) {
+			err = errors.New(apiErr.Object.String()) //nolint:err113 // script error text
+		}
+
+		return nil, err
+	}
+
+	if state.GetTop() == 0 {
+		return nil, nil
+	}
+
+	return fromLua(state.Get(-1)), nil
+}
+
+// luaCall returns redis.call running commands with call.
+func luaCall(call func(args ...string) (any, error)) lua.LGFunction {
+	return func(state *lua.LState) int {
+		args := make([]string, state.GetTop())
+		for i := range args {
+			args[i] = lua.LVAsString(state.Get(i + 1))
+		}
+
+		result, err := call(args...)
+		if err != nil {
+			state.RaiseError("ERR %s", err.Error())
+			return 0
+		}
+
+		value, ok := result.([]byte)
+		if !ok {
+			state.Push(lua.LFalse)
+			return 1
+		}
+
+		state.Push(lua.LString(value))
+
+		return 1
+	}
+}
+
+func stringTable(state *lua.LState, values []string) *lua.LTable {
+	table := state.CreateTable(len(values), 0)
+	for _, value := range values {
+		table.Append(lua.LString(value))
+	}
+
+	return table
+}
+
+// bitOp returns a bit library function folding its arguments with op over
+// 32-bit integers, returning a signed result like LuaBitOp.
+func bitOp(op func(a, b uint32) uint32) lua.LGFunction {
+	return func(state *lua.LState) int {
+		result := uint32(int64(state.CheckNumber(1))) //nolint:gosec // wraps like LuaBitOp
+		for i := 2; i <= state.GetTop(); i++ {
+			result = op(result, uint32(int64(state.CheckNumber(i)))) //nolint:gosec // wraps like LuaBitOp
+		}
+
+		state.Push(lua.LNumber(int32(result))) //nolint:gosec // signed like LuaBitOp
+
+		return 1
+	}
+}
+
+// fromLua converts a script result: strings to bulk strings, numbers to
+// integers, true to 1, false and nil to null, tables with an err field to
+// errors and other tables to arrays.
+func fromLua(value lua.LValue) any {
+	switch v := value.(type) {
+	case lua.LString:
+		return []byte(v)
+	case lua.LNumber:
+		return int64(v)
+	case lua.LBool:
+		if v {
+			return int64(1)
+		}
+
+		return nil
+	case *lua.LTable:
+		if msg := v.RawGetString("err"); msg != lua.LNil {
+			return errors.New(msg.String()) //nolint:err113 // script error reply
+		}
+
+		elems := make([]any, v.Len())
+		for i := range elems {
+			elems[i] = fromLua(v.RawGetInt(i + 1))
+		}
+
+		return elems
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import "time"
+
+const (
+	// DefaultPoolSize is the default maximum number of open connections.
+	DefaultPoolSize = 10
+	// DefaultDialTimeout bounds connection establishment when the caller's
+	// context has no earlier deadline.
+	DefaultDialTimeout = 5 * time.Second
+)
+
+// Option configures a Client.
+type Option func(*options)
+
+type options struct {
+	username    string
+	password    string
+	db          int
+	protocol    int
+	poolSize    int
+	dialTimeout time.Duration
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		username:    "",
+		password:    "",
+		db:          0,
+		protocol:    2,
+		poolSize:    DefaultPoolSize,
+		dialTimeout: DefaultDialTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithPoolSize sets the maximum number of open connections. Values <= 0 keep
+// DefaultPoolSize.
+func WithPoolSize(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.poolSize = n
+		}
+	}
+}
+
+// WithDialTimeout sets the connection establishment timeout. Values <= 0 keep
+// DefaultDialTimeout.
+func WithDialTimeout(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.dialTimeout = d
+		}
+	}
+}
+
+// WithAuth authenticates new connections. An empty username uses the legacy
+// single-password AUTH form.
+func WithAuth(username, password string) Option {
+	return func(o *options) {
+		o.username = username
+		o.password = password
+	}
+}
+
+// WithDB selects the logical database used by new connections.
+func WithDB(db int) Option {
+	return func(o *options) {
+		o.db = db
+	}
+}
+
+// WithProtocol selects the RESP protocol version negotiated with HELLO.
+// Version 3 enables RESP3 replies; any other value keeps RESP2.
+func WithProtocol(version int) Option {
+	return func(o *options) {
+		if version == 3 { //nolint:mnd // RESP3
+			o.protocol = version
+		} else {
+			o.protocol = 2
+		}
+	}
+}
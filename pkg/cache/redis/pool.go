@@ -0,0 +1,251 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// conn is a single connection to the server.
+type conn struct {
+	netConn net.Conn
+	reader  *bufio.Reader
+	writer  *bufio.Writer
+}
+
+// do sends one command and reads its reply, honoring ctx for both deadline
+// and cancellation.
+func (c *conn) do(ctx context.Context, args ...[]byte) (any, error) {
+	replies, err := c.pipeline(ctx, [][][]byte{args})
+	if err != nil {
+		return nil, err
+	}
+
+	return replies[0], nil
+}
+
+// pipeline sends commands in one write and reads their replies in order,
+// honoring ctx like do. Error replies are returned among the replies.
+func (c *conn) pipeline(ctx context.Context, commands [][][]byte) ([]any, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := c.netConn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	} else if err := c.netConn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		// Unblock pending I/O; the connection is discarded afterwards.
+		_ = c.netConn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	for _, args := range commands {
+		if err := writeCommand(c.writer, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]any, len(commands))
+
+	for i := range replies {
+		reply, err := readReply(c.reader)
+		if err != nil {
+			return nil, err
+		}
+
+		replies[i] = reply
+	}
+
+	return replies, nil
+}
+
+// pool is a bounded set of connections to a single server.
+type pool struct {
+	idle   chan *conn
+	slots  chan struct{}
+	done   chan struct{}
+	addr   string
+	opts   options
+	mx     sync.Mutex
+	closed bool
+}
+
+func newPool(addr string, opts options) *pool {
+	return &pool{
+		idle:   make(chan *conn, opts.poolSize),
+		slots:  make(chan struct{}, opts.poolSize),
+		done:   make(chan struct{}),
+		addr:   addr,
+		opts:   opts,
+		mx:     sync.Mutex{},
+		closed: false,
+	}
+}
+
+// get returns an idle connection or dials a new one while the pool has free
+// slots, otherwise it waits for a connection to be released.
+func (p *pool) get(ctx context.Context) (*conn, error) {
+	select {
+	case <-p.done:
+		return nil, ErrPoolClosed
+	default:
+	}
+
+	select {
+	case cn := <-p.idle:
+		return cn, nil
+	default:
+	}
+
+	select {
+	case cn := <-p.idle:
+		return cn, nil
+	case p.slots <- struct{}{}:
+		cn, err := p.dial(ctx)
+		if err != nil {
+			<-p.slots
+			return nil, err
+		}
+
+		return cn, nil
+	case <-p.done:
+		return nil, ErrPoolClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// put returns cn to the pool. Broken connections are closed and free their
+// slot.
+func (p *pool) put(cn *conn, broken bool) {
+	p.mx.Lock()
+	if !broken && !p.closed {
+		select {
+		case p.idle <- cn:
+			p.mx.Unlock()
+			return
+		default:
+		}
+	}
+	p.mx.Unlock()
+
+	_ = cn.netConn.Close()
+	<-p.slots
+}
+
+func (p *pool) dial(ctx context.Context) (*conn, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, p.opts.dialTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+
+	netConn, err := dialer.DialContext(dialCtx, "tcp", p.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	cn := &conn{
+		netConn: netConn,
+		reader:  bufio.NewReader(netConn),
+		writer:  bufio.NewWriter(netConn),
+	}
+
+	if err := p.handshake(dialCtx, cn); err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+
+	return cn, nil
+}
+
+// handshake negotiates the protocol, authenticates and selects the database.
+func (p *pool) handshake(ctx context.Context, cn *conn) error {
+	cmds := make([][][]byte, 0, 2) //nolint:mnd // HELLO/AUTH and SELECT
+
+	switch {
+	case p.opts.protocol == 3: //nolint:mnd // RESP3
+		hello := [][]byte{[]byte("HELLO"), []byte("3")}
+		if p.opts.password != "" {
+			hello = append(hello, []byte("AUTH"), []byte(defaultUser(p.opts.username)), []byte(p.opts.password))
+		}
+
+		cmds = append(cmds, hello)
+	case p.opts.password != "" && p.opts.username != "":
+		cmds = append(cmds, [][]byte{[]byte("AUTH"), []byte(p.opts.username), []byte(p.opts.password)})
+	case p.opts.password != "":
+		cmds = append(cmds, [][]byte{[]byte("AUTH"), []byte(p.opts.password)})
+	}
+
+	if p.opts.db != 0 {
+		cmds = append(cmds, [][]byte{[]byte("SELECT"), []byte(strconv.Itoa(p.opts.db))})
+	}
+
+	for _, args := range cmds {
+		reply, err := cn.do(ctx, args...)
+		if err != nil {
+			return err
+		}
+
+		if rerr, ok := reply.(Error); ok {
+			return fmt.Errorf("redis %s: %w", args[0], rerr)
+		}
+	}
+
+	return nil
+}
+
+// close closes idle connections and makes the pool reject new requests.
+// Connections in use are closed when they are released.
+func (p *pool) close() {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if p.closed {
+		return
+	}
+
+	p.closed = true
+	close(p.done)
+
+	for {
+		select {
+		case cn := <-p.idle:
+			_ = cn.netConn.Close()
+			<-p.slots
+		default:
+			return
+		}
+	}
+}
+
+func defaultUser(username string) string {
+	if username == "" {
+		return "default"
+	}
+
+	return username
+}
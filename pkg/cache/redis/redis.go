@@ -0,0 +1,384 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/rs/zerolog"
+)
+
+// digestScript computes the FNV-64a hash of a string value server-side and
+// returns it as 16 hex digits, matching cache.DigestOf for []byte values.
+// Redis Lua numbers are doubles, so the 64-bit state is kept in eight bytes.
+const digestScript = `local v = redis.call('GET', KEYS[1])
+if not v then return false end
+local h = {0x25, 0x23, 0x22, 0x84, 0xe4, 0x9c, 0xf2, 0xcb}
+for i = 1, #v do
+  h[1] = bit.bxor(h[1], string.byte(v, i))
+  local r, carry = {}, 0
+  for j = 1, 8 do
+    local s = h[j] * 0x1b3 + carry
+    if j > 5 then s = s + h[j - 5] end
+    r[j] = s % 256
+    carry = math.floor(s / 256)
+  end
+  h = r
+end
+return string.format('%02x%02x%02x%02x%02x%02x%02x%02x', h[8], h[7], h[6], h[5], h[4], h[3], h[2], h[1])`
+
+// digestScriptSHA is the SHA1 of digestScript, as used by EVALSHA.
+var digestScriptSHA = fmt.Sprintf("%x", sha1.Sum([]byte(digestScript))) //nolint:gosec // SHA1 is the EVALSHA script id
+
+// Client is a cache.Cache backed by a Redis server.
+//
+// Client is safe for concurrent use. Connections are dialed lazily and reused
+// through a bounded pool. Close must be called to release them.
+type Client struct {
+	pool            *pool
+	log             zerolog.Logger
+	scriptsDisabled atomic.Bool
+}
+
+// New returns a Client for the server at addr (host:port). No connection is
+// made until the first command.
+func New(addr string, log zerolog.Logger, opts ...Option) *Client {
+	return &Client{
+		pool:            newPool(addr, newOptions(opts)),
+		log:             log,
+		scriptsDisabled: atomic.Bool{},
+	}
+}
+
+// Do sends a raw command and returns its reply as described by the package
+// documentation. Error replies are returned as an Error.
+//
+// If ctx is cancelled or its deadline passes, Do returns an error wrapping
+// cache.ErrAborted.
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	raw := make([][]byte, len(args))
+	for i, arg := range args {
+		raw[i] = []byte(arg)
+	}
+
+	return c.do(ctx, raw...)
+}
+
+func (c *Client) do(ctx context.Context, args ...[]byte) (any, error) {
+	cn, err := c.pool.get(ctx)
+	if err != nil {
+		return nil, c.wrapErr(ctx, err)
+	}
+
+	reply, err := cn.do(ctx, args...)
+	if err != nil {
+		c.pool.put(cn, true)
+		return nil, c.wrapErr(ctx, err)
+	}
+
+	c.pool.put(cn, false)
+
+	if rerr, ok := reply.(Error); ok {
+		return nil, rerr
+	}
+
+	return reply, nil
+}
+
+// pipeline sends commands on one connection in a single round trip and
+// returns their replies. If a command failed, pipeline returns the first
+// error reply as an Error once all replies were read.
+func (c *Client) pipeline(ctx context.Context, commands [][][]byte) ([]any, error) {
+	cn, err := c.pool.get(ctx)
+	if err != nil {
+		return nil, c.wrapErr(ctx, err)
+	}
+
+	replies, err := cn.pipeline(ctx, commands)
+	if err != nil {
+		c.pool.put(cn, true)
+		return nil, c.wrapErr(ctx, err)
+	}
+
+	c.pool.put(cn, false)
+
+	for _, reply := range replies {
+		if rerr, ok := reply.(Error); ok {
+			return nil, rerr
+		}
+	}
+
+	return replies, nil
+}
+
+func (c *Client) wrapErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w: %w", cache.ErrAborted, ctx.Err())
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("%w: %w", cache.ErrAborted, err)
+	}
+
+	return err
+}
+
+// Set stores key/value with the provided TTL using SET with the PX option.
+//
+// TTL semantics match cache.Cache; positive TTLs below one millisecond are
+// rounded up. Values are converted with cache.EncodeValue, so non-primitive
+// values are rejected with cache.ErrType.
+func (c *Client) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	args, err := setArgs(key, value, ttl)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.do(ctx, args...)
+
+	return err
+}
+
+// SetMany stores items with one SET command each, pipelined in a single round
+// trip. Values are converted as by Set; if one is rejected, nothing is sent.
+// If a command fails, SetMany returns the first error; the other items may
+// still have been stored.
+func (c *Client) SetMany(ctx context.Context, items ...cache.Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	commands := make([][][]byte, len(items))
+
+	for i, item := range items {
+		args, err := setArgs(item.Key, item.Value, item.TTL)
+		if err != nil {
+			return err
+		}
+
+		commands[i] = args
+	}
+
+	_, err := c.pipeline(ctx, commands)
+
+	return err
+}
+
+func setArgs(key string, value any, ttl time.Duration) ([][]byte, error) {
+	data, err := cache.EncodeValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	args := [][]byte{[]byte("SET"), []byte(key), data}
+	if ttl > 0 {
+		args = append(args, []byte("PX"), strconv.AppendInt(nil, max(ttl.Milliseconds(), 1), 10))
+	}
+
+	return args, nil
+}
+
+// Get returns the value stored under key as []byte, or cache.ErrNotFound if
+// the key is missing or expired.
+func (c *Client) Get(ctx context.Context, key string) (any, error) {
+	reply, err := c.do(ctx, []byte("GET"), []byte(key))
+	if err != nil {
+		return nil, err
+	}
+
+	switch val := reply.(type) {
+	case nil:
+		return nil, cache.ErrNotFound
+	case []byte:
+		return val, nil
+	default:
+		return nil, fmt.Errorf("%w: GET replied %T", ErrProtocol, reply)
+	}
+}
+
+// GetMany returns the values stored under keys as []byte with a single MGET
+// command, and the keys that are missing or expired.
+func (c *Client) GetMany(ctx context.Context, keys ...string) (map[string]any, []string, error) {
+	found := make(map[string]any, len(keys))
+	if len(keys) == 0 {
+		return found, nil, nil
+	}
+
+	args := make([][]byte, 0, len(keys)+1)
+	args = append(args, []byte("MGET"))
+
+	for _, key := range keys {
+		args = append(args, []byte(key))
+	}
+
+	reply, err := c.do(ctx, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values, ok := reply.([]any)
+	if !ok || len(values) != len(keys) {
+		return nil, nil, fmt.Errorf("%w: MGET replied %T", ErrProtocol, reply)
+	}
+
+	var missing []string
+
+	for i, key := range keys {
+		switch val := values[i].(type) {
+		case nil:
+			missing = append(missing, key)
+		case []byte:
+			found[key] = val
+		default:
+			return nil, nil, fmt.Errorf("%w: MGET replied %T for %q", ErrProtocol, values[i], key)
+		}
+	}
+
+	return found, missing, nil
+}
+
+// TTL returns the time left until key expires using PTTL, 0 if it does not
+// expire, or cache.ErrNotFound if it is missing. Tiered uses it to keep local
+// copies from outliving the Redis entry.
+func (c *Client) TTL(ctx context.Context, key string) (time.Duration, error) {
+	reply, err := c.do(ctx, []byte("PTTL"), []byte(key))
+	if err != nil {
+		return 0, err
+	}
+
+	millis, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("%w: PTTL replied %T", ErrProtocol, reply)
+	}
+
+	switch {
+	case millis == -2: //nolint:mnd // missing key
+		return 0, cache.ErrNotFound
+	case millis < 0:
+		return 0, nil
+	default:
+		return max(time.Duration(millis)*time.Millisecond, time.Nanosecond), nil
+	}
+}
+
+// Delete removes keys with a single DEL command. Missing keys are ignored.
+func (c *Client) Delete(ctx context.Context, keys ...string) error {
+	_, err := c.DeleteMany(ctx, keys...)
+	return err
+}
+
+// DeleteMany removes keys with a single DEL command and returns the number of
+// keys that existed, as counted by the server.
+func (c *Client) DeleteMany(ctx context.Context, keys ...string) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	args := make([][]byte, 0, len(keys)+1)
+	args = append(args, []byte("DEL"))
+
+	for _, key := range keys {
+		args = append(args, []byte(key))
+	}
+
+	reply, err := c.do(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("%w: DEL replied %T", ErrProtocol, reply)
+	}
+
+	return int(deleted), nil
+}
+
+// Digest returns the FNV-64a fingerprint of the bytes stored under key, or 0
+// if the key is missing or the digest cannot be computed.
+//
+// The hash is computed server-side by a Lua script so the value does not
+// travel over the network. If the server does not support scripting, Digest
+// falls back to GET and hashes the value locally.
+func (c *Client) Digest(ctx context.Context, key string) cache.Digest {
+	if !c.scriptsDisabled.Load() {
+		digest, err := c.scriptDigest(ctx, key)
+		if err == nil {
+			return digest
+		}
+
+		var rerr Error
+		if !errors.As(err, &rerr) || rerr.Prefix() == "WRONGTYPE" {
+			c.log.Error().Err(err).Str("key", key).Msg("redis digest failed")
+			return 0
+		}
+
+		c.log.Warn().Err(err).Msg("redis scripting unavailable, computing digests client-side")
+		c.scriptsDisabled.Store(true)
+	}
+
+	value, err := c.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, cache.ErrNotFound) {
+			c.log.Error().Err(err).Str("key", key).Msg("redis digest failed")
+		}
+
+		return 0
+	}
+
+	return cache.DigestOf(value)
+}
+
+func (c *Client) scriptDigest(ctx context.Context, key string) (cache.Digest, error) {
+	reply, err := c.do(ctx, []byte("EVALSHA"), []byte(digestScriptSHA), []byte("1"), []byte(key))
+
+	var rerr Error
+	if errors.As(err, &rerr) && rerr.Prefix() == "NOSCRIPT" {
+		reply, err = c.do(ctx, []byte("EVAL"), []byte(digestScript), []byte("1"), []byte(key))
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	hexDigest, ok := reply.([]byte)
+	if !ok {
+		// Missing key: the script returns false, which is a null reply.
+		return 0, nil
+	}
+
+	digest, err := strconv.ParseUint(string(hexDigest), 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid digest %q", ErrProtocol, hexDigest)
+	}
+
+	return cache.Digest(digest), nil
+}
+
+// Close closes all idle connections; connections in use are closed when
+// released. It is safe to call Close multiple times.
+func (c *Client) Close(_ context.Context) error {
+	c.pool.close()
+	return nil
+}
@@ -0,0 +1,358 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/redis"
+	"github.com/patraden/toolkit/pkg/cache/redis/redistest"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ cache.Cache = (*redis.Client)(nil)
+
+func Logger(t *testing.T) zerolog.Logger {
+	t.Helper()
+
+	return zerolog.New(os.Stdout).With().Timestamp().Caller().Logger().Level(zerolog.DebugLevel)
+}
+
+func newClient(t *testing.T, srv *redistest.Server, opts ...redis.Option) *redis.Client {
+	t.Helper()
+
+	client := redis.New(srv.Addr(), Logger(t), opts...)
+
+	t.Cleanup(func() {
+		require.NoError(t, client.Close(t.Context()))
+	})
+
+	return client
+}
+
+func TestClientCommands(t *testing.T) {
+	t.Parallel()
+
+	for _, protocol := range []int{2, 3} {
+		t.Run(fmt.Sprintf("resp%d", protocol), func(t *testing.T) {
+			t.Parallel()
+
+			srv := redistest.NewServer()
+			t.Cleanup(srv.Close)
+
+			client := newClient(t, srv, redis.WithProtocol(protocol))
+			ctx := t.Context()
+
+			require.NoError(t, client.Set(ctx, "str", "value", 0))
+			require.NoError(t, client.Set(ctx, "int", 42, 0))
+			require.NoError(t, client.Set(ctx, "bytes", []byte{0, 1, 2}, 0))
+
+			v, err := client.Get(ctx, "str")
+			require.NoError(t, err)
+			assert.Equal(t, []byte("value"), v)
+
+			v, err = client.Get(ctx, "int")
+			require.NoError(t, err)
+			assert.Equal(t, []byte("42"), v)
+
+			v, err = client.Get(ctx, "bytes")
+			require.NoError(t, err)
+			assert.Equal(t, []byte{0, 1, 2}, v)
+
+			_, err = client.Get(ctx, "missing")
+			require.ErrorIs(t, err, cache.ErrNotFound)
+
+			require.NoError(t, client.Delete(ctx, "str", "int", "missing"))
+			require.NoError(t, client.Delete(ctx))
+
+			_, err = client.Get(ctx, "str")
+			require.ErrorIs(t, err, cache.ErrNotFound)
+
+			size, err := client.Do(ctx, "DBSIZE")
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), size)
+		})
+	}
+}
+
+func TestClientBatch(t *testing.T) {
+	t.Parallel()
+
+	srv := redistest.NewServer()
+	t.Cleanup(srv.Close)
+
+	client := newClient(t, srv)
+	ctx := t.Context()
+
+	require.NoError(t, client.SetMany(ctx,
+		cache.Item{Key: "str", Value: "value", TTL: 0},
+		cache.Item{Key: "int", Value: 42, TTL: 1500 * time.Millisecond},
+		cache.Item{Key: "bytes", Value: []byte{0, 1}, TTL: 0},
+	))
+	assert.Equal(t, 3, srv.Commands(), "one SET per item")
+	assert.Equal(t, 1, srv.Conns(), "items are pipelined on one connection")
+
+	pttl, err := client.Do(ctx, "PTTL", "int")
+	require.NoError(t, err)
+	assert.InDelta(t, 1500, pttl, 50)
+
+	commands := srv.Commands()
+
+	found, missing, err := client.GetMany(ctx, "str", "missing", "int", "bytes")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"str": []byte("value"), "int": []byte("42"), "bytes": []byte{0, 1}}, found)
+	assert.Equal(t, []string{"missing"}, missing)
+	assert.Equal(t, commands+1, srv.Commands(), "one MGET")
+
+	found, missing, err = client.GetMany(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+	assert.Empty(t, missing)
+	require.NoError(t, client.SetMany(ctx))
+
+	err = client.SetMany(ctx,
+		cache.Item{Key: "ok", Value: "v", TTL: 0},
+		cache.Item{Key: "bad", Value: struct{}{}, TTL: 0},
+	)
+	require.ErrorIs(t, err, cache.ErrType)
+
+	_, err = client.Get(ctx, "ok")
+	require.ErrorIs(t, err, cache.ErrNotFound, "rejected batches send nothing")
+
+	commands = srv.Commands()
+
+	deleted, err := client.DeleteMany(ctx, "str", "missing", "int")
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	assert.Equal(t, commands+1, srv.Commands(), "one DEL")
+
+	deleted, err = client.DeleteMany(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
+
+func TestClientTTL(t *testing.T) {
+	t.Parallel()
+
+	srv := redistest.NewServer()
+	t.Cleanup(srv.Close)
+
+	client := newClient(t, srv)
+	ctx := t.Context()
+
+	require.NoError(t, client.Set(ctx, "short", "v", 1500*time.Millisecond))
+	require.NoError(t, client.Set(ctx, "tiny", "v", time.Microsecond))
+	require.NoError(t, client.Set(ctx, "forever", "v", 0))
+
+	pttl, err := client.Do(ctx, "PTTL", "short")
+	require.NoError(t, err)
+	assert.InDelta(t, 1500, pttl, 50)
+
+	pttl, err = client.Do(ctx, "PTTL", "forever")
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), pttl)
+
+	ttl, err := client.TTL(ctx, "short")
+	require.NoError(t, err)
+	assert.InDelta(t, 1500*time.Millisecond, ttl, float64(50*time.Millisecond))
+
+	ttl, err = client.TTL(ctx, "forever")
+	require.NoError(t, err)
+	assert.Zero(t, ttl)
+
+	srv.FastForward(2 * time.Second)
+
+	_, err = client.Get(ctx, "short")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	_, err = client.TTL(ctx, "short")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	_, err = client.Get(ctx, "tiny")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	_, err = client.Get(ctx, "forever")
+	require.NoError(t, err)
+}
+
+func TestClientDigest(t *testing.T) {
+	t.Parallel()
+
+	srv := redistest.NewServer(redistest.WithScripting(runLua))
+	t.Cleanup(srv.Close)
+
+	client := newClient(t, srv)
+	ctx := t.Context()
+
+	every := make([]byte, 256)
+	for i := range every {
+		every[i] = byte(i)
+	}
+
+	values := []any{
+		"", "value", strings.Repeat("long value ", 1000), every,
+		0, 12345, -7, uint64(math.MaxUint64), 1.5, float32(-0.25), true, false,
+	}
+
+	for i, value := range values {
+		key := fmt.Sprintf("key-%d", i)
+		require.NoError(t, client.Set(ctx, key, value, 0))
+
+		// The digest script runs its FNV-1a arithmetic on eight-bit limbs;
+		// it must agree with MemCache digests of the same values.
+		assert.Equal(t, cache.DigestWith(value, cache.NewFNV64a), client.Digest(ctx, key), "%T %v", value, key)
+	}
+
+	assert.Equal(t, cache.Digest(0), client.Digest(ctx, "missing"))
+	assert.Equal(t, len(values)+1, srv.ScriptRuns(), "digests are computed server-side")
+}
+
+func TestClientDigestWithoutScripting(t *testing.T) {
+	t.Parallel()
+
+	srv := redistest.NewServer()
+	t.Cleanup(srv.Close)
+
+	client := newClient(t, srv)
+	ctx := t.Context()
+
+	require.NoError(t, client.Set(ctx, "str", "value", 0))
+	require.NoError(t, client.Set(ctx, "num", 12345, 0))
+
+	// Without scripting the client falls back to hashing values locally and
+	// must agree with MemCache digests.
+	assert.Equal(t, cache.DigestOf("value"), client.Digest(ctx, "str"))
+	assert.Equal(t, cache.DigestOf(12345), client.Digest(ctx, "num"))
+	assert.Equal(t, cache.Digest(0), client.Digest(ctx, "missing"))
+
+	before := srv.Commands()
+
+	client.Digest(ctx, "str")
+	assert.Equal(t, 1, srv.Commands()-before, "scripting is probed only once")
+}
+
+func TestClientAuth(t *testing.T) {
+	t.Parallel()
+
+	srv := redistest.NewServer(redistest.WithPassword("secret"))
+	t.Cleanup(srv.Close)
+
+	ctx := t.Context()
+
+	_, err := newClient(t, srv).Get(ctx, "key")
+	require.ErrorContains(t, err, "NOAUTH")
+
+	_, err = newClient(t, srv, redis.WithAuth("", "wrong")).Get(ctx, "key")
+	require.ErrorContains(t, err, "WRONGPASS")
+
+	for _, protocol := range []int{2, 3} {
+		client := newClient(t, srv, redis.WithAuth("default", "secret"), redis.WithProtocol(protocol), redis.WithDB(1))
+
+		require.NoError(t, client.Set(ctx, "key", "v", 0))
+
+		_, err = client.Get(ctx, "key")
+		require.NoError(t, err)
+	}
+}
+
+func TestClientPool(t *testing.T) {
+	t.Parallel()
+
+	srv := redistest.NewServer()
+	t.Cleanup(srv.Close)
+
+	client := newClient(t, srv, redis.WithPoolSize(2))
+
+	var wg sync.WaitGroup
+
+	for i := range 50 {
+		wg.Add(1)
+
+		go func(n int) {
+			defer wg.Done()
+
+			key := fmt.Sprintf("k-%d", n)
+			assert.NoError(t, client.Set(t.Context(), key, n, time.Minute))
+
+			v, err := client.Get(t.Context(), key)
+			assert.NoError(t, err)
+			assert.Equal(t, []byte(fmt.Sprint(n)), v)
+		}(i)
+	}
+
+	wg.Wait()
+
+	assert.LessOrEqual(t, srv.Conns(), 2)
+}
+
+func TestClientErrors(t *testing.T) {
+	t.Parallel()
+
+	srv := redistest.NewServer()
+	t.Cleanup(srv.Close)
+
+	client := redis.New(srv.Addr(), Logger(t))
+	ctx := t.Context()
+
+	err := client.Set(ctx, "struct", struct{}{}, 0)
+	require.ErrorIs(t, err, cache.ErrType)
+
+	_, err = client.Do(ctx, "NOPE")
+	var rerr redis.Error
+	require.ErrorAs(t, err, &rerr)
+	assert.Equal(t, "ERR", rerr.Prefix())
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err = client.Get(cancelled, "key")
+	require.ErrorIs(t, err, cache.ErrAborted)
+
+	require.NoError(t, client.Close(ctx))
+	require.NoError(t, client.Close(ctx))
+
+	_, err = client.Get(ctx, "key")
+	require.ErrorIs(t, err, redis.ErrPoolClosed)
+}
+
+func TestClientTyped(t *testing.T) {
+	t.Parallel()
+
+	type point struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+
+	srv := redistest.NewServer()
+	t.Cleanup(srv.Close)
+
+	points := cache.NewTypedWithCodec[point](newClient(t, srv), cache.JSONCodec{})
+
+	require.NoError(t, points.Set(t.Context(), "p", point{X: 1, Y: 2}, 0))
+
+	got, err := points.Get(t.Context(), "p")
+	require.NoError(t, err)
+	assert.Equal(t, point{X: 1, Y: 2}, got)
+}
@@ -0,0 +1,122 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redistest
+
+import (
+	"crypto/sha1" //nolint:gosec // SHA1 is the EVALSHA script id
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ScriptFunc runs a script passed to EVAL or EVALSHA with its keys and
+// arguments. call runs a command from the script; only GET is supported, and
+// it returns nil for a missing key.
+//
+// The result is written back as the reply: nil as null, []byte and string as
+// bulk strings, int64 as an integer, []any as an array and an error as an
+// error reply.
+type ScriptFunc func(source string, keys, argv []string, call func(args ...string) (any, error)) (any, error)
+
+// eval runs EVAL, or EVALSHA if bySHA is set: args are the script or its
+// SHA1, the number of keys, the keys and the arguments.
+func (s *Server) eval(sess *session, args []string, bySHA bool) {
+	if len(args) < 2 { //nolint:mnd // script and key count
+		sess.writeError("ERR wrong number of arguments for 'eval' command")
+		return
+	}
+
+	numKeys, err := strconv.Atoi(args[1])
+	if err != nil || numKeys < 0 {
+		sess.writeError("ERR value is not an integer or out of range")
+		return
+	}
+
+	if numKeys > len(args)-2 {
+		sess.writeError("ERR Number of keys can't be greater than number of args")
+		return
+	}
+
+	source := args[0]
+
+	if bySHA {
+		var ok bool
+		if source, ok = s.scripts[strings.ToLower(args[0])]; !ok {
+			sess.writeError("NOSCRIPT No matching script. Please use EVAL.")
+			return
+		}
+	} else {
+		sum := sha1.Sum([]byte(source)) //nolint:gosec // SHA1 is the EVALSHA script id
+		s.scripts[hex.EncodeToString(sum[:])] = source
+	}
+
+	s.runs++
+
+	result, err := s.script(source, args[2:2+numKeys], args[2+numKeys:], s.scriptCall)
+	if err != nil {
+		sess.writeError("ERR user_script: " + err.Error())
+		return
+	}
+
+	sess.writeResult(result)
+}
+
+// scriptCall runs a command for a script. It runs with the server lock held
+// by dispatch.
+func (s *Server) scriptCall(args ...string) (any, error) {
+	if len(args) == 0 {
+		return nil, errors.New("please specify at least one argument for redis.call()") //nolint:err113 // reply text
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		if len(args) != 2 { //nolint:mnd // GET key
+			return nil, errors.New("wrong number of arguments for 'get' command") //nolint:err113 // reply text
+		}
+
+		it, ok := s.lookup(args[1])
+		if !ok {
+			return nil, nil
+		}
+
+		return it.value, nil
+	default:
+		return nil, fmt.Errorf("unknown command '%s' called from script", args[0]) //nolint:err113 // reply text
+	}
+}
+
+// writeResult writes a script result as described by ScriptFunc.
+func (sess *session) writeResult(result any) {
+	switch v := result.(type) {
+	case []byte:
+		sess.writeBulk(v)
+	case string:
+		sess.writeBulk([]byte(v))
+	case int64:
+		sess.writeInt(int(v))
+	case []any:
+		sess.writeArrayHeader(len(v))
+
+		for _, elem := range v {
+			sess.writeResult(elem)
+		}
+	case error:
+		sess.writeError(v.Error())
+	default:
+		sess.writeNull()
+	}
+}
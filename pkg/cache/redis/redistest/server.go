@@ -0,0 +1,582 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redistest provides an in-process stand-in Redis server for tests.
+//
+// The server understands enough of RESP2 and RESP3 to exercise the redis
+// package: connection setup (HELLO, AUTH, SELECT, PING) and the string
+// commands SET, GET, MGET, DEL, EXISTS, PTTL, DBSIZE and FLUSHALL. EVAL and
+// EVALSHA are only understood when WithScripting supplies a ScriptFunc to run
+// scripts; otherwise they are unknown commands, as on a server with scripting
+// disabled. The package has no script interpreter of its own, so it adds
+// nothing to the dependencies of the packages that use it.
+package redistest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type item struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// Server is a stand-in Redis server listening on a loopback address.
+type Server struct {
+	listener net.Listener
+	data     map[string]item
+	conns    map[net.Conn]struct{}
+	scripts  map[string]string // script sources by SHA1
+	password string
+	offset   time.Duration
+	commands int
+	runs     int // scripts run
+	script   ScriptFunc
+	wg       sync.WaitGroup
+	mx       sync.Mutex
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithPassword requires clients to authenticate with password.
+func WithPassword(password string) Option {
+	return func(s *Server) {
+		s.password = password
+	}
+}
+
+// WithScripting makes the server run EVAL and EVALSHA scripts with run.
+func WithScripting(run ScriptFunc) Option {
+	return func(s *Server) {
+		s.script = run
+	}
+}
+
+// NewServer starts a Server on a random loopback port. It panics if it cannot
+// listen, like httptest.NewServer.
+func NewServer(opts ...Option) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("redistest: failed to listen: %v", err))
+	}
+
+	srv := &Server{
+		listener: listener,
+		data:     make(map[string]item),
+		conns:    make(map[net.Conn]struct{}),
+		scripts:  make(map[string]string),
+		password: "",
+		offset:   0,
+		commands: 0,
+		runs:     0,
+		script:   nil,
+		wg:       sync.WaitGroup{},
+		mx:       sync.Mutex{},
+	}
+
+	for _, opt := range opts {
+		opt(srv)
+	}
+
+	srv.wg.Add(1)
+
+	go srv.serve()
+
+	return srv
+}
+
+// Addr returns the host:port the server listens on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server and closes all client connections.
+func (s *Server) Close() {
+	_ = s.listener.Close()
+
+	s.mx.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mx.Unlock()
+
+	s.wg.Wait()
+}
+
+// FastForward moves the server clock forward by d, expiring keys whose TTL
+// elapses.
+func (s *Server) FastForward(d time.Duration) {
+	s.mx.Lock()
+	s.offset += d
+	s.mx.Unlock()
+}
+
+// Commands returns the number of commands the server has processed.
+func (s *Server) Commands() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	return s.commands
+}
+
+// ScriptRuns returns the number of scripts the server has run.
+func (s *Server) ScriptRuns() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	return s.runs
+}
+
+// Conns returns the number of open client connections.
+func (s *Server) Conns() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	return len(s.conns)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mx.Lock()
+		s.conns[conn] = struct{}{}
+		s.mx.Unlock()
+
+		s.wg.Add(1)
+
+		go s.handle(conn)
+	}
+}
+
+// session is the per-connection protocol state.
+type session struct {
+	writer        *bufio.Writer
+	protocol      int
+	authenticated bool
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mx.Lock()
+		delete(s.conns, conn)
+		s.mx.Unlock()
+
+		_ = conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	sess := &session{writer: bufio.NewWriter(conn), protocol: 2, authenticated: s.password == ""} //nolint:mnd // RESP2
+
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				sess.writeError("ERR Protocol error: " + err.Error())
+				_ = sess.writer.Flush()
+			}
+
+			return
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := s.dispatch(sess, args)
+
+		if err := sess.writer.Flush(); err != nil || quit {
+			return
+		}
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "*") {
+		// Inline command, as sent by telnet or redis-cli in a pipe.
+		return strings.Fields(line), nil
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid multibulk length %q", line)
+	}
+
+	args := make([]string, 0, count)
+
+	for range count {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		if !strings.HasPrefix(header, "$") {
+			return nil, fmt.Errorf("expected '$', got %q", header)
+		}
+
+		size, err := strconv.Atoi(strings.TrimRight(header[1:], "\r\n"))
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid bulk length %q", header)
+		}
+
+		buf := make([]byte, size+2) //nolint:mnd // CRLF
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+
+		args = append(args, string(buf[:size]))
+	}
+
+	return args, nil
+}
+
+// dispatch runs one command and reports whether the connection must close.
+func (s *Server) dispatch(sess *session, args []string) bool {
+	cmd := strings.ToUpper(args[0])
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.commands++
+
+	switch cmd {
+	case "HELLO":
+		s.hello(sess, args[1:])
+		return false
+	case "AUTH":
+		s.auth(sess, args[1:])
+		return false
+	case "QUIT":
+		sess.writeSimple("OK")
+		return true
+	}
+
+	if !sess.authenticated {
+		sess.writeError("NOAUTH Authentication required.")
+		return false
+	}
+
+	switch cmd {
+	case "PING":
+		sess.writeSimple("PONG")
+	case "SELECT":
+		sess.writeSimple("OK")
+	case "SET":
+		s.set(sess, args[1:])
+	case "GET":
+		s.get(sess, args[1:])
+	case "MGET":
+		s.mget(sess, args[1:])
+	case "DEL", "UNLINK":
+		s.del(sess, args[1:])
+	case "EXISTS":
+		s.exists(sess, args[1:])
+	case "PTTL":
+		s.pttl(sess, args[1:])
+	case "DBSIZE":
+		s.dbsize(sess)
+	case "EVAL", "EVALSHA":
+		if s.script == nil {
+			sess.writeError(fmt.Sprintf("ERR unknown command '%s', with args beginning with: ", args[0]))
+			break
+		}
+
+		s.eval(sess, args[1:], cmd == "EVALSHA")
+	case "FLUSHALL", "FLUSHDB":
+		s.data = make(map[string]item)
+		sess.writeSimple("OK")
+	default:
+		sess.writeError(fmt.Sprintf("ERR unknown command '%s', with args beginning with: ", args[0]))
+	}
+
+	return false
+}
+
+func (s *Server) hello(sess *session, args []string) {
+	protocol := sess.protocol
+
+	if len(args) > 0 {
+		version, err := strconv.Atoi(args[0])
+		if err != nil || version < 2 || version > 3 {
+			sess.writeError("NOPROTO unsupported protocol version")
+			return
+		}
+
+		protocol = version
+	}
+
+	if len(args) >= 4 && strings.EqualFold(args[1], "AUTH") { //nolint:mnd // HELLO ver AUTH user pass
+		if !s.checkPassword(args[3]) {
+			sess.writeError("WRONGPASS invalid username-password pair or user is disabled.")
+			return
+		}
+
+		sess.authenticated = true
+	}
+
+	if !sess.authenticated {
+		sess.writeError("NOAUTH HELLO must be called with the client already authenticated")
+		return
+	}
+
+	sess.protocol = protocol
+	sess.writeMap([]string{"server", "redis", "version", "7.2.0", "proto", strconv.Itoa(protocol), "mode", "standalone"})
+}
+
+func (s *Server) auth(sess *session, args []string) {
+	if len(args) == 0 || len(args) > 2 { //nolint:mnd // AUTH [user] pass
+		sess.writeError("ERR wrong number of arguments for 'auth' command")
+		return
+	}
+
+	if s.password == "" {
+		sess.writeError("ERR AUTH <password> called without any password configured for the default user.")
+		return
+	}
+
+	if !s.checkPassword(args[len(args)-1]) {
+		sess.writeError("WRONGPASS invalid username-password pair or user is disabled.")
+		return
+	}
+
+	sess.authenticated = true
+	sess.writeSimple("OK")
+}
+
+func (s *Server) checkPassword(password string) bool {
+	return s.password == "" || password == s.password
+}
+
+func (s *Server) now() time.Time {
+	return time.Now().Add(s.offset)
+}
+
+// lookup returns the live item stored under key, dropping it if expired.
+func (s *Server) lookup(key string) (item, bool) {
+	it, ok := s.data[key]
+	if !ok {
+		return item{}, false
+	}
+
+	if !it.expiresAt.IsZero() && !s.now().Before(it.expiresAt) {
+		delete(s.data, key)
+		return item{}, false
+	}
+
+	return it, true
+}
+
+func (s *Server) set(sess *session, args []string) {
+	if len(args) < 2 { //nolint:mnd // SET key value
+		sess.writeError("ERR wrong number of arguments for 'set' command")
+		return
+	}
+
+	key, it := args[0], item{value: []byte(args[1]), expiresAt: time.Time{}}
+	onlyNew, onlyExisting := false, false
+
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			onlyNew = true
+		case "XX":
+			onlyExisting = true
+		case "PX", "EX":
+			if i+1 >= len(args) {
+				sess.writeError("ERR syntax error")
+				return
+			}
+
+			amount, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || amount <= 0 {
+				sess.writeError("ERR invalid expire time in 'set' command")
+				return
+			}
+
+			unit := time.Millisecond
+			if strings.EqualFold(args[i], "EX") {
+				unit = time.Second
+			}
+
+			it.expiresAt = s.now().Add(time.Duration(amount) * unit)
+			i++
+		default:
+			sess.writeError("ERR syntax error")
+			return
+		}
+	}
+
+	_, exists := s.lookup(key)
+	if (onlyNew && exists) || (onlyExisting && !exists) {
+		sess.writeNull()
+		return
+	}
+
+	s.data[key] = it
+	sess.writeSimple("OK")
+}
+
+func (s *Server) get(sess *session, args []string) {
+	if len(args) != 1 {
+		sess.writeError("ERR wrong number of arguments for 'get' command")
+		return
+	}
+
+	it, ok := s.lookup(args[0])
+	if !ok {
+		sess.writeNull()
+		return
+	}
+
+	sess.writeBulk(it.value)
+}
+
+func (s *Server) mget(sess *session, args []string) {
+	if len(args) == 0 {
+		sess.writeError("ERR wrong number of arguments for 'mget' command")
+		return
+	}
+
+	sess.writeArrayHeader(len(args))
+
+	for _, key := range args {
+		if it, ok := s.lookup(key); ok {
+			sess.writeBulk(it.value)
+		} else {
+			sess.writeNull()
+		}
+	}
+}
+
+func (s *Server) del(sess *session, args []string) {
+	deleted := 0
+
+	for _, key := range args {
+		if _, ok := s.lookup(key); ok {
+			delete(s.data, key)
+
+			deleted++
+		}
+	}
+
+	sess.writeInt(deleted)
+}
+
+func (s *Server) exists(sess *session, args []string) {
+	found := 0
+
+	for _, key := range args {
+		if _, ok := s.lookup(key); ok {
+			found++
+		}
+	}
+
+	sess.writeInt(found)
+}
+
+func (s *Server) pttl(sess *session, args []string) {
+	if len(args) != 1 {
+		sess.writeError("ERR wrong number of arguments for 'pttl' command")
+		return
+	}
+
+	it, ok := s.lookup(args[0])
+
+	switch {
+	case !ok:
+		sess.writeInt(-2) //nolint:mnd // missing key
+	case it.expiresAt.IsZero():
+		sess.writeInt(-1)
+	default:
+		sess.writeInt(int(it.expiresAt.Sub(s.now()).Milliseconds()))
+	}
+}
+
+func (s *Server) dbsize(sess *session) {
+	live := 0
+
+	for key := range s.data {
+		if _, ok := s.lookup(key); ok {
+			live++
+		}
+	}
+
+	sess.writeInt(live)
+}
+
+func (sess *session) writeSimple(msg string) {
+	_, _ = sess.writer.WriteString("+" + msg + "\r\n")
+}
+
+func (sess *session) writeError(msg string) {
+	_, _ = sess.writer.WriteString("-" + msg + "\r\n")
+}
+
+func (sess *session) writeInt(n int) {
+	_, _ = sess.writer.WriteString(":" + strconv.Itoa(n) + "\r\n")
+}
+
+func (sess *session) writeBulk(data []byte) {
+	_, _ = sess.writer.WriteString("$" + strconv.Itoa(len(data)) + "\r\n")
+	_, _ = sess.writer.Write(data)
+	_, _ = sess.writer.WriteString("\r\n")
+}
+
+func (sess *session) writeNull() {
+	if sess.protocol == 3 { //nolint:mnd // RESP3
+		_, _ = sess.writer.WriteString("_\r\n")
+		return
+	}
+
+	_, _ = sess.writer.WriteString("$-1\r\n")
+}
+
+func (sess *session) writeArrayHeader(n int) {
+	_, _ = sess.writer.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+// writeMap writes alternating keys and values as a RESP3 map, or as a flat
+// array under RESP2.
+func (sess *session) writeMap(pairs []string) {
+	if sess.protocol == 3 { //nolint:mnd // RESP3
+		_, _ = sess.writer.WriteString("%" + strconv.Itoa(len(pairs)/2) + "\r\n") //nolint:mnd // pairs
+	} else {
+		sess.writeArrayHeader(len(pairs))
+	}
+
+	for _, p := range pairs {
+		sess.writeBulk([]byte(p))
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+var (
+	// ErrProtocol indicates a malformed or unexpected server reply.
+	ErrProtocol = errors.New("redis protocol error")
+	// ErrPoolClosed indicates the client was closed.
+	ErrPoolClosed = errors.New("redis client closed")
+)
+
+// Error is an error reply sent by the server, such as "ERR unknown command".
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Prefix returns the error kind, the first word of the reply (e.g. "ERR",
+// "NOSCRIPT", "WRONGTYPE").
+func (e Error) Prefix() string {
+	for i := range len(e) {
+		if e[i] == ' ' {
+			return string(e[:i])
+		}
+	}
+
+	return string(e)
+}
+
+// writeCommand writes args as a RESP array of bulk strings.
+func writeCommand(w *bufio.Writer, args ...[]byte) error {
+	buf := w.AvailableBuffer()
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+
+	for _, arg := range args {
+		buf = w.AvailableBuffer()
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+
+		if _, err := w.Write(arg); err != nil {
+			return err
+		}
+
+		if _, err := w.WriteString("\r\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readReply reads one RESP2 or RESP3 reply.
+//
+// Replies are mapped to Go values as follows:
+//   - simple string, big number: string
+//   - bulk string, verbatim string: []byte
+//   - integer: int64
+//   - double: float64
+//   - boolean: bool
+//   - null, null bulk string, null array: nil
+//   - array, set, push: []any
+//   - map: map[string]any (keys are formatted with fmt.Sprint)
+//   - simple error, bulk error: Error, returned as the value
+//
+// Attribute replies are read and discarded. An error is returned only when the
+// stream cannot be parsed or read.
+func readReply(r *bufio.Reader) (any, error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case '+':
+		return string(line), nil
+	case '-':
+		return Error(line), nil
+	case ':':
+		return parseInt(line)
+	case '$', '=', '!':
+		return readBulk(r, kind, line)
+	case '*', '~', '>':
+		return readArray(r, line)
+	case '%':
+		return readMap(r, line)
+	case '|':
+		if _, err := readMap(r, line); err != nil {
+			return nil, err
+		}
+
+		return readReply(r)
+	case '_':
+		return nil, nil //nolint:nilnil // RESP3 null
+	case '#':
+		return parseBool(line)
+	case ',':
+		return parseDouble(line)
+	case '(':
+		return string(line), nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected reply type %q", ErrProtocol, kind)
+	}
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return nil, fmt.Errorf("%w: line too long", ErrProtocol)
+		}
+
+		return nil, err
+	}
+
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("%w: line not terminated by CRLF", ErrProtocol)
+	}
+
+	return line[:len(line)-2], nil
+}
+
+func readBulk(r *bufio.Reader, kind byte, line []byte) (any, error) {
+	size, err := parseInt(line)
+	if err != nil {
+		return nil, err
+	}
+
+	if size < 0 {
+		return nil, nil //nolint:nilnil // RESP2 null bulk string
+	}
+
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	if data[size] != '\r' || data[size+1] != '\n' {
+		return nil, fmt.Errorf("%w: bulk string not terminated by CRLF", ErrProtocol)
+	}
+
+	data = data[:size]
+
+	switch kind {
+	case '!':
+		return Error(data), nil
+	case '=':
+		// Verbatim strings start with a three letter format and a colon.
+		if len(data) < 4 || data[3] != ':' {
+			return nil, fmt.Errorf("%w: malformed verbatim string", ErrProtocol)
+		}
+
+		return data[4:], nil
+	default:
+		return data, nil
+	}
+}
+
+func readArray(r *bufio.Reader, line []byte) (any, error) {
+	size, err := parseInt(line)
+	if err != nil {
+		return nil, err
+	}
+
+	if size < 0 {
+		return nil, nil //nolint:nilnil // RESP2 null array
+	}
+
+	items := make([]any, size)
+	for i := range items {
+		if items[i], err = readReply(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+func readMap(r *bufio.Reader, line []byte) (any, error) {
+	size, err := parseInt(line)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(map[string]any, size)
+
+	for range size {
+		key, err := readReply(r)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := readReply(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if b, ok := key.([]byte); ok {
+			key = string(b)
+		}
+
+		items[fmt.Sprint(key)] = value
+	}
+
+	return items, nil
+}
+
+func parseInt(line []byte) (int64, error) {
+	n, err := strconv.ParseInt(string(line), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid integer %q", ErrProtocol, line)
+	}
+
+	return n, nil
+}
+
+func parseBool(line []byte) (bool, error) {
+	switch string(line) {
+	case "t":
+		return true, nil
+	case "f":
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w: invalid boolean %q", ErrProtocol, line)
+	}
+}
+
+func parseDouble(line []byte) (float64, error) {
+	f, err := strconv.ParseFloat(string(line), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid double %q", ErrProtocol, line)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//nolint:testpackage // white-box tests require access to the RESP codec
+package redis
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCommand(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	w := bufio.NewWriter(&buf)
+	require.NoError(t, writeCommand(w, []byte("SET"), []byte("key"), []byte("a\r\nb")))
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$4\r\na\r\nb\r\n", buf.String())
+}
+
+func TestReadReply(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  any
+	}{
+		{name: "simple string", input: "+OK\r\n", want: "OK"},
+		{name: "error", input: "-ERR boom\r\n", want: Error("ERR boom")},
+		{name: "integer", input: ":-42\r\n", want: int64(-42)},
+		{name: "bulk string", input: "$5\r\nhe\r\no\r\n", want: []byte("he\r\no")},
+		{name: "empty bulk string", input: "$0\r\n\r\n", want: []byte{}},
+		{name: "null bulk string", input: "$-1\r\n", want: nil},
+		{name: "null array", input: "*-1\r\n", want: nil},
+		{name: "array", input: "*2\r\n:1\r\n$1\r\na\r\n", want: []any{int64(1), []byte("a")}},
+		{name: "resp3 null", input: "_\r\n", want: nil},
+		{name: "resp3 boolean", input: "#t\r\n", want: true},
+		{name: "resp3 double", input: ",1.5\r\n", want: 1.5},
+		{
+			name:  "resp3 big number",
+			input: "(3492890328409238509324850943850943825024385\r\n",
+			want:  "3492890328409238509324850943850943825024385",
+		},
+		{name: "resp3 bulk error", input: "!9\r\nERR oops!\r\n", want: Error("ERR oops!")},
+		{name: "resp3 verbatim", input: "=8\r\ntxt:text\r\n", want: []byte("text")},
+		{name: "resp3 set", input: "~1\r\n+a\r\n", want: []any{"a"}},
+		{name: "resp3 push", input: ">2\r\n+message\r\n:1\r\n", want: []any{"message", int64(1)}},
+		{
+			name:  "resp3 map",
+			input: "%2\r\n+proto\r\n:3\r\n$4\r\nmode\r\n+standalone\r\n",
+			want:  map[string]any{"proto": int64(3), "mode": "standalone"},
+		},
+		{name: "resp3 attribute is skipped", input: "|1\r\n+ttl\r\n:3\r\n:7\r\n", want: int64(7)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := readReply(bufio.NewReader(strings.NewReader(tt.input)))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReadReplyMalformed(t *testing.T) {
+	t.Parallel()
+
+	inputs := []string{
+		"?\r\n",
+		":abc\r\n",
+		"+OK\n",
+		"$3\r\nabcde",
+		"#x\r\n",
+		",nope\r\n",
+		"=3\r\nabc\r\n",
+	}
+
+	for _, input := range inputs {
+		_, err := readReply(bufio.NewReader(strings.NewReader(input)))
+		require.Error(t, err, "%q", input)
+	}
+
+	_, err := readReply(bufio.NewReader(strings.NewReader("*2\r\n:1\r\n")))
+	require.Error(t, err)
+}
+
+func TestErrorPrefix(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "NOSCRIPT", Error("NOSCRIPT No matching script").Prefix())
+	assert.Equal(t, "ERR", Error("ERR").Prefix())
+}
@@ -0,0 +1,146 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultRefreshQueueSize bounds the number of pending background refreshes
+// per cache. Refreshes that do not fit are skipped and retried by a later Get.
+const DefaultRefreshQueueSize = 1024
+
+// RefreshFunc reloads the value of key for a background refresh.
+type RefreshFunc func(ctx context.Context, key string) (any, error)
+
+// refreshJob is a pending background refresh of one entry.
+type refreshJob struct {
+	key   string
+	entry entry
+}
+
+// SetWithRefresh stores key/value with a soft and a hard deadline.
+//
+// Until refreshAfter elapses the entry behaves like one written by Set. After
+// that, Get keeps returning the current value immediately but also starts a
+// single background refresh through the RefreshFunc registered with
+// WithRefresh. A successful refresh stores the new value with the same
+// refreshAfter and the current ttl, as changed by Expire; a failed one leaves
+// the stale value in place until the hard expiry at now+ttl (ttl <= 0 means
+// no hard expiry). If refreshAfter <= 0 or no RefreshFunc is registered,
+// SetWithRefresh behaves like Set.
+func (mc *MemCache) SetWithRefresh(_ context.Context, key string, value any, refreshAfter, ttl time.Duration) error {
+	return mc.set(key, newRefreshingEntry(value, refreshAfter, ttl, mc.now()))
+}
+
+// startRefresh queues a background refresh of key unless one is already
+// pending or running.
+func (mc *MemCache) startRefresh(key string, val entry) {
+	mc.loads.mx.Lock()
+	if _, running := mc.loads.refreshing[key]; running {
+		mc.loads.mx.Unlock()
+		return
+	}
+
+	mc.loads.refreshing[key] = struct{}{}
+	mc.loads.mx.Unlock()
+
+	select {
+	case mc.refreshCh <- refreshJob{key: key, entry: val}:
+	default:
+		mc.finishRefresh(key)
+		mc.log.Warn().Str("key", key).Msg("refresh queue full, skipping refresh")
+	}
+}
+
+func (mc *MemCache) finishRefresh(key string) {
+	mc.loads.mx.Lock()
+	delete(mc.loads.refreshing, key)
+	mc.loads.mx.Unlock()
+}
+
+// refresher runs background refreshes until Close is called.
+func (mc *MemCache) refresher(ctx context.Context) {
+	defer mc.refreshWG.Done()
+
+	for {
+		select {
+		case job := <-mc.refreshCh:
+			mc.refresh(ctx, job)
+		case <-mc.stopCh:
+			return
+		}
+	}
+}
+
+// refresh reloads one entry. The new value replaces the old one only if the
+// entry was not overwritten or removed while the refresh was running.
+func (mc *MemCache) refresh(ctx context.Context, job refreshJob) {
+	defer mc.finishRefresh(job.key)
+
+	mc.metrics.AddRefresh()
+
+	value, err := runLoader(ctx, func(ctx context.Context) (any, error) {
+		return mc.refreshFn(ctx, job.key)
+	})
+	if err != nil {
+		mc.metrics.AddRefreshError()
+		mc.log.Error().
+			Err(err).
+			Str("key", job.key).
+			Msg("background refresh failed, serving stale value")
+
+		return
+	}
+
+	val := newRefreshingEntry(value, job.entry.refreshAfter, job.entry.ttl, mc.now())
+	if err := mc.sizeEntry(job.key, &val); err != nil {
+		mc.metrics.AddRefreshError()
+		mc.log.Error().
+			Err(err).
+			Str("key", job.key).
+			Msg("background refresh failed, serving stale value")
+
+		return
+	}
+
+	var evicted []string
+
+	mc.mx.Lock()
+
+	current, ok := mc.items[job.key]
+	if ok && current.sameStore(job.entry) {
+		val.tags = current.tags
+		val.expireIn(current.ttl, mc.now())
+		mc.items[job.key] = val
+		mc.expiry.schedule(job.key, val.expiresAt)
+		mc.addBytesLocked(val.size - current.size)
+		mc.emitLocked(job.key, current.value, ReasonReplaced)
+		mc.logSet(job.key, val)
+
+		evicted = mc.fitLocked(job.key)
+		mc.logEvicted(evicted)
+	}
+	mc.mx.Unlock()
+
+	mc.metrics.AddCapacityEviction(uint64(len(evicted))) //nolint:gosec // bounded by capacity
+
+	if mc.wlog != nil {
+		if err := mc.wlog.commit(); err != nil {
+			mc.log.Error().Err(err).Str("key", job.key).Msg("write log append failed")
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/cachetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemCacheRefreshAhead(t *testing.T) {
+	t.Parallel()
+
+	log := Logger(t)
+
+	t.Run("stale value is served while refresh runs", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			version atomic.Int32
+			release = make(chan struct{})
+		)
+
+		refreshFn := func(_ context.Context, key string) (any, error) {
+			<-release
+			return fmt.Sprintf("%s-v%d", key, version.Add(1)), nil
+		}
+
+		clock := cachetest.NewClock(time.Now())
+		mcache := cache.WithDeleteInterval(time.Hour, log, cache.WithRefresh(refreshFn, 2), cache.WithClock(clock))
+
+		t.Cleanup(func() {
+			require.NoError(t, mcache.Close(t.Context()))
+		})
+
+		ctx := t.Context()
+
+		require.NoError(t, mcache.SetWithRefresh(ctx, "key", "key-v0", 10*time.Millisecond, time.Minute))
+
+		v, err := mcache.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, "key-v0", v)
+
+		clock.Advance(20 * time.Millisecond)
+
+		// Past the soft deadline: several reads return the stale value
+		// immediately and start a single refresh.
+		for range 10 {
+			v, err = mcache.Get(ctx, "key")
+			require.NoError(t, err)
+			assert.Equal(t, "key-v0", v)
+		}
+
+		close(release)
+
+		require.Eventually(
+			t,
+			func() bool {
+				v, err := mcache.Get(ctx, "key")
+				return err == nil && v == "key-v1"
+			},
+			time.Second,
+			time.Millisecond,
+		)
+
+		assert.Equal(t, int32(1), version.Load())
+		assert.Equal(t, uint64(1), mcache.Metrics().Refreshes)
+	})
+
+	t.Run("failed refresh keeps stale value until hard expiry", func(t *testing.T) {
+		t.Parallel()
+
+		refreshFn := func(context.Context, string) (any, error) {
+			return nil, errLoad
+		}
+
+		clock := cachetest.NewClock(time.Now())
+		mcache := cache.WithDeleteInterval(time.Hour, log, cache.WithRefresh(refreshFn, 1), cache.WithClock(clock))
+
+		t.Cleanup(func() {
+			require.NoError(t, mcache.Close(t.Context()))
+		})
+
+		ctx := t.Context()
+
+		require.NoError(t, mcache.SetWithRefresh(ctx, "key", "stale", time.Millisecond, 100*time.Millisecond))
+
+		clock.Advance(5 * time.Millisecond)
+
+		v, err := mcache.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, "stale", v)
+
+		require.Eventually(
+			t,
+			func() bool { return mcache.Metrics().RefreshErrors >= 1 },
+			time.Second,
+			time.Millisecond,
+		)
+
+		v, err = mcache.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, "stale", v)
+
+		clock.Advance(100 * time.Millisecond)
+
+		_, err = mcache.Get(ctx, "key")
+		require.ErrorIs(t, err, cache.ErrNotFound)
+	})
+
+	t.Run("refresh does not clobber newer writes", func(t *testing.T) {
+		t.Parallel()
+
+		release := make(chan struct{})
+		refreshFn := func(context.Context, string) (any, error) {
+			<-release
+			return "refreshed", nil
+		}
+
+		clock := cachetest.NewClock(time.Now())
+		mcache := cache.WithDeleteInterval(time.Hour, log, cache.WithRefresh(refreshFn, 1), cache.WithClock(clock))
+
+		t.Cleanup(func() {
+			require.NoError(t, mcache.Close(t.Context()))
+		})
+
+		ctx := t.Context()
+
+		require.NoError(t, mcache.SetWithRefresh(ctx, "key", "old", time.Millisecond, 0))
+		clock.Advance(5 * time.Millisecond)
+
+		_, err := mcache.Get(ctx, "key")
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return mcache.Metrics().Refreshes == 1 }, time.Second, time.Millisecond)
+
+		require.NoError(t, mcache.Set(ctx, "key", "new", 0))
+		close(release)
+
+		time.Sleep(20 * time.Millisecond)
+
+		v, err := mcache.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, "new", v)
+	})
+
+	t.Run("close cancels in-flight refreshes", func(t *testing.T) {
+		t.Parallel()
+
+		started := make(chan struct{})
+		refreshFn := func(ctx context.Context, _ string) (any, error) {
+			close(started)
+			<-ctx.Done()
+
+			return nil, ctx.Err()
+		}
+
+		clock := cachetest.NewClock(time.Now())
+		mcache := cache.WithDeleteInterval(time.Hour, log, cache.WithRefresh(refreshFn, 1), cache.WithClock(clock))
+
+		require.NoError(t, mcache.SetWithRefresh(t.Context(), "key", "v", time.Millisecond, 0))
+		clock.Advance(5 * time.Millisecond)
+
+		_, err := mcache.Get(t.Context(), "key")
+		require.NoError(t, err)
+
+		<-started
+		require.NoError(t, mcache.Close(t.Context()))
+		assert.Equal(t, uint64(1), mcache.Metrics().RefreshErrors)
+	})
+
+	t.Run("without refresh func soft deadline is ignored", func(t *testing.T) {
+		t.Parallel()
+
+		clock := cachetest.NewClock(time.Now())
+		mcache := cache.New(log, cache.WithClock(clock))
+
+		t.Cleanup(func() {
+			require.NoError(t, mcache.Close(t.Context()))
+		})
+
+		require.NoError(t, mcache.SetWithRefresh(t.Context(), "key", "v", time.Millisecond, 0))
+		clock.Advance(5 * time.Millisecond)
+
+		v, err := mcache.Get(t.Context(), "key")
+		require.NoError(t, err)
+		assert.Equal(t, "v", v)
+		assert.Equal(t, uint64(0), mcache.Metrics().Refreshes)
+	})
+}
@@ -0,0 +1,443 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"maps"
+	"math/bits"
+	"runtime"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Sharded is a thread-safe in-memory cache that spreads keys over a fixed
+// number of independently locked MemCache shards.
+//
+// A key always maps to the same shard, so single-key operations only contend
+// with operations on keys of the same shard. Every shard runs its own
+// background cleaner with its own share of the cleanup budget.
+//
+// Options passed to NewSharded apply to every shard. Bounds that describe the
+// whole cache, such as WithMaxEntries, WithMaxBytes and WithCleanupBudget,
+// are split evenly across shards, so a shard rejects entries larger than its
+// share of the byte budget.
+//
+// Close must be called to stop the background cleaners.
+type Sharded struct {
+	shards []*MemCache
+	shift  uint // 64 minus the number of shard index bits
+}
+
+// NewSharded returns a Sharded cache with the given number of shards, rounded
+// up to a power of two. If shards is <= 0, runtime.GOMAXPROCS(0) is used.
+//
+// cleanupInterval has the same meaning as in WithDeleteInterval. The returned
+// cache starts one background goroutine per shard. Call Close to stop them.
+func NewSharded(shards int, cleanupInterval time.Duration, log zerolog.Logger, opts ...Option) *Sharded {
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+
+	if cleanupInterval <= 0 {
+		cleanupInterval = DefaultCleanupInterval
+	}
+
+	shardBits := bits.Len(uint(shards - 1))
+	count := 1 << shardBits
+	cfg := newOptions(opts)
+
+	if cfg.maxEntries > 0 {
+		cfg.maxEntries = ceilDiv(cfg.maxEntries, count)
+	}
+
+	if cfg.maxBytes > 0 {
+		cfg.maxBytes = (cfg.maxBytes + int64(count) - 1) / int64(count)
+	}
+
+	cfg.cleanupBudget = ceilDiv(cfg.cleanupBudget, count)
+
+	sharded := &Sharded{
+		shards: make([]*MemCache, count),
+		shift:  uint(64 - shardBits), //nolint:gosec,mnd // at most 64 bits
+	}
+
+	for i := range sharded.shards {
+		sharded.shards[i] = newMemCache(cleanupInterval, log.With().Int("shard", i).Logger(), cfg)
+	}
+
+	return sharded
+}
+
+// shard returns the shard owning key. It is chosen by the high bits of the
+// key hash: the count-min sketch of a TinyLFU shard indexes its counters with
+// the low bits, which would otherwise be the same for all keys of a shard.
+func (sc *Sharded) shard(key string) *MemCache {
+	return sc.shards[keyHash(key)>>sc.shift]
+}
+
+// Set stores key/value with the provided TTL in the shard owning key.
+// See MemCache.Set for TTL semantics.
+func (sc *Sharded) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	return sc.shard(key).Set(ctx, key, value, ttl)
+}
+
+// SetWithRefresh stores key/value with a soft and a hard deadline in the shard
+// owning key. See MemCache.SetWithRefresh.
+func (sc *Sharded) SetWithRefresh(ctx context.Context, key string, value any, refreshAfter, ttl time.Duration) error {
+	return sc.shard(key).SetWithRefresh(ctx, key, value, refreshAfter, ttl)
+}
+
+// SetWithTags stores key/value with tags in the shard owning key. See
+// MemCache.SetWithTags.
+func (sc *Sharded) SetWithTags(ctx context.Context, key string, value any, ttl time.Duration, tags ...string) error {
+	return sc.shard(key).SetWithTags(ctx, key, value, ttl, tags...)
+}
+
+// SetSliding stores key/value with a sliding expiration in the shard owning
+// key. See MemCache.SetSliding.
+func (sc *Sharded) SetSliding(ctx context.Context, key string, value any, ttl time.Duration) error {
+	return sc.shard(key).SetSliding(ctx, key, value, ttl)
+}
+
+// TTL returns the time left until key expires in the shard owning key.
+// See MemCache.TTL.
+func (sc *Sharded) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return sc.shard(key).TTL(ctx, key)
+}
+
+// Expire makes key expire ttl from now in the shard owning key.
+// See MemCache.Expire.
+func (sc *Sharded) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	return sc.shard(key).Expire(ctx, key, ttl)
+}
+
+// Persist removes the expiry of key in the shard owning key.
+// See MemCache.Persist.
+func (sc *Sharded) Persist(ctx context.Context, key string) error {
+	return sc.shard(key).Persist(ctx, key)
+}
+
+// Touch marks key as used in the shard owning key. See MemCache.Touch.
+func (sc *Sharded) Touch(ctx context.Context, key string) error {
+	return sc.shard(key).Touch(ctx, key)
+}
+
+// SetIfAbsent stores key/value in the shard owning key if key is missing.
+// See MemCache.SetIfAbsent.
+func (sc *Sharded) SetIfAbsent(ctx context.Context, key string, value any, ttl time.Duration) (bool, error) {
+	return sc.shard(key).SetIfAbsent(ctx, key, value, ttl)
+}
+
+// CompareAndSwap stores newValue in the shard owning key if the current value
+// has the digest expected. See MemCache.CompareAndSwap.
+func (sc *Sharded) CompareAndSwap(
+	ctx context.Context,
+	key string,
+	expected Digest,
+	newValue any,
+	ttl time.Duration,
+) (bool, error) {
+	return sc.shard(key).CompareAndSwap(ctx, key, expected, newValue, ttl)
+}
+
+// Incr adds delta to the integer value of key in the shard owning key.
+// See MemCache.Incr.
+func (sc *Sharded) Incr(ctx context.Context, key string, delta int64) (int64, error) {
+	return sc.shard(key).Incr(ctx, key, delta)
+}
+
+// Decr subtracts delta from the integer value of key in the shard owning key.
+// See MemCache.Decr.
+func (sc *Sharded) Decr(ctx context.Context, key string, delta int64) (int64, error) {
+	return sc.shard(key).Decr(ctx, key, delta)
+}
+
+// Get returns the cached value for key from the shard owning key.
+// See MemCache.Get for eviction semantics.
+func (sc *Sharded) Get(ctx context.Context, key string) (any, error) {
+	return sc.shard(key).Get(ctx, key)
+}
+
+// GetOrLoad returns the cached value for key, loading it through the shard
+// owning key on a miss. See MemCache.GetOrLoad.
+func (sc *Sharded) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (any, error) {
+	return sc.shard(key).GetOrLoad(ctx, key, ttl, loader)
+}
+
+// GetMany returns the values of the keys that were found and, in the order
+// of keys, the keys that are missing or expired. Keys are grouped by shard and
+// each shard is read with MemCache.GetMany.
+//
+// If the context is cancelled, GetMany returns ErrAborted.
+func (sc *Sharded) GetMany(ctx context.Context, keys ...string) (map[string]any, []string, error) {
+	groups := make(map[*MemCache][]string)
+	for _, key := range keys {
+		shard := sc.shard(key)
+		groups[shard] = append(groups[shard], key)
+	}
+
+	found := make(map[string]any, len(keys))
+
+	for shard, shardKeys := range groups {
+		shardFound, _, err := shard.GetMany(ctx, shardKeys...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		maps.Copy(found, shardFound)
+	}
+
+	var missing []string
+
+	for _, key := range keys {
+		if _, ok := found[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	return found, missing, nil
+}
+
+// SetMany stores items, each with its own TTL. Items are grouped by shard and
+// each shard is written with MemCache.SetMany, so items are validated and
+// stored shard by shard.
+//
+// If the context is cancelled, SetMany returns ErrAborted; items of shards
+// processed before the cancellation stay stored.
+func (sc *Sharded) SetMany(ctx context.Context, items ...Item) error {
+	groups := make(map[*MemCache][]Item)
+	for _, item := range items {
+		shard := sc.shard(item.Key)
+		groups[shard] = append(groups[shard], item)
+	}
+
+	for shard, shardItems := range groups {
+		if err := shard.SetMany(ctx, shardItems...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a set of keys from cache.
+//
+// Keys are grouped by shard and each shard is locked once. If the context is
+// cancelled, Delete returns ErrAborted; keys of shards processed before the
+// cancellation stay deleted.
+func (sc *Sharded) Delete(ctx context.Context, keys ...string) error {
+	_, err := sc.DeleteMany(ctx, keys...)
+	return err
+}
+
+// DeleteMany removes keys as Delete does and returns the number of keys that
+// held a live entry. If cancelled, it returns ErrAborted along with the number
+// of keys removed so far.
+func (sc *Sharded) DeleteMany(ctx context.Context, keys ...string) (int, error) {
+	if len(keys) == 1 {
+		return sc.shard(keys[0]).DeleteMany(ctx, keys[0])
+	}
+
+	groups := make(map[*MemCache][]string)
+	for _, key := range keys {
+		shard := sc.shard(key)
+		groups[shard] = append(groups[shard], key)
+	}
+
+	total := 0
+
+	for shard, shardKeys := range groups {
+		deleted, err := shard.DeleteMany(ctx, shardKeys...)
+		total += deleted
+
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// InvalidateTags removes every entry carrying at least one of tags from all
+// shards. See MemCache.InvalidateTags.
+//
+// Each shard is invalidated atomically, but not all shards at once: a reader
+// may briefly observe a group that is invalidated in some shards only. If the
+// context is cancelled, InvalidateTags returns ErrAborted; shards processed
+// before the cancellation stay invalidated.
+func (sc *Sharded) InvalidateTags(ctx context.Context, tags ...string) error {
+	for _, shard := range sc.shards {
+		if err := shard.InvalidateTags(ctx, tags...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// All returns an iterator over the keys and values of live entries of all
+// shards, one shard after the other. See MemCache.All.
+func (sc *Sharded) All() iter.Seq2[string, any] {
+	return func(yield func(string, any) bool) {
+		for _, shard := range sc.shards {
+			for key, value := range shard.All() {
+				if !yield(key, value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Keys returns an iterator over the keys of live entries of all shards.
+// See MemCache.Keys.
+func (sc *Sharded) Keys() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for _, shard := range sc.shards {
+			for key := range shard.Keys() {
+				if !yield(key) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// ScanPrefix returns an iterator over the keys and values of live entries of
+// all shards whose key starts with prefix. See MemCache.ScanPrefix.
+func (sc *Sharded) ScanPrefix(prefix string) iter.Seq2[string, any] {
+	return func(yield func(string, any) bool) {
+		for _, shard := range sc.shards {
+			for key, value := range shard.ScanPrefix(prefix) {
+				if !yield(key, value) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// DeletePrefix removes every entry whose key starts with prefix from all
+// shards. See MemCache.DeletePrefix.
+func (sc *Sharded) DeletePrefix(ctx context.Context, prefix string) error {
+	for _, shard := range sc.shards {
+		if err := shard.DeletePrefix(ctx, prefix); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteMatch removes every entry whose key matches the glob pattern from all
+// shards. See MemCache.DeleteMatch.
+func (sc *Sharded) DeleteMatch(ctx context.Context, pattern string) error {
+	if err := validGlob(pattern); err != nil {
+		return err
+	}
+
+	for _, shard := range sc.shards {
+		if err := shard.DeleteMatch(ctx, pattern); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Subscribe registers fn for entries leaving any shard and returns a function
+// that cancels the subscription. fn is called concurrently from one goroutine
+// per shard. See MemCache.Subscribe.
+func (sc *Sharded) Subscribe(fn func(Event)) func() {
+	cancels := make([]func(), len(sc.shards))
+	for i, shard := range sc.shards {
+		cancels[i] = shard.Subscribe(fn)
+	}
+
+	return func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+}
+
+// Digest returns a fingerprint for the current value of key.
+// See MemCache.Digest.
+func (sc *Sharded) Digest(ctx context.Context, key string) Digest {
+	return sc.shard(key).Digest(ctx, key)
+}
+
+// Close stops the background cleaners of all shards. It is safe to call
+// Close multiple times.
+func (sc *Sharded) Close(ctx context.Context) error {
+	errs := make([]error, 0, len(sc.shards))
+	for _, shard := range sc.shards {
+		errs = append(errs, shard.Close(ctx))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Shards returns the number of shards.
+func (sc *Sharded) Shards() int {
+	return len(sc.shards)
+}
+
+// Metrics returns the sum of all shard metrics. LastCleanupDurationMs is the
+// slowest of the shards' last cleanup runs.
+func (sc *Sharded) Metrics() Metrics {
+	var total Metrics
+	for _, shard := range sc.shards {
+		total.merge(shard.Metrics())
+	}
+
+	return total
+}
+
+// ResetMetrics zeroes the metrics of all shards and returns the sum of their
+// values before the reset. See Metrics.Reset.
+func (sc *Sharded) ResetMetrics() Metrics {
+	var total Metrics
+	for _, shard := range sc.shards {
+		total.merge(shard.ResetMetrics())
+	}
+
+	return total
+}
+
+// MetricsJSON returns a JSON snapshot of the aggregated metrics as a string.
+func (sc *Sharded) MetricsJSON() string {
+	total := sc.Metrics()
+	return total.JSONStr()
+}
+
+// Size returns the current number of entries across all shards, including
+// expired entries that were not evicted yet. See MemCache.Size.
+func (sc *Sharded) Size() int {
+	size := 0
+	for _, shard := range sc.shards {
+		size += shard.Size()
+	}
+
+	return size
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
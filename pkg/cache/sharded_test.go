@@ -0,0 +1,186 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ cache.AtomicCache = (*cache.Sharded)(nil)
+
+func TestShardedStress(t *testing.T) {
+	t.Parallel()
+
+	scache := cache.NewSharded(16, time.Hour, Logger(t))
+
+	t.Cleanup(func() {
+		require.NoError(t, scache.Close(t.Context()))
+	})
+
+	const keys = 100_000
+
+	var wg sync.WaitGroup
+
+	wg.Add(keys)
+
+	for key := range keys {
+		go func(k int) {
+			defer wg.Done()
+
+			assert.NoError(t, scache.Set(t.Context(), fmt.Sprintf("k-%d", k), k, 0))
+		}(key)
+	}
+
+	wg.Wait()
+	assert.Equal(t, keys, scache.Size())
+
+	wg.Add(keys)
+
+	for key := range keys {
+		go func(k int) {
+			defer wg.Done()
+
+			v, err := scache.Get(t.Context(), fmt.Sprintf("k-%d", k))
+			assert.NoError(t, err)
+			assert.Equal(t, k, v)
+		}(key)
+	}
+
+	wg.Wait()
+
+	allKeys := make([]string, 0, keys)
+	for key := range keys {
+		allKeys = append(allKeys, fmt.Sprintf("k-%d", key))
+	}
+
+	require.NoError(t, scache.Delete(t.Context(), allKeys...))
+
+	_, err := scache.Get(t.Context(), "k-0")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	mtrcs := scache.Metrics()
+
+	assert.Equal(t, 0, scache.Size())
+	assert.Equal(t, uint64(keys), mtrcs.Sets)
+	assert.Equal(t, uint64(keys), mtrcs.Hits)
+	assert.Equal(t, uint64(keys), mtrcs.Deletes)
+	assert.Equal(t, uint64(1), mtrcs.Misses)
+}
+
+func TestShardedOptions(t *testing.T) {
+	t.Parallel()
+
+	t.Run("shard count is rounded to power of two", func(t *testing.T) {
+		t.Parallel()
+
+		scache := cache.NewSharded(5, 0, Logger(t))
+
+		t.Cleanup(func() {
+			require.NoError(t, scache.Close(t.Context()))
+		})
+
+		assert.Equal(t, 8, scache.Shards())
+	})
+
+	t.Run("max entries is split across shards", func(t *testing.T) {
+		t.Parallel()
+
+		scache := cache.NewSharded(4, time.Hour, Logger(t), cache.WithMaxEntries(400))
+
+		t.Cleanup(func() {
+			require.NoError(t, scache.Close(t.Context()))
+		})
+
+		for i := range 10_000 {
+			require.NoError(t, scache.Set(t.Context(), fmt.Sprintf("k-%d", i), i, 0))
+		}
+
+		assert.Equal(t, 400, scache.Size())
+		assert.Equal(t, uint64(10_000-400), scache.Metrics().CapacityEvictions)
+	})
+
+	t.Run("background cleaners run per shard", func(t *testing.T) {
+		t.Parallel()
+
+		scache := cache.NewSharded(4, 5*time.Millisecond, Logger(t))
+
+		t.Cleanup(func() {
+			require.NoError(t, scache.Close(t.Context()))
+		})
+
+		const keys = 100
+
+		for key := range keys {
+			require.NoError(t, scache.Set(t.Context(), fmt.Sprintf("c-%d", key), key, 10*time.Millisecond))
+		}
+
+		require.Eventually(
+			t,
+			func() bool {
+				mt := scache.Metrics()
+				return mt.ScheduledEvictions >= uint64(keys) && mt.CleanupRuns >= 4 && scache.Size() == 0
+			},
+			time.Second,
+			10*time.Millisecond,
+		)
+	})
+}
+
+func TestShardedDeleteAborted(t *testing.T) {
+	t.Parallel()
+
+	scache := cache.NewSharded(4, time.Hour, Logger(t))
+
+	t.Cleanup(func() {
+		require.NoError(t, scache.Close(t.Context()))
+	})
+
+	require.NoError(t, scache.Set(t.Context(), "a", 1, 0))
+	require.NoError(t, scache.Set(t.Context(), "b", 2, 0))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	err := scache.Delete(ctx, "a", "b")
+	require.ErrorIs(t, err, cache.ErrAborted)
+	assert.Equal(t, 2, scache.Size())
+}
+
+func TestShardedDigest(t *testing.T) {
+	t.Parallel()
+
+	scache := cache.NewSharded(4, time.Hour, Logger(t))
+	mcache := cache.New(Logger(t))
+
+	t.Cleanup(func() {
+		require.NoError(t, scache.Close(t.Context()))
+		require.NoError(t, mcache.Close(t.Context()))
+	})
+
+	require.NoError(t, scache.Set(t.Context(), "k", "value", 0))
+	require.NoError(t, mcache.Set(t.Context(), "k", "value", 0))
+
+	assert.Equal(t, mcache.Digest(t.Context(), "k"), scache.Digest(t.Context(), "k"))
+	assert.Equal(t, cache.Digest(0), scache.Digest(t.Context(), "missing"))
+	assert.Contains(t, scache.MetricsJSON(), `"sets":1`)
+}
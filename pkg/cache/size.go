@@ -0,0 +1,148 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Sizer estimates the size in bytes of a value that is neither a []byte nor
+// a string. It is set with WithSizer.
+type Sizer func(value any) int64
+
+// SizeError reports a value rejected because it exceeds the size limits of a
+// MemCache. It wraps ErrTooLarge.
+type SizeError struct {
+	Key   string
+	Size  int64 // Estimated size of the rejected value or entry in bytes
+	Limit int64 // Limit it exceeds in bytes
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("value of key %q is %d bytes, limit is %d bytes", e.Key, e.Size, e.Limit)
+}
+
+func (e *SizeError) Unwrap() error {
+	return ErrTooLarge
+}
+
+// valueSize estimates the size of value in bytes: exactly for []byte and
+// string, with sizer for other types if it is set, and as the shallow size of
+// the value type otherwise.
+func valueSize(value any, sizer Sizer) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case []byte:
+		return int64(len(v))
+	case string:
+		return int64(len(v))
+	}
+
+	if sizer != nil {
+		return max(sizer(value), 0)
+	}
+
+	return int64(min(reflect.TypeOf(value).Size(), math.MaxInt64)) //nolint:gosec // clamped to the int64 range
+}
+
+// sizeEntry estimates the size of val stored under key and checks it
+// against the size limits. It is safe to call without holding any lock.
+func (mc *MemCache) sizeEntry(key string, val *entry) error {
+	size := valueSize(val.value, mc.sizer)
+
+	if mc.maxValueBytes > 0 && size > mc.maxValueBytes {
+		return &SizeError{Key: key, Size: size, Limit: mc.maxValueBytes}
+	}
+
+	val.size = int64(len(key)) + size
+
+	if mc.maxBytes > 0 && val.size > mc.maxBytes {
+		return &SizeError{Key: key, Size: val.size, Limit: mc.maxBytes}
+	}
+
+	return nil
+}
+
+// addBytesLocked adjusts the size of the stored entries by delta. The caller
+// must hold the write lock.
+func (mc *MemCache) addBytesLocked(delta int64) {
+	mc.bytes += delta
+	mc.metrics.SetBytes(uint64(max(mc.bytes, 0))) //nolint:gosec // not negative
+}
+
+// fitLocked evicts entries until the stored entries fit in the byte budget
+// and returns the evicted keys. keep, the key just stored, is only evicted if
+// the cache Policy rejects it. The caller must hold the write lock.
+func (mc *MemCache) fitLocked(keep string) []string {
+	if mc.maxBytes <= 0 || mc.bytes <= mc.maxBytes {
+		return nil
+	}
+
+	evictor, ok := mc.policy.(Evictor)
+	if !ok {
+		return mc.fitAnyLocked(keep)
+	}
+
+	var (
+		evicted []string
+		skipped bool
+	)
+
+	for mc.bytes > mc.maxBytes {
+		victim, ok := evictor.Evict()
+		if !ok {
+			break
+		}
+
+		if victim == keep {
+			skipped = true
+			continue
+		}
+
+		mc.unlinkLocked(victim, ReasonEvicted)
+		evicted = append(evicted, victim)
+	}
+
+	if skipped {
+		for _, victim := range mc.policy.Add(keep).Evicted {
+			mc.unlinkLocked(victim, ReasonEvicted)
+			evicted = append(evicted, victim)
+		}
+	}
+
+	return evicted
+}
+
+// fitAnyLocked is fitLocked for policies that are no Evictor: it evicts
+// arbitrary entries other than keep.
+func (mc *MemCache) fitAnyLocked(keep string) []string {
+	var evicted []string
+
+	for key := range mc.items {
+		if mc.bytes <= mc.maxBytes {
+			break
+		}
+
+		if key != keep {
+			mc.removeLocked(key, ReasonEvicted)
+			evicted = append(evicted, key)
+		}
+	}
+
+	return evicted
+}
@@ -0,0 +1,233 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/cachetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemCacheBytes(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	mcache := newMemCache(t, cache.WithClock(clock), cache.WithSizer(func(value any) int64 {
+		if p, ok := value.(snapshotPoint); ok {
+			return int64(p.X)
+		}
+
+		return -1 // counted as zero
+	}))
+
+	require.NoError(t, mcache.Set(ctx, "bytes", make([]byte, 100), 0))
+	assert.Equal(t, uint64(5+100), mcache.Metrics().Bytes, "key and value are counted")
+
+	require.NoError(t, mcache.Set(ctx, "str", "hello", 0))
+	require.NoError(t, mcache.Set(ctx, "point", snapshotPoint{X: 40, Y: 0}, 0))
+	require.NoError(t, mcache.Set(ctx, "other", 42, 0))
+	assert.Equal(t, uint64(105+8+45+5), mcache.Metrics().Bytes)
+
+	require.NoError(t, mcache.Set(ctx, "bytes", make([]byte, 10), 0))
+	assert.Equal(t, uint64(15+8+45+5), mcache.Metrics().Bytes, "overwrites replace the old size")
+
+	require.NoError(t, mcache.Delete(ctx, "bytes", "point", "other"))
+	assert.Equal(t, uint64(8), mcache.Metrics().Bytes)
+
+	require.NoError(t, mcache.Set(ctx, "short", "v", time.Nanosecond))
+	clock.Advance(time.Millisecond)
+
+	_, err := mcache.Get(ctx, "short")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+	assert.Equal(t, uint64(8), mcache.Metrics().Bytes, "lazily evicted entries are uncounted")
+
+	assert.Equal(t, uint64(8), mcache.ResetMetrics().Bytes)
+	assert.Equal(t, uint64(8), mcache.Metrics().Bytes, "Reset keeps the current size")
+}
+
+func TestMemCacheDefaultSizes(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := newMemCache(t)
+
+	require.NoError(t, mcache.Set(ctx, "i", int64(1), 0))
+	require.NoError(t, mcache.Set(ctx, "b", true, 0))
+	require.NoError(t, mcache.Set(ctx, "n", nil, 0))
+	require.NoError(t, mcache.Set(ctx, "p", snapshotPoint{X: 1, Y: 2}, 0))
+
+	assert.Equal(t, uint64(1+8+1+1+1+0+1+16), mcache.Metrics().Bytes, "shallow size of the value type")
+}
+
+func TestMemCacheMaxValueBytes(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := newMemCache(t, cache.WithMaxValueBytes(10), cache.WithMaxBytes(100))
+
+	require.NoError(t, mcache.Set(ctx, "fits", strings.Repeat("x", 10), 0))
+
+	err := mcache.Set(ctx, "big", strings.Repeat("x", 11), 0)
+	require.ErrorIs(t, err, cache.ErrTooLarge)
+
+	var sizeErr *cache.SizeError
+	require.ErrorAs(t, err, &sizeErr)
+	assert.Equal(t, cache.SizeError{Key: "big", Size: 11, Limit: 10}, *sizeErr)
+	assert.Equal(t, `value of key "big" is 11 bytes, limit is 10 bytes`, err.Error())
+
+	err = mcache.SetWithTags(ctx, "fits", bytes.Repeat([]byte{1}, 11), 0, "tag")
+	require.ErrorIs(t, err, cache.ErrTooLarge)
+
+	value, err := mcache.Get(ctx, "fits")
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("x", 10), value, "a rejected overwrite keeps the old value")
+
+	_, err = mcache.Get(ctx, "big")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	err = mcache.Set(ctx, strings.Repeat("k", 95), "123456", 0)
+	require.ErrorIs(t, err, cache.ErrTooLarge, "entries larger than the budget are rejected")
+	require.ErrorAs(t, err, &sizeErr)
+	assert.Equal(t, int64(100), sizeErr.Limit)
+
+	assert.Equal(t, uint64(14), mcache.Metrics().Bytes)
+	assert.Equal(t, uint64(1), mcache.Metrics().Sets)
+}
+
+func TestMemCacheMaxBytes(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := newMemCache(t, cache.WithMaxBytes(100))
+
+	var log eventLog
+
+	mcache.Subscribe(log.add)
+
+	blob := make([]byte, 29) // 30 bytes per entry with a one byte key
+
+	require.NoError(t, mcache.Set(ctx, "a", blob, 0))
+	require.NoError(t, mcache.Set(ctx, "b", blob, 0))
+	require.NoError(t, mcache.Set(ctx, "c", blob, 0))
+
+	_, err := mcache.Get(ctx, "a")
+	require.NoError(t, err)
+
+	require.NoError(t, mcache.Set(ctx, "d", blob, 0))
+
+	_, err = mcache.Get(ctx, "b")
+	require.ErrorIs(t, err, cache.ErrNotFound, "least recently used entry is evicted")
+
+	require.NoError(t, mcache.Set(ctx, "e", make([]byte, 79), 0))
+
+	keys := make([]string, 0, 2)
+	for key := range mcache.Keys() {
+		keys = append(keys, key)
+	}
+
+	assert.ElementsMatch(t, []string{"e"}, keys, "as many entries as needed are evicted")
+
+	mt := mcache.Metrics()
+	assert.Equal(t, uint64(80), mt.Bytes)
+	assert.Equal(t, uint64(4), mt.CapacityEvictions)
+
+	require.Eventually(t, func() bool { return log.len() == 4 }, time.Second, time.Millisecond)
+	assert.Equal(t, map[string]cache.EventReason{
+		fmt.Sprintf("b=%v", blob): cache.ReasonEvicted,
+		fmt.Sprintf("c=%v", blob): cache.ReasonEvicted,
+		fmt.Sprintf("a=%v", blob): cache.ReasonEvicted,
+		fmt.Sprintf("d=%v", blob): cache.ReasonEvicted,
+	}, log.reasons())
+}
+
+func TestMemCacheMaxBytesWithPolicy(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+
+	for name, newPolicy := range map[string]cache.NewPolicyFunc{"lru": cache.NewLRU, "tinylfu": cache.NewTinyLFU} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mcache := newMemCache(t, cache.WithMaxEntries(100), cache.WithPolicy(newPolicy), cache.WithMaxBytes(1000))
+
+			for i := range 200 {
+				key := fmt.Sprintf("k-%03d", i)
+				err := mcache.Set(ctx, key, strings.Repeat("x", 5+i%50), 0)
+				require.NoError(t, err)
+
+				assert.LessOrEqual(t, mcache.Metrics().Bytes, uint64(1000))
+			}
+
+			// Growing an entry in place evicts others, never the entry itself.
+			require.NoError(t, mcache.Set(ctx, "k-199", strings.Repeat("x", 900), 0))
+
+			value, err := mcache.Get(ctx, "k-199")
+			require.NoError(t, err)
+			assert.Len(t, value, 900)
+			assert.LessOrEqual(t, mcache.Metrics().Bytes, uint64(1000))
+		})
+	}
+}
+
+func TestMemCacheMaxBytesWithoutEvictor(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+
+	// Embedding hides the Evict method of the LRU.
+	opaque := func(capacity int) cache.Policy {
+		return struct{ cache.Policy }{cache.NewLRU(capacity)}
+	}
+
+	mcache := newMemCache(t, cache.WithMaxEntries(10), cache.WithPolicy(opaque), cache.WithMaxBytes(50))
+
+	for i := range 10 {
+		require.NoError(t, mcache.Set(ctx, fmt.Sprintf("k-%d", i), "123456789", 0))
+	}
+
+	assert.LessOrEqual(t, mcache.Metrics().Bytes, uint64(50))
+
+	_, err := mcache.Get(ctx, "k-9")
+	require.NoError(t, err, "the entry just stored is kept")
+}
+
+func TestShardedMaxBytes(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	sharded := cache.NewSharded(4, time.Minute, Logger(t), cache.WithMaxBytes(400))
+
+	t.Cleanup(func() {
+		require.NoError(t, sharded.Close(ctx))
+	})
+
+	for i := range 100 {
+		require.NoError(t, sharded.Set(ctx, fmt.Sprintf("k-%02d", i), "0123456789", 0))
+	}
+
+	assert.LessOrEqual(t, sharded.Metrics().Bytes, uint64(400))
+
+	err := sharded.Set(ctx, "big", strings.Repeat("x", 101), 0)
+	require.ErrorIs(t, err, cache.ErrTooLarge, "a shard holds a quarter of the budget")
+}
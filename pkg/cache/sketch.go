@@ -0,0 +1,115 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import "math/bits"
+
+const (
+	sketchDepth = 4
+	// sketchMaxCount caps counters so that old popularity decays quickly
+	// once the sketch is aged.
+	sketchMaxCount = 15
+	// sketchSampleFactor controls how many increments, relative to the
+	// tracked capacity, happen between two aging passes.
+	sketchSampleFactor = 10
+
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// cmSketch is a count-min sketch estimating how often a key was seen.
+//
+// Counters saturate at sketchMaxCount and are halved every sample period so
+// that the estimate reflects recent popularity rather than all-time totals.
+// cmSketch is not safe for concurrent use; its owner must synchronize.
+type cmSketch struct {
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions int
+	sample    int
+}
+
+func newCMSketch(capacity int) *cmSketch {
+	width := uint64(1) << bits.Len64(uint64(max(capacity, 16)-1))
+
+	sketch := &cmSketch{
+		mask:   width - 1,
+		sample: sketchSampleFactor * max(capacity, 1),
+	}
+
+	for i := range sketch.rows {
+		sketch.rows[i] = make([]uint8, width)
+	}
+
+	return sketch
+}
+
+// increment records one occurrence of key.
+func (s *cmSketch) increment(key string) {
+	hash, step := sketchHash(key)
+
+	for i := range s.rows {
+		idx := (hash + uint64(i)*step) & s.mask
+		if s.rows[i][idx] < sketchMaxCount {
+			s.rows[i][idx]++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.sample {
+		s.age()
+	}
+}
+
+// estimate returns the approximate number of recent occurrences of key.
+func (s *cmSketch) estimate(key string) uint8 {
+	hash, step := sketchHash(key)
+	low := uint8(sketchMaxCount)
+
+	for i := range s.rows {
+		idx := (hash + uint64(i)*step) & s.mask
+		low = min(low, s.rows[i][idx])
+	}
+
+	return low
+}
+
+// age halves every counter.
+func (s *cmSketch) age() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+
+	s.additions /= 2
+}
+
+// sketchHash returns the base hash and odd step used for double hashing.
+func sketchHash(key string) (uint64, uint64) {
+	hash := keyHash(key)
+	return hash, (hash >> 32) | 1
+}
+
+// keyHash is an allocation-free FNV-64a hash of key.
+func keyHash(key string) uint64 {
+	hash := uint64(fnvOffset64)
+	for i := range len(key) {
+		hash ^= uint64(key[i])
+		hash *= fnvPrime64
+	}
+
+	return hash
+}
@@ -0,0 +1,708 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"slices"
+	"time"
+)
+
+// Snapshot format, version 3. All integers are varints unless noted.
+//
+//	header:  "TKCACHE" version:byte
+//	entry:   0x01 key expiresAt refreshAt ttl refreshAfter kind value
+//	tagged:  0x02 key expiresAt refreshAt ttl refreshAfter kind value tags
+//	sliding: 0x03 key expiresAt refreshAt ttl refreshAfter kind value tags
+//	trailer: 0x00 count:uvarint crc32:uint32be
+//
+// Keys, values and tags are length-prefixed byte strings; tags is a count
+// followed by that many tags. Sliding entries, written with SetSliding,
+// always carry tags, possibly none. Times are Unix nanoseconds, 0 meaning
+// unset. The CRC-32 (IEEE) covers every byte before it.
+//
+// Each kind of record raised the version that introduced it: version 1
+// snapshots only hold plain entries and version 2 snapshots also tagged
+// ones, written by SetWithTags. Both are restored as is.
+const (
+	snapshotMagic      = "TKCACHE"
+	snapshotVersion    = 3
+	minSnapshotVersion = 1
+
+	recordEnd          = 0
+	recordEntry        = 1
+	recordTaggedEntry  = 2
+	recordSlidingEntry = 3
+)
+
+// Value kinds. Primitive types are stored natively, everything else through
+// the cache Codec.
+const (
+	kindNil byte = iota
+	kindBytes
+	kindString
+	kindBool
+	kindInt
+	kindInt8
+	kindInt16
+	kindInt32
+	kindInt64
+	kindUint
+	kindUint8
+	kindUint16
+	kindUint32
+	kindUint64
+	kindFloat32
+	kindFloat64
+	kindCodec
+)
+
+// Snapshot writes the live entries of the cache to w.
+//
+// Entries keep their absolute expiry, so a snapshot restored later only
+// holds what is still valid then. Values of primitive types are stored
+// natively; other values are encoded with the Codec set by WithValueCodec.
+//
+// Snapshot copies the entry set under a read lock and encodes it without
+// holding any lock, so concurrent writes are not blocked by slow writers
+// and are not part of the snapshot.
+//
+// The operation can be cancelled via the context. If cancelled, Snapshot
+// returns ErrAborted and w holds an incomplete snapshot that Restore rejects.
+func (mc *MemCache) Snapshot(ctx context.Context, w io.Writer) error {
+	now := mc.now()
+
+	mc.mx.RLock()
+
+	keys := make([]string, 0, len(mc.items))
+	entries := make([]entry, 0, len(mc.items))
+
+	for key, val := range mc.items {
+		if !val.IsExpired(now) {
+			keys = append(keys, key)
+			entries = append(entries, val)
+		}
+	}
+
+	mc.mx.RUnlock()
+
+	sw := newSnapshotWriter(w)
+	sw.header()
+
+	for i, key := range keys {
+		select {
+		case <-ctx.Done():
+			mc.log.Error().
+				Err(ctx.Err()).
+				Str("key", key).
+				Msg("snapshot aborted")
+
+			return ErrAborted
+		default:
+		}
+
+		if err := sw.entry(key, entries[i], mc.codec); err != nil {
+			return fmt.Errorf("snapshot key %q: %w", key, err)
+		}
+	}
+
+	if err := sw.trailer(uint64(len(keys))); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+
+	mc.log.Info().Int("entries", len(keys)).Msg("cache snapshot written")
+
+	return nil
+}
+
+// Restore loads a snapshot written by Snapshot into the cache.
+//
+// Restored entries overwrite existing entries with the same key; other
+// entries are kept. Entries that expired since the snapshot was taken are
+// skipped. A bounded cache admits restored keys through its Policy like Set.
+//
+// The snapshot is decoded and verified completely before the cache is
+// modified: a corrupt, truncated or unsupported snapshot returns an error
+// wrapping ErrSnapshot and leaves the cache untouched.
+//
+// The operation can be cancelled via the context. If cancelled, Restore
+// returns ErrAborted and leaves the cache untouched.
+func (mc *MemCache) Restore(ctx context.Context, r io.Reader) error {
+	restored, err := mc.readSnapshot(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	loaded := mc.loadEntries(restored)
+
+	mc.log.Info().
+		Int("entries", loaded).
+		Int("skipped", restored.len()-loaded).
+		Msg("cache snapshot restored")
+
+	if mc.wlog != nil {
+		return mc.wlog.commit()
+	}
+
+	return nil
+}
+
+// entrySet holds decoded entries with the position of the record that last
+// wrote each of them, so that they are loaded in the order they were written
+// and a bounded cache keeps the same keys on every load.
+type entrySet struct {
+	entries map[string]entry
+	order   map[string]int
+	next    int
+}
+
+func newEntrySet() *entrySet {
+	return &entrySet{entries: make(map[string]entry), order: make(map[string]int), next: 0}
+}
+
+// set stores val under key as the latest write.
+func (es *entrySet) set(key string, val entry) {
+	es.entries[key] = val
+	es.order[key] = es.next
+	es.next++
+}
+
+func (es *entrySet) delete(key string) {
+	delete(es.entries, key)
+	delete(es.order, key)
+}
+
+func (es *entrySet) len() int {
+	return len(es.entries)
+}
+
+// keys returns the keys of the set in write order.
+func (es *entrySet) keys() []string {
+	keys := make([]string, 0, len(es.entries))
+	for key := range es.entries {
+		keys = append(keys, key)
+	}
+
+	slices.SortFunc(keys, func(a, b string) int {
+		return es.order[a] - es.order[b]
+	})
+
+	return keys
+}
+
+// loadEntries stores entries that are not expired and fit in the size
+// limits in write order, as Set would but without counting them as sets, and
+// returns how many were stored.
+func (mc *MemCache) loadEntries(entries *entrySet) int {
+	var (
+		admission Admission
+		evicted   int
+		loaded    int
+	)
+
+	now := mc.now()
+
+	mc.mx.Lock()
+
+	for _, key := range entries.keys() {
+		val := entries.entries[key]
+		if val.IsExpired(now) {
+			continue
+		}
+
+		if err := mc.sizeEntry(key, &val); err != nil {
+			mc.log.Warn().Err(err).Str("key", key).Msg("skipped oversized entry")
+			continue
+		}
+
+		admission = mc.storeLocked(key, val)
+		mc.logSet(key, val)
+		mc.logEvicted(admission.Evicted)
+		loaded++
+
+		evicted += len(admission.Evicted)
+		mc.metrics.AddAdmission(admission.Decision)
+	}
+
+	mc.mx.Unlock()
+
+	mc.metrics.AddCapacityEviction(uint64(evicted)) //nolint:gosec // bounded by capacity
+
+	return loaded
+}
+
+// readSnapshot decodes a whole snapshot, dropping entries that are already
+// expired.
+func (mc *MemCache) readSnapshot(ctx context.Context, r io.Reader) (*entrySet, error) {
+	sr := newSnapshotReader(r)
+
+	if err := sr.header(); err != nil {
+		return nil, err
+	}
+
+	restored := newEntrySet()
+	now := mc.now()
+
+	for count := uint64(0); ; count++ {
+		select {
+		case <-ctx.Done():
+			mc.log.Error().Err(ctx.Err()).Msg("restore aborted")
+			return nil, ErrAborted
+		default:
+		}
+
+		record, err := sr.byte()
+		if err != nil {
+			return nil, err
+		}
+
+		switch record {
+		case recordEntry, recordTaggedEntry, recordSlidingEntry:
+			key, val, err := sr.entry(mc.codec)
+			if err != nil {
+				return nil, err
+			}
+
+			if record != recordEntry {
+				if val.tags, err = sr.tags(); err != nil {
+					return nil, err
+				}
+			}
+
+			val.sliding = record == recordSlidingEntry
+
+			if !val.IsExpired(now) {
+				restored.set(key, val)
+			}
+		case recordEnd:
+			if err := sr.trailer(count); err != nil {
+				return nil, err
+			}
+
+			return restored, nil
+		default:
+			return nil, fmt.Errorf("%w: unknown record type %d", ErrSnapshot, record)
+		}
+	}
+}
+
+// snapshotWriter encodes snapshot records, keeping a running checksum.
+// Write errors are sticky and reported by the next call that returns one.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	crc hash.Hash32
+	buf []byte
+	err error
+}
+
+func newSnapshotWriter(w io.Writer) *snapshotWriter {
+	return &snapshotWriter{w: bufio.NewWriter(w), crc: crc32.NewIEEE(), buf: nil, err: nil}
+}
+
+func (sw *snapshotWriter) write(p []byte) {
+	if sw.err != nil {
+		return
+	}
+
+	_, _ = sw.crc.Write(p)
+	_, sw.err = sw.w.Write(p)
+}
+
+func (sw *snapshotWriter) header() {
+	sw.write(append([]byte(snapshotMagic), snapshotVersion))
+}
+
+func (sw *snapshotWriter) entry(key string, val entry, codec Codec) error {
+	record := byte(recordEntry)
+
+	switch {
+	case val.sliding:
+		record = recordSlidingEntry
+	case len(val.tags) > 0:
+		record = recordTaggedEntry
+	}
+
+	buf, err := appendEntry(append(sw.buf[:0], record), key, val, codec)
+	if err != nil {
+		return err
+	}
+
+	if record != recordEntry {
+		buf = appendTags(buf, val.tags)
+	}
+
+	sw.buf = buf
+	sw.write(buf)
+
+	return sw.err
+}
+
+func (sw *snapshotWriter) trailer(count uint64) error {
+	sw.write(binary.AppendUvarint([]byte{recordEnd}, count))
+
+	if sw.err != nil {
+		return sw.err
+	}
+
+	if _, err := sw.w.Write(binary.BigEndian.AppendUint32(nil, sw.crc.Sum32())); err != nil {
+		return err
+	}
+
+	return sw.w.Flush()
+}
+
+// snapshotReader decodes snapshot records, keeping a running checksum.
+type snapshotReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+}
+
+func newSnapshotReader(r io.Reader) *snapshotReader {
+	return &snapshotReader{r: bufio.NewReader(r), crc: crc32.NewIEEE()}
+}
+
+// ReadByte implements io.ByteReader for binary.ReadVarint.
+func (sr *snapshotReader) ReadByte() (byte, error) {
+	b, err := sr.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	_, _ = sr.crc.Write([]byte{b})
+
+	return b, nil
+}
+
+func (sr *snapshotReader) byte() (byte, error) {
+	b, err := sr.ReadByte()
+	return b, snapshotReadErr(err)
+}
+
+func (sr *snapshotReader) varint() (int64, error) {
+	v, err := binary.ReadVarint(sr)
+	return v, snapshotReadErr(err)
+}
+
+func (sr *snapshotReader) uvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(sr)
+	return v, snapshotReadErr(err)
+}
+
+// bytes reads a length-prefixed byte string. The buffer grows with the data
+// actually read, so a corrupt length cannot trigger a huge allocation.
+func (sr *snapshotReader) bytes() ([]byte, error) {
+	size, err := sr.uvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	if size > math.MaxInt32 {
+		return nil, fmt.Errorf("%w: field of %d bytes", ErrSnapshot, size)
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.CopyN(io.MultiWriter(&buf, sr.crc), sr.r, int64(size)); err != nil {
+		return nil, snapshotReadErr(err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (sr *snapshotReader) header() error {
+	magic := make([]byte, len(snapshotMagic)+1)
+	if _, err := io.ReadFull(sr.r, magic); err != nil {
+		return snapshotReadErr(err)
+	}
+
+	_, _ = sr.crc.Write(magic)
+
+	if string(magic[:len(snapshotMagic)]) != snapshotMagic {
+		return fmt.Errorf("%w: bad magic", ErrSnapshot)
+	}
+
+	if version := magic[len(snapshotMagic)]; version < minSnapshotVersion || version > snapshotVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrSnapshot, version)
+	}
+
+	return nil
+}
+
+func (sr *snapshotReader) entry(codec Codec) (string, entry, error) {
+	key, err := sr.bytes()
+	if err != nil {
+		return "", entry{}, err
+	}
+
+	var fields [4]int64
+
+	for i := range fields {
+		if fields[i], err = sr.varint(); err != nil {
+			return "", entry{}, err
+		}
+	}
+
+	kind, err := sr.byte()
+	if err != nil {
+		return "", entry{}, err
+	}
+
+	data, err := sr.bytes()
+	if err != nil {
+		return "", entry{}, err
+	}
+
+	value, err := decodeSnapshotValue(kind, data, codec)
+	if err != nil {
+		return "", entry{}, fmt.Errorf("%w: key %q: %w", ErrSnapshot, key, err)
+	}
+
+	return string(key), entry{
+		value:        value,
+		expiresAt:    fromUnixNano(fields[0]),
+		refreshAt:    fromUnixNano(fields[1]),
+		ttl:          time.Duration(fields[2]),
+		refreshAfter: time.Duration(fields[3]),
+	}, nil
+}
+
+func (sr *snapshotReader) tags() ([]string, error) {
+	count, err := sr.uvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	if count > math.MaxUint16 {
+		return nil, fmt.Errorf("%w: %d tags", ErrSnapshot, count)
+	}
+
+	tags := make([]string, count)
+
+	for i := range tags {
+		tag, err := sr.bytes()
+		if err != nil {
+			return nil, err
+		}
+
+		tags[i] = string(tag)
+	}
+
+	return tags, nil
+}
+
+func (sr *snapshotReader) trailer(count uint64) error {
+	want, err := sr.uvarint()
+	if err != nil {
+		return err
+	}
+
+	if want != count {
+		return fmt.Errorf("%w: %d entries, trailer says %d", ErrSnapshot, count, want)
+	}
+
+	sum := sr.crc.Sum32()
+
+	var stored [4]byte
+	if _, err := io.ReadFull(sr.r, stored[:]); err != nil {
+		return snapshotReadErr(err)
+	}
+
+	if binary.BigEndian.Uint32(stored[:]) != sum {
+		return fmt.Errorf("%w: checksum mismatch", ErrSnapshot)
+	}
+
+	return nil
+}
+
+// snapshotReadErr maps an unexpected end of input to ErrSnapshot.
+func snapshotReadErr(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: truncated", ErrSnapshot)
+	}
+
+	return err
+}
+
+// appendEntry appends the encoding of one entry, without its record type, to
+// buf.
+func appendEntry(buf []byte, key string, val entry, codec Codec) ([]byte, error) {
+	kind, data, err := encodeSnapshotValue(val.value, codec)
+	if err != nil {
+		return nil, err
+	}
+
+	buf = appendBytes(buf, []byte(key))
+	buf = binary.AppendVarint(buf, unixNano(val.expiresAt))
+	buf = binary.AppendVarint(buf, unixNano(val.refreshAt))
+	buf = binary.AppendVarint(buf, int64(val.ttl))
+	buf = binary.AppendVarint(buf, int64(val.refreshAfter))
+	buf = append(buf, kind)
+
+	return appendBytes(buf, data), nil
+}
+
+func appendTags(buf []byte, tags []string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(tags)))
+	for _, tag := range tags {
+		buf = appendBytes(buf, []byte(tag))
+	}
+
+	return buf
+}
+
+func appendBytes(buf, data []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UnixNano()
+}
+
+func fromUnixNano(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, ns).UTC()
+}
+
+//nolint:cyclop,gosec // one case per primitive type; conversions are lossless
+func encodeSnapshotValue(value any, codec Codec) (byte, []byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return kindNil, nil, nil
+	case []byte:
+		return kindBytes, v, nil
+	case string:
+		return kindString, []byte(v), nil
+	case bool:
+		return kindBool, []byte{boolToUint8(v)}, nil
+	case int:
+		return kindInt, binary.AppendVarint(nil, int64(v)), nil
+	case int8:
+		return kindInt8, binary.AppendVarint(nil, int64(v)), nil
+	case int16:
+		return kindInt16, binary.AppendVarint(nil, int64(v)), nil
+	case int32:
+		return kindInt32, binary.AppendVarint(nil, int64(v)), nil
+	case int64:
+		return kindInt64, binary.AppendVarint(nil, v), nil
+	case uint:
+		return kindUint, binary.AppendUvarint(nil, uint64(v)), nil
+	case uint8:
+		return kindUint8, binary.AppendUvarint(nil, uint64(v)), nil
+	case uint16:
+		return kindUint16, binary.AppendUvarint(nil, uint64(v)), nil
+	case uint32:
+		return kindUint32, binary.AppendUvarint(nil, uint64(v)), nil
+	case uint64:
+		return kindUint64, binary.AppendUvarint(nil, v), nil
+	case float32:
+		return kindFloat32, binary.BigEndian.AppendUint32(nil, math.Float32bits(v)), nil
+	case float64:
+		return kindFloat64, binary.BigEndian.AppendUint64(nil, math.Float64bits(v)), nil
+	default:
+		data, err := codec.Marshal(value)
+		if err != nil {
+			return 0, nil, fmt.Errorf("%w: %w", ErrType, err)
+		}
+
+		return kindCodec, data, nil
+	}
+}
+
+//nolint:cyclop,gosec // one case per primitive type; values were written from the same types
+func decodeSnapshotValue(kind byte, data []byte, codec Codec) (any, error) {
+	switch kind {
+	case kindNil:
+		return nil, nil //nolint:nilnil // a nil value is a valid cache value
+	case kindBytes:
+		return data, nil
+	case kindString:
+		return string(data), nil
+	case kindBool:
+		return len(data) == 1 && data[0] == 1, nil
+	case kindInt, kindInt8, kindInt16, kindInt32, kindInt64:
+		v, n := binary.Varint(data)
+		if n <= 0 || n != len(data) {
+			return nil, errors.New("bad integer")
+		}
+
+		switch kind {
+		case kindInt:
+			return int(v), nil
+		case kindInt8:
+			return int8(v), nil
+		case kindInt16:
+			return int16(v), nil
+		case kindInt32:
+			return int32(v), nil
+		default:
+			return v, nil
+		}
+	case kindUint, kindUint8, kindUint16, kindUint32, kindUint64:
+		v, n := binary.Uvarint(data)
+		if n <= 0 || n != len(data) {
+			return nil, errors.New("bad unsigned integer")
+		}
+
+		switch kind {
+		case kindUint:
+			return uint(v), nil
+		case kindUint8:
+			return uint8(v), nil
+		case kindUint16:
+			return uint16(v), nil
+		case kindUint32:
+			return uint32(v), nil
+		default:
+			return v, nil
+		}
+	case kindFloat32:
+		if len(data) != 4 { //nolint:mnd // float32 size
+			return nil, errors.New("bad float32")
+		}
+
+		return math.Float32frombits(binary.BigEndian.Uint32(data)), nil
+	case kindFloat64:
+		if len(data) != 8 { //nolint:mnd // float64 size
+			return nil, errors.New("bad float64")
+		}
+
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	case kindCodec:
+		var value any
+		if err := codec.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+
+		return value, nil
+	default:
+		return nil, fmt.Errorf("unknown value kind %d", kind)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"hash/crc32"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/cachetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type snapshotPoint struct {
+	X, Y int
+}
+
+//nolint:gochecknoinits // gob needs concrete types registered before use
+func init() {
+	gob.Register(snapshotPoint{})
+}
+
+func newMemCache(t *testing.T, opts ...cache.Option) *cache.MemCache {
+	t.Helper()
+
+	mcache := cache.New(Logger(t), opts...)
+
+	t.Cleanup(func() {
+		require.NoError(t, mcache.Close(t.Context()))
+	})
+
+	return mcache
+}
+
+func TestMemCacheSnapshotRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	src := newMemCache(t, cache.WithClock(clock))
+
+	values := map[string]any{
+		"bytes":   []byte{0, 1, 2},
+		"string":  "value",
+		"bool":    true,
+		"int":     -42,
+		"int8":    int8(-8),
+		"int16":   int16(-16),
+		"int32":   int32(-32),
+		"int64":   int64(-64),
+		"uint":    uint(42),
+		"uint8":   uint8(8),
+		"uint16":  uint16(16),
+		"uint32":  uint32(32),
+		"uint64":  uint64(64),
+		"float32": float32(1.5),
+		"float64": 2.5,
+		"nil":     nil,
+		"struct":  snapshotPoint{X: 1, Y: 2},
+	}
+
+	for key, value := range values {
+		require.NoError(t, src.Set(ctx, key, value, time.Hour))
+	}
+
+	require.NoError(t, src.Set(ctx, "expired", "v", time.Nanosecond))
+	clock.Advance(time.Millisecond)
+
+	var buf bytes.Buffer
+	require.NoError(t, src.Snapshot(ctx, &buf))
+
+	dst := newMemCache(t)
+	require.NoError(t, dst.Set(ctx, "string", "old", 0))
+	require.NoError(t, dst.Set(ctx, "kept", "v", 0))
+	require.NoError(t, dst.Restore(ctx, &buf))
+
+	for key, want := range values {
+		got, err := dst.Get(ctx, key)
+		require.NoError(t, err, key)
+		assert.Equal(t, want, got, key)
+	}
+
+	_, err := dst.Get(ctx, "expired")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	_, err = dst.Get(ctx, "kept")
+	require.NoError(t, err, "restore keeps keys missing from the snapshot")
+	assert.Equal(t, len(values)+1, dst.Size())
+}
+
+func TestMemCacheSnapshotKeepsExpiry(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	src := newMemCache(t, cache.WithClock(clock))
+
+	require.NoError(t, src.Set(ctx, "short", "v", 50*time.Millisecond))
+	require.NoError(t, src.Set(ctx, "long", "v", time.Hour))
+
+	var buf bytes.Buffer
+	require.NoError(t, src.Snapshot(ctx, &buf))
+
+	clock.Advance(100 * time.Millisecond)
+
+	dst := newMemCache(t, cache.WithClock(clock))
+	require.NoError(t, dst.Restore(ctx, &buf))
+
+	assert.Equal(t, 1, dst.Size(), "entries expired since the snapshot are skipped")
+
+	_, err := dst.Get(ctx, "long")
+	require.NoError(t, err)
+}
+
+func TestMemCacheSnapshotCodec(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	src := newMemCache(t, cache.WithValueCodec(cache.JSONCodec{}))
+
+	require.NoError(t, src.Set(ctx, "point", snapshotPoint{X: 1, Y: 2}, 0))
+	require.NoError(t, src.Set(ctx, "chan", make(chan int), 0))
+	require.ErrorIs(t, src.Snapshot(ctx, &bytes.Buffer{}), cache.ErrType)
+	require.NoError(t, src.Delete(ctx, "chan"))
+
+	var buf bytes.Buffer
+	require.NoError(t, src.Snapshot(ctx, &buf))
+
+	dst := newMemCache(t, cache.WithValueCodec(cache.JSONCodec{}))
+	require.NoError(t, dst.Restore(ctx, &buf))
+
+	got, err := dst.Get(ctx, "point")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"X": 1.0, "Y": 2.0}, got, "JSON decodes structs into maps")
+}
+
+func TestMemCacheRestoreInvalid(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	src := newMemCache(t)
+
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, src.Set(ctx, key, key, 0))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, src.Snapshot(ctx, &buf))
+
+	snapshot := buf.Bytes()
+	dst := newMemCache(t)
+
+	t.Run("truncated", func(t *testing.T) {
+		t.Parallel()
+
+		for size := range len(snapshot) {
+			err := dst.Restore(ctx, bytes.NewReader(snapshot[:size]))
+			require.ErrorIs(t, err, cache.ErrSnapshot, "size %d", size)
+		}
+
+		assert.Equal(t, 0, dst.Size(), "a failed restore loads nothing")
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		t.Parallel()
+
+		for i := range snapshot {
+			corrupt := bytes.Clone(snapshot)
+			corrupt[i] ^= 0x20
+
+			err := dst.Restore(ctx, bytes.NewReader(corrupt))
+			require.ErrorIs(t, err, cache.ErrSnapshot, "byte %d", i)
+		}
+
+		assert.Equal(t, 0, dst.Size(), "a failed restore loads nothing")
+	})
+
+	t.Run("version", func(t *testing.T) {
+		t.Parallel()
+
+		newer := bytes.Clone(snapshot)
+		newer[len("TKCACHE")] = 99
+
+		err := dst.Restore(ctx, bytes.NewReader(newer))
+		require.ErrorIs(t, err, cache.ErrSnapshot)
+		require.ErrorContains(t, err, "unsupported version 99")
+
+		for _, version := range []byte{1, 2} {
+			older := bytes.Clone(snapshot)
+			older[len("TKCACHE")] = version
+			binary.BigEndian.PutUint32(older[len(older)-4:], crc32.ChecksumIEEE(older[:len(older)-4]))
+
+			require.NoError(t, newMemCache(t).Restore(ctx, bytes.NewReader(older)), "version %d is still restored", version)
+		}
+	})
+
+	t.Run("aborted", func(t *testing.T) {
+		t.Parallel()
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		require.ErrorIs(t, dst.Restore(cancelled, bytes.NewReader(snapshot)), cache.ErrAborted)
+		require.ErrorIs(t, src.Snapshot(cancelled, &bytes.Buffer{}), cache.ErrAborted)
+	})
+}
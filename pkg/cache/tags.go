@@ -0,0 +1,119 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// SetWithTags stores key/value with the provided TTL like Set and attaches
+// tags to the entry, so that InvalidateTags can later remove it together with
+// every other entry sharing one of the tags. Overwriting a key replaces its
+// tags; Set drops them.
+func (mc *MemCache) SetWithTags(_ context.Context, key string, value any, ttl time.Duration, tags ...string) error {
+	val := newEntry(value, ttl, mc.now())
+	val.tags = uniqueTags(tags)
+
+	return mc.set(key, val)
+}
+
+// InvalidateTags removes every entry carrying at least one of tags.
+//
+// The entries are removed under a single write lock, so no reader observes
+// a partially invalidated group. Entries leaving the cache by expiry or
+// eviction leave the tag index with them. Removed entries are counted in
+// Metrics.TagInvalidations.
+//
+// If the context is cancelled before the entries are removed,
+// InvalidateTags returns ErrAborted and removes nothing.
+func (mc *MemCache) InvalidateTags(ctx context.Context, tags ...string) error {
+	select {
+	case <-ctx.Done():
+		mc.log.Error().
+			Err(ctx.Err()).
+			Strs("tags", tags).
+			Msg("invalidate tags aborted")
+
+		return ErrAborted
+	default:
+	}
+
+	removed := uint64(0)
+
+	mc.mx.Lock()
+
+	for _, tag := range tags {
+		for key := range mc.tags[tag] {
+			mc.removeLocked(key, ReasonInvalidated)
+
+			if mc.wlog != nil {
+				mc.wlog.append(encodeLogDelete(key))
+			}
+
+			removed++
+		}
+	}
+
+	mc.mx.Unlock()
+
+	mc.metrics.AddTagInvalidation(removed)
+
+	if mc.wlog != nil {
+		return mc.wlog.commit()
+	}
+
+	return nil
+}
+
+// tagLocked adds key to the index of each tag. The caller must hold the
+// write lock.
+func (mc *MemCache) tagLocked(key string, tags []string) {
+	for _, tag := range tags {
+		keys, ok := mc.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			mc.tags[tag] = keys
+		}
+
+		keys[key] = struct{}{}
+	}
+}
+
+// untagLocked removes key from the index of each tag, dropping tags left
+// without keys. The caller must hold the write lock.
+func (mc *MemCache) untagLocked(key string, tags []string) {
+	for _, tag := range tags {
+		keys := mc.tags[tag]
+		delete(keys, key)
+
+		if len(keys) == 0 {
+			delete(mc.tags, tag)
+		}
+	}
+}
+
+// uniqueTags returns tags without duplicates, or nil if there are none.
+func uniqueTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	unique := slices.Clone(tags)
+	slices.Sort(unique)
+
+	return slices.Compact(unique)
+}
@@ -0,0 +1,173 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/cachetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemCacheInvalidateTags(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	mcache := newMemCache(t)
+
+	require.NoError(t, mcache.SetWithTags(ctx, "a", 1, 0, "orders"))
+	require.NoError(t, mcache.SetWithTags(ctx, "b", 2, 0, "orders", "users", "orders"))
+	require.NoError(t, mcache.SetWithTags(ctx, "c", 3, 0, "users"))
+	require.NoError(t, mcache.SetWithTags(ctx, "d", 4, 0, "items"))
+	require.NoError(t, mcache.Set(ctx, "e", 5, 0))
+
+	// Overwriting replaces the tags of an entry.
+	require.NoError(t, mcache.SetWithTags(ctx, "d", 4, 0, "users"))
+	require.NoError(t, mcache.Set(ctx, "c", 3, 0))
+
+	require.NoError(t, mcache.InvalidateTags(ctx, "orders", "items"))
+
+	for _, key := range []string{"a", "b"} {
+		_, err := mcache.Get(ctx, key)
+		require.ErrorIs(t, err, cache.ErrNotFound, key)
+	}
+
+	for _, key := range []string{"c", "d", "e"} {
+		_, err := mcache.Get(ctx, key)
+		require.NoError(t, err, key)
+	}
+
+	require.NoError(t, mcache.InvalidateTags(ctx, "users", "unknown"))
+
+	_, err := mcache.Get(ctx, "d")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	_, err = mcache.Get(ctx, "c")
+	require.NoError(t, err, "Set drops the tags of an entry")
+
+	assert.Equal(t, uint64(3), mcache.Metrics().TagInvalidations)
+	assert.Equal(t, uint64(0), mcache.Metrics().Deletes)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	require.NoError(t, mcache.SetWithTags(ctx, "f", 6, 0, "orders"))
+	require.ErrorIs(t, mcache.InvalidateTags(cancelled, "orders"), cache.ErrAborted)
+
+	_, err = mcache.Get(ctx, "f")
+	require.NoError(t, err)
+}
+
+func TestMemCacheTagIndexFollowsEvictions(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	mcache := cache.WithDeleteInterval(5*time.Millisecond, Logger(t), cache.WithMaxEntries(10), cache.WithClock(clock))
+
+	t.Cleanup(func() {
+		require.NoError(t, mcache.Close(t.Context()))
+	})
+
+	require.NoError(t, mcache.SetWithTags(ctx, "lazy", 1, time.Nanosecond, "tag"))
+	require.NoError(t, mcache.SetWithTags(ctx, "scheduled", 1, time.Nanosecond, "tag"))
+	require.NoError(t, mcache.SetWithTags(ctx, "capacity", 1, 0, "tag"))
+
+	clock.Advance(time.Millisecond)
+
+	_, err := mcache.Get(ctx, "lazy")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	clock.Advance(5 * time.Millisecond)
+	waitCleanupRuns(t, mcache, 1)
+	require.Equal(t, uint64(1), mcache.Metrics().ScheduledEvictions)
+
+	for i := range 10 {
+		require.NoError(t, mcache.Set(ctx, fmt.Sprintf("filler-%d", i), i, 0))
+	}
+
+	require.Equal(t, uint64(1), mcache.Metrics().CapacityEvictions)
+
+	// Untagged entries reusing the keys must not be reached through the
+	// stale tags of their evicted predecessors.
+	require.NoError(t, mcache.Delete(ctx, "filler-0"))
+
+	for _, key := range []string{"lazy", "scheduled"} {
+		require.NoError(t, mcache.Set(ctx, key, 2, 0))
+	}
+
+	require.NoError(t, mcache.InvalidateTags(ctx, "tag"))
+
+	assert.Equal(t, uint64(0), mcache.Metrics().TagInvalidations)
+
+	for _, key := range []string{"lazy", "scheduled"} {
+		_, err := mcache.Get(ctx, key)
+		require.NoError(t, err, key)
+	}
+}
+
+func TestMemCacheTagsPersist(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	path := filepath.Join(t.TempDir(), "cache.log")
+	src := openLogged(t, path)
+
+	require.NoError(t, src.SetWithTags(ctx, "a", 1, 0, "orders"))
+	require.NoError(t, src.SetWithTags(ctx, "b", 2, 0, "users"))
+	require.NoError(t, src.Set(ctx, "c", 3, 0))
+
+	var buf bytes.Buffer
+	require.NoError(t, src.Snapshot(ctx, &buf))
+	require.NoError(t, src.InvalidateTags(ctx, "users"))
+	require.NoError(t, src.Close(ctx))
+
+	restored := newMemCache(t)
+	require.NoError(t, restored.Restore(ctx, &buf))
+	require.NoError(t, restored.InvalidateTags(ctx, "orders"))
+	assert.Equal(t, 2, restored.Size(), "restored entries keep their tags")
+
+	replayed := openLogged(t, path)
+	assert.Equal(t, 2, replayed.Size(), "tag invalidations are logged")
+	require.NoError(t, replayed.InvalidateTags(ctx, "orders"))
+	assert.Equal(t, 1, replayed.Size(), "replayed entries keep their tags")
+}
+
+func TestShardedInvalidateTags(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	sharded := cache.NewSharded(4, time.Minute, Logger(t))
+
+	t.Cleanup(func() {
+		require.NoError(t, sharded.Close(t.Context()))
+	})
+
+	for i := range 100 {
+		require.NoError(t, sharded.SetWithTags(ctx, fmt.Sprintf("k-%d", i), i, 0, fmt.Sprintf("mod-%d", i%2)))
+	}
+
+	require.NoError(t, sharded.InvalidateTags(ctx, "mod-0"))
+
+	assert.Equal(t, 50, sharded.Size())
+	assert.Equal(t, uint64(50), sharded.Metrics().TagInvalidations)
+}
@@ -0,0 +1,435 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultL1TTL is the default lifetime of entries in the local tier of a
+// Tiered cache.
+const DefaultL1TTL = time.Minute
+
+// Tiered is a two-tier cache composing a local MemCache (L1) with a slower,
+// usually remote, Cache (L2).
+//
+// Tiered deals in bytes, like the byte-oriented backends it usually fronts:
+// values are converted with EncodeValue before being written to either tier,
+// and Get returns []byte whichever tier served the key. Use Typed or Encoded
+// on top of a Tiered cache to store other types.
+//
+// Reads go L1, then L2; an L2 hit populates L1 with a TTL of at most l1TTL,
+// so L1 entries written by other processes age out quickly. If L2 reports
+// the time a key has left, as MemCache, Sharded and the redis and memcached
+// clients do with a TTL method, the L1 copy also expires no later than the L2
+// entry. Writes and deletes go through both tiers, L2 first.
+//
+// L1 may drift from L2 when other processes write to L2. Digest and
+// Revalidate compare the fingerprints of both tiers and drop drifted L1
+// entries.
+//
+// Tiered owns both tiers: Close closes L1 and L2.
+type Tiered struct {
+	l1      *MemCache
+	l2      Cache
+	metrics TierMetrics
+	log     zerolog.Logger
+	l1TTL   time.Duration
+}
+
+// NewTiered returns a Tiered cache over l1 and l2. If l1TTL is <= 0,
+// DefaultL1TTL is used.
+func NewTiered(l1 *MemCache, l2 Cache, l1TTL time.Duration, log zerolog.Logger) *Tiered {
+	if l1TTL <= 0 {
+		l1TTL = DefaultL1TTL
+	}
+
+	return &Tiered{
+		l1:      l1,
+		l2:      l2,
+		metrics: TierMetrics{},
+		log:     log,
+		l1TTL:   l1TTL,
+	}
+}
+
+// ttlReader is implemented by caches that report the time a key has left,
+// such as MemCache. TTL returns 0 for keys that do not expire and ErrNotFound
+// for missing keys.
+type ttlReader interface {
+	TTL(ctx context.Context, key string) (time.Duration, error)
+}
+
+// localTTL returns the L1 TTL for an entry written to L2 with ttl.
+func (tc *Tiered) localTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 || ttl > tc.l1TTL {
+		return tc.l1TTL
+	}
+
+	return ttl
+}
+
+// remoteTTL returns the L1 TTL for key after an L2 hit: the L1 TTL, capped
+// at the time key has left in L2 if L2 reports it. It reports false if key
+// should not be copied to L1, because it expired meanwhile or its TTL could
+// not be read.
+func (tc *Tiered) remoteTTL(ctx context.Context, key string) (time.Duration, bool) {
+	reader, ok := tc.l2.(ttlReader)
+	if !ok {
+		return tc.l1TTL, true
+	}
+
+	ttl, err := reader.TTL(ctx, key)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			tc.metrics.AddL2Error()
+			tc.log.Error().Err(err).Str("key", key).Msg("read l2 ttl failed")
+		}
+
+		return 0, false
+	}
+
+	return tc.localTTL(ttl), true
+}
+
+// Set stores key/value in L2 with ttl and then in L1 with the shorter of ttl
+// and the L1 TTL. Both tiers store the bytes returned by EncodeValue; values
+// it cannot convert are rejected with ErrType before either tier is written.
+//
+// If L2 rejects the value, the key is removed from L1 so that readers do not
+// keep seeing the previous value, and the L2 error is returned.
+func (tc *Tiered) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	data, err := EncodeValue(value)
+	if err != nil {
+		return err
+	}
+
+	if err := tc.l2.Set(ctx, key, data, ttl); err != nil {
+		tc.metrics.AddL2Error()
+
+		return errors.Join(err, tc.l1.Delete(context.WithoutCancel(ctx), key))
+	}
+
+	return tc.l1.Set(ctx, key, data, tc.localTTL(ttl))
+}
+
+// Get returns the value for key from L1, falling back to L2, as []byte.
+//
+// An L2 hit is converted with EncodeValue and copied to L1. If the key is
+// missing in both tiers, Get returns ErrNotFound; other L2 errors are
+// returned as is.
+func (tc *Tiered) Get(ctx context.Context, key string) (any, error) {
+	if value, err := tc.l1.Get(ctx, key); err == nil {
+		tc.metrics.AddL1Hit()
+		return value, nil
+	}
+
+	tc.metrics.AddL1Miss()
+
+	value, err := tc.l2.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			tc.metrics.AddL2Miss()
+		} else {
+			tc.metrics.AddL2Error()
+		}
+
+		return nil, err
+	}
+
+	tc.metrics.AddL2Hit()
+
+	data, err := EncodeValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl, ok := tc.remoteTTL(ctx, key); ok {
+		if err := tc.l1.Set(ctx, key, data, ttl); err != nil {
+			tc.log.Error().Err(err).Str("key", key).Msg("populate l1 failed")
+		}
+	}
+
+	return data, nil
+}
+
+// GetMany returns the values of keys from L1, reading the keys missing there
+// from L2 with one GetMany call. Values are returned as []byte and L2 hits
+// are copied to L1 as by Get, which reads the TTL of every L2 hit if L2
+// reports TTLs. The returned missing keys are those missing from both tiers;
+// an L2 error is returned as is.
+func (tc *Tiered) GetMany(ctx context.Context, keys ...string) (map[string]any, []string, error) {
+	found, localMissing, err := tc.l1.GetMany(ctx, keys...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tc.metrics.AddL1Hits(uint64(len(keys) - len(localMissing))) //nolint:gosec // len(localMissing) <= len(keys)
+	tc.metrics.AddL1Misses(uint64(len(localMissing)))
+
+	if len(localMissing) == 0 {
+		return found, nil, nil
+	}
+
+	remote, missing, err := tc.l2.GetMany(ctx, localMissing...)
+	if err != nil {
+		tc.metrics.AddL2Error()
+		return nil, nil, err
+	}
+
+	tc.metrics.AddL2Hits(uint64(len(localMissing) - len(missing))) //nolint:gosec // len(missing) <= len(localMissing)
+	tc.metrics.AddL2Misses(uint64(len(missing)))
+
+	items := make([]Item, 0, len(remote))
+
+	for key, value := range remote {
+		data, err := EncodeValue(value)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		found[key] = data
+
+		if ttl, ok := tc.remoteTTL(ctx, key); ok {
+			items = append(items, Item{Key: key, Value: data, TTL: ttl})
+		}
+	}
+
+	if err := tc.l1.SetMany(ctx, items...); err != nil {
+		tc.log.Error().Err(err).Int("keys", len(items)).Msg("populate l1 failed")
+	}
+
+	return found, missing, nil
+}
+
+// SetMany stores items in L2 and then in L1, each with the shorter of its
+// TTL and the L1 TTL. Values are converted as by Set; if one of them cannot
+// be, nothing is written.
+//
+// If L2 fails, the keys of items are removed from L1 so that readers do not
+// keep seeing previous values, and the L2 error is returned.
+func (tc *Tiered) SetMany(ctx context.Context, items ...Item) error {
+	encoded := make([]Item, len(items))
+
+	for i, item := range items {
+		data, err := EncodeValue(item.Value)
+		if err != nil {
+			return fmt.Errorf("item %q: %w", item.Key, err)
+		}
+
+		encoded[i] = Item{Key: item.Key, Value: data, TTL: item.TTL}
+	}
+
+	items = encoded
+
+	if err := tc.l2.SetMany(ctx, items...); err != nil {
+		tc.metrics.AddL2Error()
+
+		keys := make([]string, len(items))
+		for i, item := range items {
+			keys[i] = item.Key
+		}
+
+		return errors.Join(err, tc.l1.Delete(context.WithoutCancel(ctx), keys...))
+	}
+
+	local := make([]Item, len(items))
+	for i, item := range items {
+		local[i] = Item{Key: item.Key, Value: item.Value, TTL: tc.localTTL(item.TTL)}
+	}
+
+	return tc.l1.SetMany(ctx, local...)
+}
+
+// Delete removes keys from L2 and then from L1. Keys are removed from L1
+// even if L2 fails, and both errors are returned.
+func (tc *Tiered) Delete(ctx context.Context, keys ...string) error {
+	_, err := tc.DeleteMany(ctx, keys...)
+	return err
+}
+
+// DeleteMany removes keys as Delete does and returns the number of keys L2
+// reported as present, since L1 may hold only some of them.
+func (tc *Tiered) DeleteMany(ctx context.Context, keys ...string) (int, error) {
+	deleted, err := tc.l2.DeleteMany(ctx, keys...)
+	if err != nil {
+		tc.metrics.AddL2Error()
+	}
+
+	_, localErr := tc.l1.DeleteMany(ctx, keys...)
+
+	return deleted, errors.Join(err, localErr)
+}
+
+// Digest returns the L2 fingerprint of key. If L1 holds a value with a
+// different fingerprint, the L1 entry is dropped as drifted.
+func (tc *Tiered) Digest(ctx context.Context, key string) Digest {
+	digest, _ := tc.check(ctx, key)
+	return digest
+}
+
+// Revalidate compares the L1 and L2 fingerprints of keys and drops drifted
+// L1 entries. It returns the number of entries dropped.
+//
+// Keys missing from L1 are skipped.
+// The operation can be cancelled via the context. If cancelled, Revalidate
+// returns ErrAborted along with the number of entries dropped so far.
+func (tc *Tiered) Revalidate(ctx context.Context, keys ...string) (int, error) {
+	drifted := 0
+
+	for _, key := range keys {
+		select {
+		case <-ctx.Done():
+			tc.log.Error().
+				Err(ctx.Err()).
+				Str("key", key).
+				Msg("revalidate key aborted")
+
+			return drifted, ErrAborted
+		default:
+			if _, ok := tc.check(ctx, key); ok {
+				drifted++
+			}
+		}
+	}
+
+	return drifted, nil
+}
+
+// check returns the L2 digest of key and whether the L1 entry had drifted
+// and was dropped.
+func (tc *Tiered) check(ctx context.Context, key string) (Digest, bool) {
+	local := tc.l1.digest(key, false)
+	if local == 0 {
+		return tc.l2.Digest(ctx, key), false
+	}
+
+	remote := tc.l2.Digest(ctx, key)
+	if local == remote {
+		return remote, false
+	}
+
+	if err := tc.l1.Delete(context.WithoutCancel(ctx), key); err != nil {
+		tc.log.Error().Err(err).Str("key", key).Msg("drop drifted l1 entry failed")
+		return remote, false
+	}
+
+	tc.metrics.AddDrift()
+	tc.log.Debug().Str("key", key).Msg("dropped drifted l1 entry")
+
+	return remote, true
+}
+
+// Close closes L1 and then L2, returning both errors.
+func (tc *Tiered) Close(ctx context.Context) error {
+	return errors.Join(tc.l1.Close(ctx), tc.l2.Close(ctx))
+}
+
+// L1 returns the local tier, for example to inspect its Metrics.
+func (tc *Tiered) L1() *MemCache {
+	return tc.l1
+}
+
+// Metrics returns a snapshot of per-tier hit and miss counters.
+func (tc *Tiered) Metrics() TierMetrics {
+	return tc.metrics.Snapshot()
+}
+
+// MetricsJSON returns a JSON snapshot of per-tier metrics as a string.
+func (tc *Tiered) MetricsJSON() string {
+	return tc.metrics.JSONStr()
+}
+
+// TierMetrics tracks reads of a Tiered cache per tier.
+//
+// All fields are updated atomically and are safe to read concurrently.
+type TierMetrics struct {
+	L1Hits   uint64 `json:"l1_hits"`
+	L1Misses uint64 `json:"l1_misses"`
+	L2Hits   uint64 `json:"l2_hits"`   // L1 misses served by L2
+	L2Misses uint64 `json:"l2_misses"` // Keys missing from both tiers
+	L2Errors uint64 `json:"l2_errors"` // Failed L2 operations
+	Drifts   uint64 `json:"drifts"`    // L1 entries dropped for differing from L2
+}
+
+// Snapshot returns an atomic-load copy of all metrics fields.
+func (m *TierMetrics) Snapshot() TierMetrics {
+	return TierMetrics{
+		L1Hits:   atomic.LoadUint64(&m.L1Hits),
+		L1Misses: atomic.LoadUint64(&m.L1Misses),
+		L2Hits:   atomic.LoadUint64(&m.L2Hits),
+		L2Misses: atomic.LoadUint64(&m.L2Misses),
+		L2Errors: atomic.LoadUint64(&m.L2Errors),
+		Drifts:   atomic.LoadUint64(&m.Drifts),
+	}
+}
+
+func (m *TierMetrics) AddL1Hit() {
+	atomic.AddUint64(&m.L1Hits, 1)
+}
+
+func (m *TierMetrics) AddL1Miss() {
+	atomic.AddUint64(&m.L1Misses, 1)
+}
+
+func (m *TierMetrics) AddL2Hit() {
+	atomic.AddUint64(&m.L2Hits, 1)
+}
+
+func (m *TierMetrics) AddL2Miss() {
+	atomic.AddUint64(&m.L2Misses, 1)
+}
+
+// AddL1Hits adds count L1 hits, for example of a GetMany batch.
+func (m *TierMetrics) AddL1Hits(count uint64) {
+	atomic.AddUint64(&m.L1Hits, count)
+}
+
+func (m *TierMetrics) AddL1Misses(count uint64) {
+	atomic.AddUint64(&m.L1Misses, count)
+}
+
+func (m *TierMetrics) AddL2Hits(count uint64) {
+	atomic.AddUint64(&m.L2Hits, count)
+}
+
+func (m *TierMetrics) AddL2Misses(count uint64) {
+	atomic.AddUint64(&m.L2Misses, count)
+}
+
+func (m *TierMetrics) AddL2Error() {
+	atomic.AddUint64(&m.L2Errors, 1)
+}
+
+func (m *TierMetrics) AddDrift() {
+	atomic.AddUint64(&m.Drifts, 1)
+}
+
+// JSONStr returns a JSON snapshot of the current metrics.
+func (m *TierMetrics) JSONStr() string {
+	b, err := json.Marshal(m.Snapshot())
+	if err != nil {
+		return fmt.Sprintf(`{"error":%q}`, err.Error())
+	}
+
+	return string(b)
+}
@@ -0,0 +1,337 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/cachetest"
+	"github.com/patraden/toolkit/pkg/cache/redis"
+	"github.com/patraden/toolkit/pkg/cache/redis/redistest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errRemote = errors.New("remote unavailable")
+
+// failingCache is a Cache whose reads and writes always fail.
+type failingCache struct{}
+
+func (failingCache) Set(context.Context, string, any, time.Duration) error { return errRemote }
+func (failingCache) Get(context.Context, string) (any, error)              { return nil, errRemote }
+func (failingCache) GetMany(context.Context, ...string) (map[string]any, []string, error) {
+	return nil, nil, errRemote
+}
+func (failingCache) SetMany(context.Context, ...cache.Item) error       { return errRemote }
+func (failingCache) Delete(context.Context, ...string) error            { return errRemote }
+func (failingCache) DeleteMany(context.Context, ...string) (int, error) { return 0, errRemote }
+func (failingCache) Digest(context.Context, string) cache.Digest        { return 0 }
+func (failingCache) Close(context.Context) error                        { return nil }
+
+func newTiered(t *testing.T, l2 cache.Cache, l1TTL time.Duration, opts ...cache.Option) *cache.Tiered {
+	t.Helper()
+
+	tiered := cache.NewTiered(cache.New(Logger(t), opts...), l2, l1TTL, Logger(t))
+
+	t.Cleanup(func() {
+		require.NoError(t, tiered.Close(t.Context()))
+	})
+
+	return tiered
+}
+
+func TestTieredReadThrough(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	l2 := cache.New(Logger(t))
+	tiered := newTiered(t, l2, time.Minute)
+
+	require.NoError(t, l2.Set(ctx, "remote", "v", 0))
+
+	v, err := tiered.Get(ctx, "remote")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v"), v)
+
+	v, err = tiered.L1().Get(ctx, "remote")
+	require.NoError(t, err, "L2 hit populates L1")
+	assert.Equal(t, []byte("v"), v)
+
+	_, err = tiered.Get(ctx, "remote")
+	require.NoError(t, err)
+
+	_, err = tiered.Get(ctx, "missing")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+
+	assert.Equal(t, cache.TierMetrics{
+		L1Hits:   1,
+		L1Misses: 2,
+		L2Hits:   1,
+		L2Misses: 1,
+		L2Errors: 0,
+		Drifts:   0,
+	}, tiered.Metrics())
+	assert.JSONEq(t,
+		`{"l1_hits":1,"l1_misses":2,"l2_hits":1,"l2_misses":1,"l2_errors":0,"drifts":0}`,
+		tiered.MetricsJSON())
+}
+
+func TestTieredL1TTL(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	l2 := cache.New(Logger(t), cache.WithClock(clock))
+	tiered := newTiered(t, l2, 50*time.Millisecond, cache.WithClock(clock))
+
+	require.NoError(t, tiered.Set(ctx, "written", "v", time.Hour))
+	require.NoError(t, l2.Set(ctx, "remote", "v", 0))
+
+	_, err := tiered.Get(ctx, "remote")
+	require.NoError(t, err)
+
+	clock.Advance(100 * time.Millisecond)
+
+	for _, key := range []string{"written", "remote"} {
+		_, err = tiered.L1().Get(ctx, key)
+		require.ErrorIs(t, err, cache.ErrNotFound, "L1 copy of %q expires first", key)
+
+		_, err = l2.Get(ctx, key)
+		require.NoError(t, err)
+	}
+}
+
+func TestTieredL1TTLCappedByL2(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	clock := cachetest.NewClock(time.Now())
+	l2 := cache.New(Logger(t), cache.WithClock(clock))
+	tiered := newTiered(t, l2, time.Minute, cache.WithClock(clock))
+	l1 := tiered.L1()
+
+	require.NoError(t, l2.Set(ctx, "short", "v", 10*time.Second))
+	require.NoError(t, l2.Set(ctx, "forever", "v", 0))
+
+	found, _, err := tiered.GetMany(ctx, "short", "forever")
+	require.NoError(t, err)
+	assert.Len(t, found, 2)
+
+	ttl, err := l1.TTL(ctx, "short")
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, ttl, "L1 copy expires with the L2 entry")
+
+	ttl, err = l1.TTL(ctx, "forever")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+
+	clock.Advance(11 * time.Second)
+
+	_, err = tiered.Get(ctx, "short")
+	require.ErrorIs(t, err, cache.ErrNotFound)
+}
+
+func TestTieredValueType(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	l2 := cache.New(Logger(t))
+	tiered := newTiered(t, l2, time.Minute)
+
+	require.NoError(t, tiered.Set(ctx, "key", "v", 0))
+
+	fromL1, err := tiered.Get(ctx, "key")
+	require.NoError(t, err)
+
+	require.NoError(t, tiered.L1().Delete(ctx, "key"))
+
+	fromL2, err := tiered.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v"), fromL1)
+	assert.Equal(t, fromL1, fromL2, "both tiers return the same type")
+
+	require.ErrorIs(t, tiered.Set(ctx, "struct", struct{}{}, 0), cache.ErrType)
+
+	for _, tier := range []cache.Cache{tiered.L1(), l2} {
+		_, err = tier.Get(ctx, "struct")
+		require.ErrorIs(t, err, cache.ErrNotFound, "rejected values reach no tier")
+	}
+}
+
+func TestTieredWriteThrough(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	l2 := cache.New(Logger(t))
+	tiered := newTiered(t, l2, time.Minute)
+
+	require.NoError(t, tiered.Set(ctx, "key", "v", 0))
+
+	for _, tier := range []cache.Cache{tiered.L1(), l2} {
+		v, err := tier.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("v"), v)
+	}
+
+	require.NoError(t, tiered.Delete(ctx, "key"))
+
+	for _, tier := range []cache.Cache{tiered.L1(), l2} {
+		_, err := tier.Get(ctx, "key")
+		require.ErrorIs(t, err, cache.ErrNotFound)
+	}
+}
+
+func TestTieredBatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	srv := redistest.NewServer()
+	t.Cleanup(srv.Close)
+
+	l2 := redis.New(srv.Addr(), Logger(t))
+	tiered := newTiered(t, l2, time.Minute)
+
+	require.NoError(t, tiered.SetMany(ctx,
+		cache.Item{Key: "a", Value: "1", TTL: 0},
+		cache.Item{Key: "b", Value: "2", TTL: time.Hour},
+	))
+	require.NoError(t, l2.Set(ctx, "remote", "3", 0))
+
+	found, missing, err := tiered.GetMany(ctx, "a", "b", "remote", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": []byte("1"), "b": []byte("2"), "remote": []byte("3")}, found)
+	assert.Equal(t, []string{"missing"}, missing)
+
+	v, err := tiered.L1().Get(ctx, "remote")
+	require.NoError(t, err, "L2 hits populate L1")
+	assert.Equal(t, []byte("3"), v)
+
+	assert.Equal(t, cache.TierMetrics{
+		L1Hits:   2,
+		L1Misses: 2,
+		L2Hits:   1,
+		L2Misses: 1,
+		L2Errors: 0,
+		Drifts:   0,
+	}, tiered.Metrics())
+
+	commands := srv.Commands()
+
+	_, missing, err = tiered.GetMany(ctx, "a", "b", "remote")
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+	assert.Equal(t, commands, srv.Commands(), "L1 hits do not reach L2")
+
+	require.NoError(t, l2.Set(ctx, "l2only", "4", 0))
+
+	deleted, err := tiered.DeleteMany(ctx, "a", "l2only", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted, "L2 counts the deleted keys")
+	assert.Equal(t, 2, tiered.L1().Size())
+}
+
+func TestTieredBatchL2Failure(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	tiered := newTiered(t, failingCache{}, time.Minute)
+
+	require.NoError(t, tiered.L1().Set(ctx, "a", "stale", 0))
+
+	err := tiered.SetMany(ctx, cache.Item{Key: "a", Value: "new", TTL: 0})
+	require.ErrorIs(t, err, errRemote)
+
+	_, missing, err := tiered.GetMany(ctx, "a")
+	require.ErrorIs(t, err, errRemote)
+	assert.Nil(t, missing)
+
+	_, err = tiered.L1().Get(ctx, "a")
+	require.ErrorIs(t, err, cache.ErrNotFound, "failed batches drop L1 copies")
+	assert.Equal(t, uint64(2), tiered.Metrics().L2Errors)
+}
+
+func TestTieredDrift(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	srv := redistest.NewServer()
+	t.Cleanup(srv.Close)
+
+	l2 := redis.New(srv.Addr(), Logger(t))
+	tiered := newTiered(t, l2, time.Minute)
+
+	require.NoError(t, tiered.Set(ctx, "a", "v1", 0))
+	require.NoError(t, tiered.Set(ctx, "b", 42, 0))
+	require.NoError(t, tiered.Set(ctx, "c", "v1", 0))
+	require.NoError(t, tiered.Set(ctx, "d", true, 0))
+
+	assert.Equal(t, cache.DigestOf("v1"), tiered.Digest(ctx, "a"))
+	assert.Equal(t, cache.DigestOf(true), tiered.Digest(ctx, "d"))
+	assert.Equal(t, uint64(0), tiered.Metrics().Drifts, "tiers agree after write-through")
+
+	// Another process updates L2 behind this cache's back.
+	require.NoError(t, l2.Set(ctx, "a", "v2", 0))
+	require.NoError(t, l2.Set(ctx, "c", "v2", 0))
+
+	v, err := tiered.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v1"), v, "L1 serves the drifted value until checked")
+
+	assert.Equal(t, cache.DigestOf("v2"), tiered.Digest(ctx, "a"))
+
+	v, err = tiered.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v2"), v, "drifted entry is reloaded from L2")
+
+	dropped, err := tiered.Revalidate(ctx, "a", "b", "c", "d", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, 1, dropped)
+	assert.Equal(t, uint64(2), tiered.Metrics().Drifts)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err = tiered.Revalidate(cancelled, "a")
+	require.ErrorIs(t, err, cache.ErrAborted)
+}
+
+func TestTieredL2Errors(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	tiered := newTiered(t, failingCache{}, time.Minute)
+
+	require.NoError(t, tiered.L1().Set(ctx, "stale", "v", 0))
+
+	require.ErrorIs(t, tiered.Set(ctx, "stale", "new", 0), errRemote)
+
+	_, err := tiered.L1().Get(ctx, "stale")
+	require.ErrorIs(t, err, cache.ErrNotFound, "failed write drops the L1 copy")
+
+	_, err = tiered.Get(ctx, "stale")
+	require.ErrorIs(t, err, errRemote)
+
+	require.NoError(t, tiered.L1().Set(ctx, "key", "v", 0))
+	require.ErrorIs(t, tiered.Delete(ctx, "key"), errRemote)
+
+	_, err = tiered.L1().Get(ctx, "key")
+	require.ErrorIs(t, err, cache.ErrNotFound, "L1 delete happens even if L2 fails")
+
+	assert.Equal(t, uint64(3), tiered.Metrics().L2Errors)
+}
@@ -0,0 +1,224 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+const (
+	// tinyLFUWindowPercent is the share of capacity given to the window LRU.
+	tinyLFUWindowPercent = 1
+	// tinyLFUProtectedPercent is the share of the main space given to the
+	// protected segment.
+	tinyLFUProtectedPercent = 80
+)
+
+type segment uint8
+
+const (
+	segmentWindow segment = iota
+	segmentProbation
+	segmentProtected
+)
+
+type tinyLFUNode struct {
+	key     string
+	segment segment
+}
+
+// tinyLFU is a W-TinyLFU Policy.
+//
+// New keys enter a small window LRU. Keys leaving the window compete for a
+// place in the main space, a segmented LRU made of a probation and a
+// protected segment: the candidate is admitted only if the frequency sketch
+// estimates it was seen more often than the probation victim it would
+// replace. Keys read while on probation are promoted to the protected
+// segment.
+type tinyLFU struct {
+	sketch       *cmSketch
+	elems        map[string]*list.Element
+	window       *list.List
+	probation    *list.List
+	protected    *list.List
+	windowCap    int
+	mainCap      int
+	protectedCap int
+	mx           sync.Mutex
+}
+
+// NewTinyLFU returns a W-TinyLFU Policy bounded to capacity keys.
+//
+// Compared to NewLRU it resists scans: a burst of keys read once cannot push
+// out keys that are read frequently, because the burst keys lose the
+// admission comparison against the frequent ones. Admission verdicts are
+// reported through Admission.Decision.
+func NewTinyLFU(capacity int) Policy {
+	capacity = max(capacity, 1)
+	windowCap := max(capacity*tinyLFUWindowPercent/100, 1)
+	mainCap := capacity - windowCap
+
+	return &tinyLFU{
+		sketch:       newCMSketch(capacity),
+		elems:        make(map[string]*list.Element),
+		window:       list.New(),
+		probation:    list.New(),
+		protected:    list.New(),
+		windowCap:    windowCap,
+		mainCap:      mainCap,
+		protectedCap: mainCap * tinyLFUProtectedPercent / 100,
+		mx:           sync.Mutex{},
+	}
+}
+
+// Add places a new key in the window and, if the window overflows, lets its
+// oldest key compete for the main space.
+func (p *tinyLFU) Add(key string) Admission {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.sketch.increment(key)
+
+	if elem, ok := p.elems[key]; ok {
+		p.promote(elem)
+		return Admission{}
+	}
+
+	p.elems[key] = p.window.PushFront(&tinyLFUNode{key: key, segment: segmentWindow})
+
+	if p.window.Len() <= p.windowCap {
+		return Admission{}
+	}
+
+	candidate := p.window.Back()
+	candidateNode := nodeOf(candidate)
+	p.window.Remove(candidate)
+
+	if p.mainCap == 0 {
+		delete(p.elems, candidateNode.key)
+		return Admission{Evicted: []string{candidateNode.key}, Decision: DecisionNone}
+	}
+
+	if p.probation.Len()+p.protected.Len() < p.mainCap {
+		p.pushProbation(candidateNode)
+		return Admission{}
+	}
+
+	victim := p.probation.Back()
+	if victim == nil {
+		victim = p.protected.Back()
+	}
+
+	victimNode := nodeOf(victim)
+
+	if p.sketch.estimate(candidateNode.key) <= p.sketch.estimate(victimNode.key) {
+		delete(p.elems, candidateNode.key)
+		return Admission{Evicted: []string{candidateNode.key}, Decision: DecisionReject}
+	}
+
+	p.listOf(victimNode.segment).Remove(victim)
+	delete(p.elems, victimNode.key)
+	p.pushProbation(candidateNode)
+
+	return Admission{Evicted: []string{victimNode.key}, Decision: DecisionAdmit}
+}
+
+// Evict forgets and returns the oldest key on probation, or else the oldest
+// key of the window, or else the oldest protected key.
+func (p *tinyLFU) Evict() (string, bool) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	for _, segment := range []*list.List{p.probation, p.window, p.protected} {
+		if oldest := segment.Back(); oldest != nil {
+			key := nodeOf(oldest).key
+
+			segment.Remove(oldest)
+			delete(p.elems, key)
+
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+// Access counts key in the frequency sketch and refreshes its recency.
+func (p *tinyLFU) Access(key string) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.sketch.increment(key)
+
+	if elem, ok := p.elems[key]; ok {
+		p.promote(elem)
+	}
+}
+
+// Remove forgets key. Unknown keys are ignored.
+func (p *tinyLFU) Remove(key string) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if elem, ok := p.elems[key]; ok {
+		p.listOf(nodeOf(elem).segment).Remove(elem)
+		delete(p.elems, key)
+	}
+}
+
+// promote refreshes recency of a tracked key, moving probation keys to the
+// protected segment and demoting protected overflow back to probation.
+func (p *tinyLFU) promote(elem *list.Element) {
+	node := nodeOf(elem)
+
+	switch node.segment {
+	case segmentWindow:
+		p.window.MoveToFront(elem)
+	case segmentProtected:
+		p.protected.MoveToFront(elem)
+	case segmentProbation:
+		p.probation.Remove(elem)
+		node.segment = segmentProtected
+		p.elems[node.key] = p.protected.PushFront(node)
+
+		if p.protected.Len() > p.protectedCap {
+			demoted := p.protected.Back()
+			p.protected.Remove(demoted)
+			p.pushProbation(nodeOf(demoted))
+		}
+	}
+}
+
+func (p *tinyLFU) pushProbation(node *tinyLFUNode) {
+	node.segment = segmentProbation
+	p.elems[node.key] = p.probation.PushFront(node)
+}
+
+func (p *tinyLFU) listOf(seg segment) *list.List {
+	switch seg {
+	case segmentProbation:
+		return p.probation
+	case segmentProtected:
+		return p.protected
+	default:
+		return p.window
+	}
+}
+
+func nodeOf(elem *list.Element) *tinyLFUNode {
+	node, _ := elem.Value.(*tinyLFUNode)
+	return node
+}
@@ -0,0 +1,98 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Typed is a type-safe façade over a Cache holding values of type V.
+//
+// Without a codec, values are stored as-is and Get asserts the stored value
+// to V. With a codec, values are encoded to []byte on Set and decoded on Get,
+// so the same typed API works against byte-oriented backends. In both modes a
+// stored value that cannot be turned into a V is reported as ErrType.
+type Typed[V any] struct {
+	cache Cache
+	codec Codec
+}
+
+// NewTyped returns a Typed façade storing values of type V in c as-is.
+func NewTyped[V any](c Cache) *Typed[V] {
+	return &Typed[V]{cache: c, codec: nil}
+}
+
+// NewTypedWithCodec returns a Typed façade storing values of type V in c
+// encoded with codec.
+func NewTypedWithCodec[V any](c Cache, codec Codec) *Typed[V] {
+	return &Typed[V]{cache: c, codec: codec}
+}
+
+// Set stores value under key with the provided TTL. See Cache for TTL
+// semantics. If the value cannot be encoded, Set returns an error wrapping
+// ErrType.
+func (tc *Typed[V]) Set(ctx context.Context, key string, value V, ttl time.Duration) error {
+	if tc.codec == nil {
+		return tc.cache.Set(ctx, key, value, ttl)
+	}
+
+	data, err := tc.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("%w: encode %q: %w", ErrType, key, err)
+	}
+
+	return tc.cache.Set(ctx, key, data, ttl)
+}
+
+// Get returns the value stored under key.
+//
+// Get returns the underlying cache error (such as ErrNotFound) as-is, and an
+// error wrapping ErrType if the stored value is not a V or cannot be decoded
+// into one.
+func (tc *Typed[V]) Get(ctx context.Context, key string) (V, error) {
+	var value V
+
+	raw, err := tc.cache.Get(ctx, key)
+	if err != nil {
+		return value, err
+	}
+
+	if tc.codec == nil {
+		typed, ok := raw.(V)
+		if !ok {
+			return value, fmt.Errorf("%w: %q holds %T", ErrType, key, raw)
+		}
+
+		return typed, nil
+	}
+
+	if err := decode(tc.codec, key, raw, &value); err != nil {
+		return value, err
+	}
+
+	return value, nil
+}
+
+// Delete removes keys from the underlying cache.
+func (tc *Typed[V]) Delete(ctx context.Context, keys ...string) error {
+	return tc.cache.Delete(ctx, keys...)
+}
+
+// Cache returns the underlying cache.
+func (tc *Typed[V]) Cache() Cache {
+	return tc.cache
+}
@@ -0,0 +1,116 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type user struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestTyped(t *testing.T) {
+	t.Parallel()
+
+	mcache := cache.WithDeleteInterval(time.Hour, Logger(t))
+
+	t.Cleanup(func() {
+		require.NoError(t, mcache.Close(t.Context()))
+	})
+
+	ctx := t.Context()
+
+	t.Run("round trips values without codec", func(t *testing.T) {
+		t.Parallel()
+
+		users := cache.NewTyped[user](mcache)
+
+		require.NoError(t, users.Set(ctx, "plain:alice", user{Name: "alice", Age: 30}, 0))
+
+		got, err := users.Get(ctx, "plain:alice")
+		require.NoError(t, err)
+		assert.Equal(t, user{Name: "alice", Age: 30}, got)
+
+		raw, err := mcache.Get(ctx, "plain:alice")
+		require.NoError(t, err)
+		assert.IsType(t, user{}, raw)
+	})
+
+	t.Run("round trips values with codec", func(t *testing.T) {
+		t.Parallel()
+
+		users := cache.NewTypedWithCodec[user](mcache, cache.JSONCodec{})
+
+		require.NoError(t, users.Set(ctx, "json:bob", user{Name: "bob", Age: 40}, 0))
+
+		raw, err := mcache.Get(ctx, "json:bob")
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"name":"bob","age":40}`, string(raw.([]byte)))
+
+		got, err := users.Get(ctx, "json:bob")
+		require.NoError(t, err)
+		assert.Equal(t, user{Name: "bob", Age: 40}, got)
+	})
+
+	t.Run("wrong type is reported as ErrType", func(t *testing.T) {
+		t.Parallel()
+
+		require.NoError(t, mcache.Set(ctx, "wrong:int", 42, 0))
+
+		_, err := cache.NewTyped[string](mcache).Get(ctx, "wrong:int")
+		require.ErrorIs(t, err, cache.ErrType)
+
+		_, err = cache.NewTypedWithCodec[user](mcache, cache.JSONCodec{}).Get(ctx, "wrong:int")
+		require.ErrorIs(t, err, cache.ErrType)
+
+		require.NoError(t, mcache.Set(ctx, "wrong:json", []byte("{not json"), 0))
+
+		_, err = cache.NewTypedWithCodec[user](mcache, cache.JSONCodec{}).Get(ctx, "wrong:json")
+		require.ErrorIs(t, err, cache.ErrType)
+	})
+
+	t.Run("unencodable value is reported as ErrType", func(t *testing.T) {
+		t.Parallel()
+
+		funcs := cache.NewTypedWithCodec[func()](mcache, cache.JSONCodec{})
+
+		err := funcs.Set(ctx, "func", func() {}, 0)
+		require.ErrorIs(t, err, cache.ErrType)
+	})
+
+	t.Run("missing key keeps cache error", func(t *testing.T) {
+		t.Parallel()
+
+		users := cache.NewTyped[user](mcache)
+
+		_, err := users.Get(ctx, "missing")
+		require.ErrorIs(t, err, cache.ErrNotFound)
+		require.NotErrorIs(t, err, cache.ErrType)
+
+		require.NoError(t, users.Set(ctx, "deleted", user{}, 0))
+		require.NoError(t, users.Delete(ctx, "deleted"))
+
+		_, err = users.Get(ctx, "deleted")
+		require.ErrorIs(t, err, cache.ErrNotFound)
+		assert.Same(t, mcache, users.Cache())
+	})
+}
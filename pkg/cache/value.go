@@ -0,0 +1,50 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import "fmt"
+
+// EncodeValue converts a primitive value to the bytes stored by byte-oriented
+// backends.
+//
+// Strings and byte slices are stored as-is, integers and floats in their
+// shortest decimal form and booleans as a single 1 or 0 byte. Other types are
+// reported as ErrType; encode them with a Codec first.
+func EncodeValue(value any) ([]byte, error) {
+	switch val := value.(type) {
+	case []byte:
+		return val, nil
+	case string:
+		return []byte(val), nil
+	case int, int8, int16, int32, int64:
+		return fmt.Appendf(nil, "%d", val), nil
+	case uint, uint8, uint16, uint32, uint64, uintptr:
+		return fmt.Appendf(nil, "%d", val), nil
+	case float32, float64:
+		return fmt.Appendf(nil, "%g", val), nil
+	case bool:
+		return []byte{boolToUint8(val)}, nil
+	default:
+		return nil, fmt.Errorf("%w: %T cannot be stored as bytes", ErrType, value)
+	}
+}
+
+func boolToUint8(b bool) uint8 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
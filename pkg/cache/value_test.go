@@ -0,0 +1,66 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{value: "text", want: "text"},
+		{value: []byte("raw"), want: "raw"},
+		{value: -42, want: "-42"},
+		{value: int8(7), want: "7"},
+		{value: uint64(math.MaxUint64), want: "18446744073709551615"},
+		{value: 1.5, want: "1.5"},
+		{value: float32(0.25), want: "0.25"},
+		{value: true, want: "\x01"},
+		{value: false, want: "\x00"},
+	}
+
+	for _, tt := range tests {
+		got, err := cache.EncodeValue(tt.value)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, string(got))
+		assert.Equal(t, cache.DigestOf(tt.value), cache.DigestOf(got), "%T", tt.value)
+	}
+
+	_, err := cache.EncodeValue(struct{}{})
+	require.ErrorIs(t, err, cache.ErrType)
+
+	_, err = cache.EncodeValue(nil)
+	require.ErrorIs(t, err, cache.ErrType)
+}
+
+func TestDigestOf(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, cache.Digest(0), cache.DigestOf(nil))
+	assert.Equal(t, cache.Digest(0), cache.DigestOf(func() {}))
+	assert.NotEqual(t, cache.DigestOf(true), cache.DigestOf(false))
+	assert.Equal(t, cache.DigestOf("a"), cache.DigestOf([]byte("a")))
+	assert.NotEqual(t, cache.DigestOf("a"), cache.DigestOf("b"))
+}
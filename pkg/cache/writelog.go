@@ -0,0 +1,809 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// FsyncPolicy controls when the write log is flushed to stable storage.
+type FsyncPolicy int
+
+const (
+	// FsyncAlways syncs the log before every mutation returns. No
+	// acknowledged write is lost on a crash, at the cost of one fsync per
+	// mutation.
+	FsyncAlways FsyncPolicy = iota
+	// FsyncEverySecond syncs the log once per second in the background. A
+	// crash loses at most about one second of writes.
+	FsyncEverySecond
+	// FsyncNever leaves syncing to the operating system. Writes survive a
+	// process crash but not necessarily a machine crash.
+	FsyncNever
+)
+
+// DefaultCompactionThreshold is the write log size from which it is
+// compacted automatically.
+const DefaultCompactionThreshold = 64 << 20
+
+// Write log format, version 3:
+//
+//	header: "TKCLOG" version:byte
+//	record: type:byte size:uvarint payload crc32:uint32be
+//
+// A set record carries an entry encoded as in snapshots, a tagged or sliding
+// set record an entry followed by its tags, a delete record a length-prefixed
+// key and an expire record a key followed by the new expiresAt and ttl. The
+// CRC-32 (IEEE) covers type, size and payload.
+//
+// Each kind of record raised the version that introduced it: version 1 logs
+// only hold set and delete records, and version 2 logs also tagged set
+// records, written by SetWithTags. Older logs are replayed as is and upgraded
+// to the current version when opened, so that older readers reject the log
+// once it may hold newer records.
+const (
+	logMagic      = "TKCLOG"
+	logVersion    = 3
+	minLogVersion = 1
+
+	logSet        = 1
+	logDelete     = 2
+	logTaggedSet  = 3
+	logSlidingSet = 4
+	logExpire     = 5
+
+	logSyncInterval = time.Second
+
+	// maxSwapDrains bounds how often swap writes and syncs the records
+	// buffered during a compaction without holding the log lock, should
+	// writers keep adding records.
+	maxSwapDrains = 4
+)
+
+// writeLog is the append-only log of a durable MemCache.
+//
+// Records are appended while the cache write lock is held, so the log order
+// matches the order in which mutations were applied.
+type writeLog struct {
+	file      *os.File
+	rewrite   *bytes.Buffer // records appended while compacting, even after err
+	err       error         // first write error, reported until compaction
+	path      string
+	policy    FsyncPolicy
+	threshold int64
+	size      int64
+	baseSize  int64 // size after the last compaction
+	mx        sync.Mutex
+	dirty     bool
+	closed    bool
+	compact   atomic.Bool
+}
+
+// Open returns a MemCache whose mutations are recorded in an append-only log
+// at path, so that its contents survive restarts and crashes.
+//
+// If the log exists, Open replays it first: entries come back with their
+// original expiry times, and entries that expired in the meantime are
+// skipped. A torn record at the end of the log, as left by a crash during a
+// write, is dropped with a warning; any other corruption makes Open fail with
+// an error wrapping ErrWriteLog.
+//
+// Set, SetWithRefresh, Delete, Restore and background refreshes are logged, as
+// are expiry changes by Expire, Persist and Touch, and capacity evictions and
+// keys rejected by the cache Policy, as deletes. Expiry evictions are not:
+// replay drops expired entries. Reads extending sliding entries are not logged
+// either, so the log grows with writes only: replay gives the sliding entries
+// still live at their logged expiry a full window from the time of the replay,
+// as if they had just been read. Entries are replayed in the order they were
+// last written, so a bounded cache keeps the same keys on every restart. Values
+// of non-primitive types are encoded with the Codec set by WithValueCodec. The
+// log is synced as configured by WithFsync and compacted in the background once
+// it grows past WithCompactionThreshold and has doubled since the last
+// compaction.
+//
+// cleanupInterval has the same meaning as in WithDeleteInterval. Close must
+// be called to stop the background goroutines and close the log.
+func Open(path string, cleanupInterval time.Duration, log zerolog.Logger, opts ...Option) (*MemCache, error) {
+	if cleanupInterval <= 0 {
+		cleanupInterval = DefaultCleanupInterval
+	}
+
+	cfg := newOptions(opts)
+
+	wlog, replayed, err := openWriteLog(path, cfg, log)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := newMemCache(cleanupInterval, log, cfg)
+	loaded := cache.loadEntries(replayed)
+	cache.wlog = wlog
+
+	cache.logWG.Add(1)
+
+	go cache.logWorker()
+
+	log.Info().Str("path", path).Int("entries", loaded).Msg("cache write log replayed")
+
+	return cache, nil
+}
+
+func openWriteLog(path string, cfg options, log zerolog.Logger) (*writeLog, *entrySet, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600) //nolint:mnd // owner-only permissions
+	if err != nil {
+		return nil, nil, fmt.Errorf("open write log: %w", err)
+	}
+
+	entries, size, err := replayWriteLog(file, cfg.codec, cfg.clock.Now().UTC(), log)
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+
+	wlog := &writeLog{
+		file:      file,
+		rewrite:   nil,
+		err:       nil,
+		path:      path,
+		policy:    cfg.fsync,
+		threshold: cfg.compactionThreshold,
+		size:      size,
+		baseSize:  size,
+		mx:        sync.Mutex{},
+		dirty:     false,
+		closed:    false,
+		compact:   atomic.Bool{},
+	}
+
+	return wlog, entries, nil
+}
+
+// replayWriteLog reads the log from the start and returns the resulting
+// entries in the order they were last written, dropping those expired at now
+// and extending sliding ones from now, and the size of its valid prefix.
+// A torn tail is truncated and an empty file gets a header, leaving file
+// positioned for appends.
+func replayWriteLog(file *os.File, codec Codec, now time.Time, log zerolog.Logger) (*entrySet, int64, error) {
+	reader := bufio.NewReader(file)
+	header := make([]byte, len(logMagic)+1)
+
+	n, err := io.ReadFull(reader, header)
+	if n == 0 && errors.Is(err, io.EOF) {
+		if _, err := file.Write(append([]byte(logMagic), logVersion)); err != nil {
+			return nil, 0, fmt.Errorf("write log header: %w", err)
+		}
+
+		return newEntrySet(), int64(len(header)), nil
+	}
+
+	if err != nil || string(header[:len(logMagic)]) != logMagic {
+		return nil, 0, fmt.Errorf("%w: bad header", ErrWriteLog)
+	}
+
+	version := header[len(logMagic)]
+	if version < minLogVersion || version > logVersion {
+		return nil, 0, fmt.Errorf("%w: unsupported version %d", ErrWriteLog, version)
+	}
+
+	entries := newEntrySet()
+	size := int64(len(header))
+
+	for {
+		record, payload, n, err := readLogRecord(reader)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			if !tornRecord(reader, err) {
+				return nil, 0, fmt.Errorf("record at offset %d: %w", size, err)
+			}
+
+			log.Warn().
+				Err(err).
+				Int64("offset", size).
+				Msg("dropping torn write log tail")
+
+			if err := file.Truncate(size); err != nil {
+				return nil, 0, fmt.Errorf("truncate write log: %w", err)
+			}
+
+			break
+		}
+
+		if err := applyLogRecord(entries, record, payload, codec, now); err != nil {
+			return nil, 0, fmt.Errorf("%w: record at offset %d: %w", ErrWriteLog, size, err)
+		}
+
+		size += int64(n)
+	}
+
+	if version < logVersion {
+		if _, err := file.WriteAt([]byte{logVersion}, int64(len(logMagic))); err != nil {
+			return nil, 0, fmt.Errorf("upgrade write log header: %w", err)
+		}
+	}
+
+	for key, val := range entries.entries {
+		if val.sliding {
+			val.expireIn(val.ttl, now)
+			entries.entries[key] = val
+		}
+	}
+
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		return nil, 0, fmt.Errorf("seek write log: %w", err)
+	}
+
+	return entries, size, nil
+}
+
+// tornRecord reports whether err, returned by readLogRecord, comes from a
+// record torn by a crash during a write: one cut short by the end of the
+// file, or the last record of the file failing its checksum. Any other
+// failure is corruption.
+func tornRecord(reader *bufio.Reader, err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	_, peekErr := reader.Peek(1)
+
+	return errors.Is(peekErr, io.EOF)
+}
+
+// readLogRecord reads one record and returns its type, payload and encoded
+// size. It returns io.EOF only at a record boundary and io.ErrUnexpectedEOF
+// for a record cut short by the end of the file.
+func readLogRecord(reader *bufio.Reader) (byte, []byte, int, error) {
+	record, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	frame := []byte{record}
+
+	size, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return 0, nil, 0, logReadErr(err)
+	}
+
+	frame = binary.AppendUvarint(frame, size)
+
+	var payload bytes.Buffer
+	if _, err := io.CopyN(&payload, reader, int64(size)); err != nil { //nolint:gosec // bounded by the read
+		return 0, nil, 0, logReadErr(err)
+	}
+
+	frame = append(frame, payload.Bytes()...)
+
+	var sum [4]byte
+	if _, err := io.ReadFull(reader, sum[:]); err != nil {
+		return 0, nil, 0, logReadErr(err)
+	}
+
+	if binary.BigEndian.Uint32(sum[:]) != crc32.ChecksumIEEE(frame) {
+		return 0, nil, 0, fmt.Errorf("%w: checksum mismatch", ErrWriteLog)
+	}
+
+	return record, payload.Bytes(), len(frame) + len(sum), nil
+}
+
+// logReadErr reports an end of file within a record as io.ErrUnexpectedEOF
+// and wraps other read errors in ErrWriteLog.
+func logReadErr(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return fmt.Errorf("%w: %w", ErrWriteLog, err)
+}
+
+func applyLogRecord(entries *entrySet, record byte, payload []byte, codec Codec, now time.Time) error {
+	reader := newSnapshotReader(bytes.NewReader(payload))
+
+	switch record {
+	case logSet, logTaggedSet, logSlidingSet:
+		key, val, err := reader.entry(codec)
+		if err != nil {
+			return err
+		}
+
+		if record != logSet {
+			if val.tags, err = reader.tags(); err != nil {
+				return err
+			}
+		}
+
+		val.sliding = record == logSlidingSet
+
+		if val.IsExpired(now) {
+			entries.delete(key)
+		} else {
+			entries.set(key, val)
+		}
+	case logDelete:
+		key, err := reader.bytes()
+		if err != nil {
+			return err
+		}
+
+		entries.delete(string(key))
+	case logExpire:
+		return applyLogExpire(entries, reader, now)
+	default:
+		return fmt.Errorf("unknown record type %d", record)
+	}
+
+	return nil
+}
+
+func applyLogExpire(entries *entrySet, reader *snapshotReader, now time.Time) error {
+	key, err := reader.bytes()
+	if err != nil {
+		return err
+	}
+
+	expiresAt, err := reader.varint()
+	if err != nil {
+		return err
+	}
+
+	ttl, err := reader.varint()
+	if err != nil {
+		return err
+	}
+
+	val, ok := entries.entries[string(key)]
+	if !ok {
+		return nil
+	}
+
+	val.expiresAt = fromUnixNano(expiresAt)
+	val.ttl = time.Duration(ttl)
+
+	if val.IsExpired(now) {
+		entries.delete(string(key))
+	} else {
+		entries.entries[string(key)] = val
+	}
+
+	return nil
+}
+
+// appendLogRecord appends a framed record with the given payload to buf.
+func appendLogRecord(buf []byte, record byte, payload []byte) []byte {
+	start := len(buf)
+
+	buf = append(buf, record)
+	buf = binary.AppendUvarint(buf, uint64(len(payload)))
+	buf = append(buf, payload...)
+
+	return binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf[start:]))
+}
+
+func encodeLogSet(key string, val entry, codec Codec) ([]byte, error) {
+	payload, err := appendEntry(nil, key, val, codec)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case val.sliding:
+		return appendLogRecord(nil, logSlidingSet, appendTags(payload, val.tags)), nil
+	case len(val.tags) > 0:
+		return appendLogRecord(nil, logTaggedSet, appendTags(payload, val.tags)), nil
+	}
+
+	return appendLogRecord(nil, logSet, payload), nil
+}
+
+func encodeLogExpire(key string, val entry) []byte {
+	payload := appendBytes(nil, []byte(key))
+	payload = binary.AppendVarint(payload, unixNano(val.expiresAt))
+	payload = binary.AppendVarint(payload, int64(val.ttl))
+
+	return appendLogRecord(nil, logExpire, payload)
+}
+
+func encodeLogDelete(key string) []byte {
+	return appendLogRecord(nil, logDelete, appendBytes(nil, []byte(key)))
+}
+
+// append writes record to the log. Write errors are kept and reported by
+// commit.
+//
+// During a compaction, record is also buffered for the compacted log, even
+// if the log failed: the compacted log then holds every mutation made since
+// the entry set was copied, and replacing the failed log with it clears the
+// error.
+func (wl *writeLog) append(record []byte) {
+	wl.mx.Lock()
+	defer wl.mx.Unlock()
+
+	if wl.rewrite != nil {
+		wl.rewrite.Write(record)
+	}
+
+	if wl.err != nil {
+		return
+	}
+
+	if wl.closed {
+		wl.err = os.ErrClosed
+		return
+	}
+
+	n, err := wl.file.Write(record)
+	wl.size += int64(n)
+	wl.dirty = true
+
+	if err != nil {
+		wl.err = fmt.Errorf("append to write log: %w", err)
+	}
+}
+
+// commit makes appended records durable as the fsync policy requires and
+// reports write errors.
+func (wl *writeLog) commit() error {
+	if wl.policy == FsyncAlways {
+		return wl.sync()
+	}
+
+	wl.mx.Lock()
+	defer wl.mx.Unlock()
+
+	return wl.err
+}
+
+func (wl *writeLog) sync() error {
+	wl.mx.Lock()
+	defer wl.mx.Unlock()
+
+	if wl.err != nil || !wl.dirty || wl.closed {
+		return wl.err
+	}
+
+	if err := wl.file.Sync(); err != nil {
+		wl.err = fmt.Errorf("sync write log: %w", err)
+		return wl.err
+	}
+
+	wl.dirty = false
+
+	return nil
+}
+
+func (wl *writeLog) needsCompaction() bool {
+	wl.mx.Lock()
+	defer wl.mx.Unlock()
+
+	return wl.threshold > 0 && wl.size >= wl.threshold && wl.size >= 2*wl.baseSize
+}
+
+func (wl *writeLog) close() error {
+	err := wl.sync()
+
+	wl.mx.Lock()
+	defer wl.mx.Unlock()
+
+	if wl.closed {
+		return nil
+	}
+
+	wl.closed = true
+
+	return errors.Join(err, wl.file.Close())
+}
+
+// logSet appends a set record for key. The caller must hold the write lock.
+func (mc *MemCache) logSet(key string, val entry) {
+	if mc.wlog == nil {
+		return
+	}
+
+	record, err := encodeLogSet(key, val, mc.codec)
+	if err != nil {
+		mc.log.Error().Err(err).Str("key", key).Msg("write log encoding failed")
+		return
+	}
+
+	mc.wlog.append(record)
+}
+
+// logEvicted appends delete records for keys evicted by the cache Policy or
+// the byte budget, including a key the Policy rejected, so that replay does
+// not bring them back. The caller must hold the write lock.
+func (mc *MemCache) logEvicted(keys []string) {
+	if mc.wlog == nil {
+		return
+	}
+
+	for _, key := range keys {
+		mc.wlog.append(encodeLogDelete(key))
+	}
+}
+
+// logExpire appends an expire record for key. The caller must hold the write
+// lock.
+func (mc *MemCache) logExpire(key string, val entry) {
+	if mc.wlog != nil {
+		mc.wlog.append(encodeLogExpire(key, val))
+	}
+}
+
+// logWorker syncs the write log and starts compactions until Close is
+// called.
+func (mc *MemCache) logWorker() {
+	defer mc.logWG.Done()
+
+	ticker := mc.clock.NewTicker(logSyncInterval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for {
+		select {
+		case <-ticker.C():
+			if mc.wlog.policy == FsyncEverySecond {
+				if err := mc.wlog.sync(); err != nil {
+					mc.log.Error().Err(err).Msg("write log sync failed")
+				}
+			}
+
+			if mc.wlog.needsCompaction() && !mc.wlog.compact.Load() {
+				mc.logWG.Add(1)
+
+				go func() {
+					defer mc.logWG.Done()
+
+					if err := mc.Compact(ctx); err != nil {
+						mc.log.Error().Err(err).Msg("write log compaction failed")
+					}
+				}()
+			}
+		case <-mc.stopCh:
+			return
+		}
+	}
+}
+
+// Compact rewrites the write log from the live entries of the cache,
+// dropping overwritten, deleted and expired entries.
+//
+// The entry set is copied under a read lock and written to a new file
+// without holding any lock; mutations made meanwhile are appended to both
+// logs. The new log is synced and then replaces the old one atomically.
+// Writers, and readers waiting behind them, are only blocked while the entry
+// set is copied and while the log is renamed. A compaction already in
+// progress makes Compact return immediately.
+//
+// As the new log holds every mutation of the cache, replacing the old one
+// clears a write error it had met, and writes are acknowledged again.
+//
+// Compact returns ErrWriteLog if the cache has no write log. The operation
+// can be cancelled via the context. If cancelled, Compact returns ErrAborted
+// and the old log stays in place.
+func (mc *MemCache) Compact(ctx context.Context) error {
+	wlog := mc.wlog
+	if wlog == nil {
+		return fmt.Errorf("%w: cache has no write log", ErrWriteLog)
+	}
+
+	if !wlog.compact.CompareAndSwap(false, true) {
+		return nil
+	}
+	defer wlog.compact.Store(false)
+
+	start := time.Now()
+	now := mc.now()
+
+	mc.mx.RLock()
+
+	keys := make([]string, 0, len(mc.items))
+	entries := make([]entry, 0, len(mc.items))
+
+	for key, val := range mc.items {
+		if !val.IsExpired(now) {
+			keys = append(keys, key)
+			entries = append(entries, val)
+		}
+	}
+
+	wlog.mx.Lock()
+	wlog.rewrite = &bytes.Buffer{}
+	wlog.mx.Unlock()
+
+	mc.mx.RUnlock()
+
+	tmpPath := wlog.path + ".compact"
+
+	size, err := mc.writeCompacted(ctx, tmpPath, keys, entries)
+	if err == nil {
+		err = wlog.swap(tmpPath, size)
+	}
+
+	if err != nil {
+		wlog.mx.Lock()
+		wlog.rewrite = nil
+		wlog.mx.Unlock()
+
+		_ = os.Remove(tmpPath)
+
+		return err
+	}
+
+	mc.log.Info().
+		Int("entries", len(keys)).
+		Int64("bytes", size).
+		Dur("duration", time.Since(start)).
+		Msg("cache write log compacted")
+
+	return nil
+}
+
+// writeCompacted writes a log holding set records for entries to path and
+// returns its size.
+func (mc *MemCache) writeCompacted(ctx context.Context, path string, keys []string, entries []entry) (int64, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600) //nolint:mnd // owner-only permissions
+	if err != nil {
+		return 0, fmt.Errorf("create compacted write log: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	size := int64(len(logMagic) + 1)
+
+	_, _ = writer.Write(append([]byte(logMagic), logVersion))
+
+	for i, key := range keys {
+		select {
+		case <-ctx.Done():
+			mc.log.Error().
+				Err(ctx.Err()).
+				Str("key", key).
+				Msg("write log compaction aborted")
+
+			return 0, ErrAborted
+		default:
+		}
+
+		record, err := encodeLogSet(key, entries[i], mc.codec)
+		if err != nil {
+			return 0, fmt.Errorf("compact key %q: %w", key, err)
+		}
+
+		n, err := writer.Write(record)
+		size += int64(n)
+
+		if err != nil {
+			return 0, fmt.Errorf("write compacted write log: %w", err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return 0, fmt.Errorf("write compacted write log: %w", err)
+	}
+
+	if err := file.Sync(); err != nil {
+		return 0, fmt.Errorf("sync compacted write log: %w", err)
+	}
+
+	return size, nil
+}
+
+// swap appends the records buffered during compaction to the compacted log
+// at tmpPath and replaces the current log with it.
+//
+// Writers append to the log while holding the cache write lock, so the log
+// lock is not held while the buffered records are written and synced: swap
+// takes them out of the buffer and writes them unlocked, as long as writers
+// keep adding records, up to maxSwapDrains times. The lock is then only held
+// to write the remaining records, usually none, and to rename the log and
+// sync its directory.
+func (wl *writeLog) swap(tmpPath string, size int64) error {
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return fmt.Errorf("open compacted write log: %w", err)
+	}
+
+	for range maxSwapDrains {
+		wl.mx.Lock()
+		pending := wl.rewrite
+		wl.rewrite = &bytes.Buffer{}
+		wl.mx.Unlock()
+
+		if pending.Len() == 0 {
+			break
+		}
+
+		n, err := writeSynced(file, pending.Bytes())
+		size += int64(n)
+
+		if err != nil {
+			_ = file.Close()
+			return fmt.Errorf("replace write log: %w", err)
+		}
+	}
+
+	wl.mx.Lock()
+	defer wl.mx.Unlock()
+
+	if wl.closed {
+		_ = file.Close()
+		return os.ErrClosed
+	}
+
+	n, err := writeSynced(file, wl.rewrite.Bytes())
+	if err == nil {
+		err = os.Rename(tmpPath, wl.path)
+	}
+
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("replace write log: %w", err)
+	}
+
+	syncDir(filepath.Dir(wl.path))
+
+	_ = wl.file.Close()
+
+	wl.file = file
+	wl.rewrite = nil
+	wl.size = size + int64(n)
+	wl.baseSize = wl.size
+	wl.dirty = false
+	wl.err = nil
+
+	return nil
+}
+
+// writeSynced writes records to file and syncs it, unless records is empty.
+func writeSynced(file *os.File, records []byte) (int, error) {
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	n, err := file.Write(records)
+	if err == nil {
+		err = file.Sync()
+	}
+
+	return n, err
+}
+
+// syncDir makes a rename in dir durable. Errors are ignored: not every
+// platform supports syncing directories.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+}
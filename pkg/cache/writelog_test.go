@@ -0,0 +1,381 @@
+// Copyright 2025 The Toolkit Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/patraden/toolkit/pkg/cache"
+	"github.com/patraden/toolkit/pkg/cache/cachetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openLogged(t *testing.T, path string, opts ...cache.Option) *cache.MemCache {
+	t.Helper()
+
+	mcache, err := cache.Open(path, time.Minute, Logger(t), opts...)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		require.NoError(t, mcache.Close(t.Context()))
+	})
+
+	return mcache
+}
+
+func TestWriteLogReplay(t *testing.T) {
+	t.Parallel()
+
+	policies := map[string]cache.FsyncPolicy{
+		"always":       cache.FsyncAlways,
+		"every second": cache.FsyncEverySecond,
+		"never":        cache.FsyncNever,
+	}
+
+	for name, policy := range policies {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			path := filepath.Join(t.TempDir(), "cache.log")
+			clock := cachetest.NewClock(time.Now())
+			first := openLogged(t, path, cache.WithFsync(policy), cache.WithClock(clock))
+
+			require.NoError(t, first.Set(ctx, "str", "v1", 0))
+			require.NoError(t, first.Set(ctx, "str", "v2", 0))
+			require.NoError(t, first.Set(ctx, "int", 42, time.Hour))
+			require.NoError(t, first.Set(ctx, "point", snapshotPoint{X: 1, Y: 2}, 0))
+			require.NoError(t, first.Set(ctx, "short", "v", 100*time.Millisecond))
+			require.NoError(t, first.Set(ctx, "expired", "v", time.Nanosecond))
+			require.NoError(t, first.Set(ctx, "deleted", "v", 0))
+			require.NoError(t, first.Delete(ctx, "deleted", "missing"))
+			require.NoError(t, first.Close(ctx))
+			clock.Advance(time.Millisecond)
+
+			second := openLogged(t, path, cache.WithFsync(policy), cache.WithClock(clock))
+
+			want := map[string]any{"str": "v2", "int": 42, "point": snapshotPoint{X: 1, Y: 2}, "short": "v"}
+			for key, value := range want {
+				got, err := second.Get(ctx, key)
+				require.NoError(t, err, key)
+				assert.Equal(t, value, got, key)
+			}
+
+			assert.Equal(t, len(want), second.Size())
+
+			clock.Advance(150 * time.Millisecond)
+
+			_, err := second.Get(ctx, "short")
+			require.ErrorIs(t, err, cache.ErrNotFound, "replayed entries keep their original expiry")
+		})
+	}
+}
+
+func TestWriteLogBoundedReplay(t *testing.T) {
+	t.Parallel()
+
+	policies := map[string][]cache.Option{
+		"lru":     {cache.WithMaxEntries(3)},
+		"tinylfu": {cache.WithMaxEntries(3), cache.WithPolicy(cache.NewTinyLFU)},
+	}
+
+	for name, opts := range policies {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			path := filepath.Join(t.TempDir(), "cache.log")
+			live := openLogged(t, path, opts...)
+
+			for i := range 20 {
+				require.NoError(t, live.Set(ctx, fmt.Sprintf("k-%d", i%7), i, 0))
+			}
+
+			want := slices.Sorted(live.Keys())
+			require.Len(t, want, 3)
+			require.NoError(t, live.Close(ctx))
+
+			for range 5 {
+				replayed, err := cache.Open(path, time.Minute, Logger(t), opts...)
+				require.NoError(t, err)
+
+				got := slices.Sorted(replayed.Keys())
+				require.NoError(t, replayed.Close(ctx))
+				assert.Equal(t, want, got, "every restart keeps the keys the live cache held")
+			}
+
+			unbounded := openLogged(t, path)
+			assert.Equal(t, want, slices.Sorted(unbounded.Keys()), "evictions are logged")
+		})
+	}
+}
+
+func TestWriteLogTornTail(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	path := filepath.Join(t.TempDir(), "cache.log")
+	first := openLogged(t, path)
+
+	require.NoError(t, first.Set(ctx, "a", "v", 0))
+	require.NoError(t, first.Set(ctx, "b", "v", 0))
+	require.NoError(t, first.Close(ctx))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	valid, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// Simulate a crash in the middle of appending a set record announcing a
+	// 50-byte payload.
+	require.NoError(t, os.WriteFile(path, append(bytes.Clone(valid), 1, 50, 'x'), 0o600))
+
+	second := openLogged(t, path)
+	assert.Equal(t, 2, second.Size())
+
+	require.NoError(t, second.Set(ctx, "c", "v", 0))
+	require.NoError(t, second.Close(ctx))
+
+	truncated, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, valid, truncated[:info.Size()], "the torn record is dropped before appending")
+
+	reopened := openLogged(t, path)
+	assert.Equal(t, 3, reopened.Size())
+}
+
+func TestWriteLogCorruptRecord(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	path := filepath.Join(t.TempDir(), "cache.log")
+	first := openLogged(t, path)
+
+	for i := range 10 {
+		require.NoError(t, first.Set(ctx, fmt.Sprintf("key-%d", i), i, 0))
+	}
+
+	require.NoError(t, first.Close(ctx))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// Flip a byte in the payload of the first record, after the 7-byte
+	// header and the type and size of the record.
+	corrupt := bytes.Clone(data)
+	corrupt[10] ^= 0xff
+	require.NoError(t, os.WriteFile(path, corrupt, 0o600))
+
+	_, err = cache.Open(path, time.Minute, Logger(t))
+	require.ErrorIs(t, err, cache.ErrWriteLog, "records after a corrupt one are not dropped")
+
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, corrupt, after, "a corrupt log is left untouched")
+
+	// The same damage in the last record is a torn write.
+	last := bytes.Clone(data)
+	last[len(last)-1] ^= 0xff
+	require.NoError(t, os.WriteFile(path, last, 0o600))
+
+	reopened := openLogged(t, path)
+	assert.Equal(t, 9, reopened.Size())
+}
+
+func TestWriteLogOlderVersions(t *testing.T) {
+	t.Parallel()
+
+	for _, version := range []byte{1, 2} {
+		t.Run(fmt.Sprintf("version %d", version), func(t *testing.T) {
+			t.Parallel()
+
+			ctx := t.Context()
+			path := filepath.Join(t.TempDir(), "cache.log")
+			first := openLogged(t, path)
+
+			require.NoError(t, first.Set(ctx, "key", "value", 0))
+
+			if version > 1 {
+				require.NoError(t, first.SetWithTags(ctx, "tagged", "value", 0, "tag"))
+			}
+
+			require.NoError(t, first.Close(ctx))
+
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+
+			data[len("TKCLOG")] = version
+			require.NoError(t, os.WriteFile(path, data, 0o600))
+
+			second := openLogged(t, path)
+
+			value, err := second.Get(ctx, "key")
+			require.NoError(t, err)
+			assert.Equal(t, "value", value)
+
+			if version > 1 {
+				require.NoError(t, second.InvalidateTags(ctx, "tag"))
+				assert.Equal(t, 1, second.Size(), "tagged records keep their tags")
+			}
+
+			upgraded, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, byte(3), upgraded[len("TKCLOG")], "opened logs are upgraded to the current version")
+		})
+	}
+}
+
+func TestWriteLogInvalid(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	bad := filepath.Join(dir, "bad.log")
+	require.NoError(t, os.WriteFile(bad, []byte("NOTALOG"), 0o600))
+
+	_, err := cache.Open(bad, time.Minute, Logger(t))
+	require.ErrorIs(t, err, cache.ErrWriteLog)
+
+	newer := filepath.Join(dir, "newer.log")
+	require.NoError(t, os.WriteFile(newer, []byte("TKCLOG\x63"), 0o600))
+
+	_, err = cache.Open(newer, time.Minute, Logger(t))
+	require.ErrorIs(t, err, cache.ErrWriteLog)
+
+	_, err = cache.Open(filepath.Join(dir, "missing", "cache.log"), time.Minute, Logger(t))
+	require.Error(t, err)
+
+	logged := openLogged(t, filepath.Join(dir, "cache.log"))
+
+	require.ErrorIs(t, logged.Set(t.Context(), "chan", make(chan int), 0), cache.ErrType)
+
+	_, err = logged.Get(t.Context(), "chan")
+	require.ErrorIs(t, err, cache.ErrNotFound, "values the log cannot hold are not stored")
+
+	require.ErrorIs(t, newMemCache(t).Compact(t.Context()), cache.ErrWriteLog)
+}
+
+func TestWriteLogCompact(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	path := filepath.Join(t.TempDir(), "cache.log")
+	first := openLogged(t, path, cache.WithCompactionThreshold(0))
+
+	for i := range 1000 {
+		require.NoError(t, first.Set(ctx, fmt.Sprintf("k-%d", i%10), i, 0))
+	}
+
+	require.NoError(t, first.Set(ctx, "expired", "v", time.Nanosecond))
+
+	before, err := os.Stat(path)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for i := range 200 {
+			assert.NoError(t, first.Set(ctx, fmt.Sprintf("during-%d", i), i, 0))
+		}
+	}()
+
+	require.NoError(t, first.Compact(ctx))
+	wg.Wait()
+
+	after, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Less(t, after.Size(), before.Size())
+
+	require.NoError(t, first.Set(ctx, "after", "v", 0))
+	require.NoError(t, first.Close(ctx))
+
+	second := openLogged(t, path)
+	assert.Equal(t, 10+200+1, second.Size(), "writes made during compaction are kept")
+
+	got, err := second.Get(ctx, "k-9")
+	require.NoError(t, err)
+	assert.Equal(t, 999, got)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	require.ErrorIs(t, second.Compact(cancelled), cache.ErrAborted)
+	assert.Equal(t, 10+200+1, openLoggedSize(t, second, path))
+}
+
+// openLoggedSize closes mcache and reports the size of the cache replayed
+// from path.
+func openLoggedSize(t *testing.T, mcache *cache.MemCache, path string) int {
+	t.Helper()
+
+	require.NoError(t, mcache.Close(t.Context()))
+
+	return openLogged(t, path).Size()
+}
+
+func TestWriteLogAutoCompaction(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	path := filepath.Join(t.TempDir(), "cache.log")
+	mcache := openLogged(t, path, cache.WithCompactionThreshold(4096), cache.WithFsync(cache.FsyncNever))
+
+	for i := range 1000 {
+		require.NoError(t, mcache.Set(ctx, "key", i, 0))
+	}
+
+	require.Eventually(t, func() bool {
+		info, err := os.Stat(path)
+		return err == nil && info.Size() < 4096
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestWriteLogClock(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	path := filepath.Join(t.TempDir(), "cache.log")
+	clock := cachetest.NewClock(time.Now())
+	mcache := openLogged(t, path, cache.WithClock(clock), cache.WithCompactionThreshold(4096))
+
+	for i := range 1000 {
+		require.NoError(t, mcache.Set(ctx, "key", i, 0))
+	}
+
+	compacted := func() bool {
+		info, err := os.Stat(path)
+		return err == nil && info.Size() < 4096
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, compacted(), "no compaction before the clock ticks")
+
+	clock.Advance(time.Second)
+	require.Eventually(t, compacted, 5*time.Second, 10*time.Millisecond)
+}